@@ -10,7 +10,7 @@ import (
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "usage: %s [options] <filename>\n", os.Args[0])
-	fmt.Fprintf(os.Stderr, "\nSupported formats: json, yaml, hcl\n\n")
+	fmt.Fprintf(os.Stderr, "\nSupported formats: json, yaml, hcl, toml\n\n")
 	flag.PrintDefaults()
 	os.Exit(1)
 }
@@ -26,6 +26,13 @@ var conversions = map[string]conversionFunc{
 	"yaml->hcl":  convert.YAMLToHCL,
 	"hcl->json":  convert.HCLToJSON,
 	"hcl->yaml":  convert.HCLToYAML,
+	"hcl->hcl":   convert.FormatHCL,
+	"json->toml": convert.JSONToTOML,
+	"yaml->toml": convert.YAMLToTOML,
+	"hcl->toml":  convert.HCLToTOML,
+	"toml->json": convert.TOMLToJSON,
+	"toml->yaml": convert.TOMLToYAML,
+	"toml->hcl":  convert.TOMLToHCL,
 }
 
 func main() {
@@ -35,7 +42,8 @@ func main() {
 	flag.StringVar(&to, "to", "hcl", "to format")
 	flag.Parse()
 
-	if from == to {
+	// hcl to hcl formats the file, keeping its comments
+	if from == to && from != "hcl" {
 		fmt.Fprintf(os.Stderr, "error: from and to format are the same\n")
 		os.Exit(1)
 	}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"github.com/genelet/horizon/dethcl"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	"gopkg.in/yaml.v3"
 )
 
@@ -76,17 +78,21 @@ func YAMLToJSON(raw []byte) ([]byte, error) {
 // JSONToHCL converts JSON data to HCL format.
 //
 // Note: The HCL output will not contain variables or expressions, only
-// declarative data. Maps are represented as HCL blocks with labels.
+// declarative data, except for the objects {"$hcl": "..."} given by
+// HCLToJSONRaw, which are written as the expressions in them. The keys
+// "$$hcl", "$$$hcl"... are the escaped "$hcl", "$$hcl"... Maps are
+// represented as HCL blocks with labels.
 //
 // Returns the HCL-formatted data or an error if parsing or conversion fails.
 func JSONToHCL(raw []byte) ([]byte, error) {
-	return convertFormat(raw, json.Unmarshal, dethcl.Marshal)
+	return convertFormat(raw, json.Unmarshal, marshalRaw)
 }
 
 // HCLToJSON converts HCL data to JSON format.
 //
 // Important: The HCL input should not contain variables or complex expressions,
-// only declarative data structures. Such features will cause errors.
+// only declarative data structures. Such features will cause errors; use
+// HCLToJSONRaw to keep them as source text.
 //
 // Returns the JSON-formatted data or an error if parsing or conversion fails.
 func HCLToJSON(raw []byte) ([]byte, error) {
@@ -112,3 +118,79 @@ func YAMLToHCL(raw []byte) ([]byte, error) {
 func HCLToYAML(raw []byte) ([]byte, error) {
 	return convertFormat(raw, hclUnmarshal, yaml.Marshal)
 }
+
+// TOMLToJSON converts TOML data to JSON format.
+//
+// Date-times, which JSON lacks, become strings.
+//
+// Returns the JSON-formatted data or an error if parsing or conversion fails.
+func TOMLToJSON(raw []byte) ([]byte, error) {
+	return convertFormat(raw, tomlUnmarshal, json.Marshal)
+}
+
+// JSONToTOML converts JSON data to TOML format.
+//
+// A TOML document is a table, so the JSON must be an object; an array or a
+// scalar at the top level is an error, as is a null anywhere.
+//
+// Returns the TOML-formatted data or an error if parsing or conversion fails.
+func JSONToTOML(raw []byte) ([]byte, error) {
+	return convertFormat(raw, json.Unmarshal, tomlMarshal)
+}
+
+// TOMLToHCL converts TOML data to HCL format.
+//
+// Note: The HCL output will not contain variables or expressions, only
+// declarative data. Tables are represented as HCL blocks.
+//
+// Returns the HCL-formatted data or an error if parsing or conversion fails.
+func TOMLToHCL(raw []byte) ([]byte, error) {
+	return convertFormat(raw, tomlUnmarshal, dethcl.Marshal)
+}
+
+// HCLToTOML converts HCL data to TOML format.
+//
+// Important: The HCL input should not contain variables or complex expressions,
+// only declarative data structures. Such features will cause errors.
+//
+// Returns the TOML-formatted data or an error if parsing or conversion fails.
+func HCLToTOML(raw []byte) ([]byte, error) {
+	return convertFormat(raw, hclUnmarshal, tomlMarshal)
+}
+
+// TOMLToYAML converts TOML data to YAML format.
+//
+// Returns the YAML-formatted data or an error if parsing or conversion fails.
+func TOMLToYAML(raw []byte) ([]byte, error) {
+	return convertFormat(raw, tomlUnmarshal, yaml.Marshal)
+}
+
+// YAMLToTOML converts YAML data to TOML format.
+//
+// As with JSONToTOML, the YAML must be a mapping at the top level.
+//
+// Returns the TOML-formatted data or an error if parsing or conversion fails.
+func YAMLToTOML(raw []byte) ([]byte, error) {
+	return convertFormat(raw, yaml.Unmarshal, tomlMarshal)
+}
+
+// FormatHCL rewrites HCL data in the canonical layout of hclwrite, as
+// "terraform fmt" does: indentation and the alignment of equal signs are
+// fixed, while comments, expressions and the order of attributes and blocks
+// are kept as written.
+//
+// Unlike the other conversions, the data is not decoded into a generic
+// map, so variables and function calls are allowed.
+//
+// Returns the formatted data or an error if the HCL cannot be parsed.
+func FormatHCL(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("input is empty")
+	}
+
+	f, diags := hclwrite.ParseConfig(raw, "input.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse HCL: %w", diags)
+	}
+	return hclwrite.Format(f.Bytes()), nil
+}
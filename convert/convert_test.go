@@ -248,3 +248,34 @@ func TestYAMLToJSONArray(t *testing.T) {
 		t.Fatalf("unexpected JSON output: %#v", got)
 	}
 }
+
+func TestFormatHCL(t *testing.T) {
+	raw := []byte(`# service settings
+service "api" {
+    port=8080 # public port
+  // upstream address
+  host = var.host
+}
+`)
+	bs, err := FormatHCL(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# service settings
+service "api" {
+  port = 8080 # public port
+  // upstream address
+  host = var.host
+}
+`
+	if string(bs) != expected {
+		t.Errorf("got:\n%s", bs)
+	}
+
+	if _, err := FormatHCL([]byte(`service "api" {`)); err == nil {
+		t.Error("expected error for unclosed block")
+	}
+	if _, err := FormatHCL(nil); err == nil {
+		t.Error("expected error for empty input")
+	}
+}
@@ -0,0 +1,237 @@
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/genelet/horizon/dethcl"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// rawKey is the only key of the JSON object carrying the source of an HCL
+// expression, e.g. {"$hcl": "8080 + 1"}.
+const rawKey = "$hcl"
+
+// HCLToJSONRaw converts HCL data to JSON like HCLToJSON, but keeps the
+// source text of each attribute whose value is not a literal, in an object
+// of the single key "$hcl", instead of evaluating it:
+//
+//	port   = 8080 + 1    // "port": {"$hcl": "8080 + 1"}
+//	region = var.region  // "region": {"$hcl": "var.region"}
+//
+// Literals, including lists and objects of literals and strings without
+// interpolation, are converted as by HCLToJSON. Variables and function calls
+// are thus allowed, and JSONToHCL writes the expressions back as they were.
+//
+// An object key or block label "$hcl" of the data itself is escaped with one
+// more "$", as are "$$hcl", "$$$hcl" and so on, so that m = { "$hcl" = "1" }
+// gives "m": {"$$hcl": "1"} and JSONToHCL gives the literal back.
+//
+// Returns the JSON-formatted data or an error if parsing or conversion fails.
+func HCLToJSONRaw(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("input is empty")
+	}
+	wrapped, err := wrapExpressions(raw)
+	if err != nil {
+		return nil, err
+	}
+	return HCLToJSON(wrapped)
+}
+
+// wrapExpressions replaces in src each attribute expression that is not a
+// literal by an object literal { "$hcl" = "<source>" }, and escapes the
+// literal keys and labels which look like it.
+func wrapExpressions(src []byte) ([]byte, error) {
+	file, diags := hclsyntax.ParseConfig(src, "input.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse HCL: %w", diags)
+	}
+	type replacement struct {
+		rng  hcl.Range
+		text []byte
+	}
+	var replacements []replacement
+	escape := func(rng hcl.Range, key string) {
+		if isRawKey(key) {
+			replacements = append(replacements, replacement{rng, hclwrite.TokensForValue(cty.StringVal("$" + key)).Bytes()})
+		}
+	}
+	var walkLiteral func(expr hclsyntax.Expression)
+	walkLiteral = func(expr hclsyntax.Expression) {
+		switch e := expr.(type) {
+		case *hclsyntax.TupleConsExpr:
+			for _, item := range e.Exprs {
+				walkLiteral(item)
+			}
+		case *hclsyntax.ObjectConsExpr:
+			for _, item := range e.Items {
+				key := item.KeyExpr.(*hclsyntax.ObjectConsKeyExpr).Wrapped
+				if v, diags := key.Value(nil); hcl.ExprAsKeyword(key) == "" && !diags.HasErrors() && v.Type() == cty.String {
+					escape(key.Range(), v.AsString())
+				}
+				walkLiteral(item.ValueExpr)
+			}
+		default:
+		}
+	}
+	var walk func(body *hclsyntax.Body)
+	walk = func(body *hclsyntax.Body) {
+		for _, attr := range body.Attributes {
+			if isLiteral(attr.Expr) {
+				walkLiteral(attr.Expr)
+				continue
+			}
+			text := "{ " + strconv.Quote(rawKey) + " = " + string(hclwrite.TokensForValue(cty.StringVal(string(attr.Expr.Range().SliceBytes(src)))).Bytes()) + " }"
+			replacements = append(replacements, replacement{attr.Expr.Range(), []byte(text)})
+		}
+		for _, block := range body.Blocks {
+			for i, label := range block.Labels {
+				escape(block.LabelRanges[i], label)
+			}
+			walk(block.Body)
+		}
+	}
+	walk(file.Body.(*hclsyntax.Body))
+	if len(replacements) == 0 {
+		return src, nil
+	}
+
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].rng.Start.Byte < replacements[j].rng.Start.Byte })
+	var out []byte
+	start := 0
+	for _, r := range replacements {
+		out = append(out, src[start:r.rng.Start.Byte]...)
+		out = append(out, r.text...)
+		start = r.rng.End.Byte
+	}
+	return append(out, src[start:]...), nil
+}
+
+// isRawKey reports whether key is rawKey, or rawKey escaped with one or more
+// "$", e.g. "$$hcl".
+func isRawKey(key string) bool {
+	rest, ok := strings.CutSuffix(key, rawKey)
+	return ok && strings.Trim(rest, "$") == ""
+}
+
+// isLiteral reports whether expr is a constant: a number, bool, null or
+// string without interpolation, a negative number, or a list or object of
+// literals with keys that are names or strings.
+func isLiteral(expr hclsyntax.Expression) bool {
+	switch e := expr.(type) {
+	case *hclsyntax.LiteralValueExpr:
+		return true
+	case *hclsyntax.TemplateExpr:
+		return e.IsStringLiteral()
+	case *hclsyntax.UnaryOpExpr:
+		_, ok := e.Val.(*hclsyntax.LiteralValueExpr)
+		return ok && e.Op == hclsyntax.OpNegate
+	case *hclsyntax.TupleConsExpr:
+		for _, item := range e.Exprs {
+			if !isLiteral(item) {
+				return false
+			}
+		}
+		return true
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range e.Items {
+			key, ok := item.KeyExpr.(*hclsyntax.ObjectConsKeyExpr)
+			if !ok || (hcl.ExprAsKeyword(key.Wrapped) == "" && !isLiteral(key.Wrapped)) || !isLiteral(item.ValueExpr) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// marshalRaw marshals obj to HCL like dethcl.Marshal, writing each object
+// {"$hcl": "<source>"} given by HCLToJSONRaw as the expression in it, and
+// the escaped keys "$$hcl", "$$$hcl"... as "$hcl", "$$hcl"...
+func marshalRaw(obj any) ([]byte, error) {
+	// placeholders must not be found in the data
+	prefix := rawKey
+	for hasString(obj, prefix) {
+		prefix += "$"
+	}
+	var exprs []string
+	obj = replaceExpressions(obj, prefix, &exprs)
+	bs, err := dethcl.Marshal(obj)
+	if err != nil || len(exprs) == 0 {
+		return bs, err
+	}
+	pairs := make([]string, 0, 2*len(exprs))
+	for i, expr := range exprs {
+		placeholder := hclwrite.TokensForValue(cty.StringVal(fmt.Sprintf("%s%d", prefix, i))).Bytes()
+		pairs = append(pairs, string(placeholder), expr)
+	}
+	return []byte(strings.NewReplacer(pairs...).Replace(string(bs))), nil
+}
+
+// rawExpression returns the source of v if it is an object {"$hcl": "..."}.
+func rawExpression(v any) (string, bool) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	expr, ok := m[rawKey].(string)
+	return expr, ok
+}
+
+// replaceExpressions returns v with each object {"$hcl": "..."} replaced by
+// the string prefix followed by its index in exprs, to which its source is
+// appended, and its escaped keys unescaped.
+func replaceExpressions(v any, prefix string, exprs *[]string) any {
+	if expr, ok := rawExpression(v); ok {
+		*exprs = append(*exprs, expr)
+		return fmt.Sprintf("%s%d", prefix, len(*exprs)-1)
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(x))
+		for key, item := range x {
+			if key != rawKey && isRawKey(key) {
+				key = key[1:]
+			}
+			m[key] = replaceExpressions(item, prefix, exprs)
+		}
+		return m
+	case []any:
+		list := make([]any, len(x))
+		for i, item := range x {
+			list[i] = replaceExpressions(item, prefix, exprs)
+		}
+		return list
+	default:
+		return v
+	}
+}
+
+// hasString reports whether s is found in any key or string of v.
+func hasString(v any, s string) bool {
+	switch x := v.(type) {
+	case string:
+		return strings.Contains(x, s)
+	case map[string]any:
+		for key, item := range x {
+			if strings.Contains(key, s) || hasString(item, s) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range x {
+			if hasString(item, s) {
+				return true
+			}
+		}
+	default:
+	}
+	return false
+}
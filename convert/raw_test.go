@@ -0,0 +1,99 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHCLToJSONRaw(t *testing.T) {
+	src := []byte(`name     = "api"
+port     = 8080 + 1
+region   = var.region
+tags     = ["a", -1, { x = 1 }]
+greeting = "hi ${var.name}"
+script   = <<EOT
+echo ${var.x}
+EOT
+service "web" {
+  replicas = max(1, var.n)
+  env      = { a = "1" }
+}
+`)
+	js, err := HCLToJSONRaw(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"greeting":{"$hcl":"\"hi ${var.name}\""},"name":"api","port":{"$hcl":"8080 + 1"},"region":{"$hcl":"var.region"},"script":{"$hcl":"\u003c\u003cEOT\necho ${var.x}\nEOT"},"service":{"web":{"env":{"a":"1"},"replicas":{"$hcl":"max(1, var.n)"}}},"tags":["a",-1,{"x":1}]}`
+	if string(js) != want {
+		t.Errorf("got  %s\nwant %s", js, want)
+	}
+
+	back, err := JSONToHCL(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"port = 8080 + 1", "region = var.region", `greeting = "hi ${var.name}"`, "replicas = max(1, var.n)", "script = <<EOT\necho ${var.x}\nEOT"} {
+		if !strings.Contains(string(back), line) {
+			t.Errorf("missing %q in\n%s", line, back)
+		}
+	}
+	again, err := HCLToJSONRaw(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != want {
+		t.Errorf("round trip: got %s", again)
+	}
+
+	if _, err := HCLToJSONRaw(nil); err == nil {
+		t.Error("expected an error for empty input")
+	}
+	if _, err := HCLToJSONRaw([]byte("port = ")); err == nil {
+		t.Error("expected an error for invalid HCL")
+	}
+}
+
+func TestJSONToHCLRawPlaceholders(t *testing.T) {
+	// strings that look like placeholders are kept as data
+	bs, err := JSONToHCL([]byte(`{"a": "$hcl0", "b": {"$hcl": "var.b"}, "c": {"$hcl": "x", "d": 1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(strings.Fields(string(bs)), " ")
+	if want := `a = "$hcl0" b = var.b c = { "$hcl" = "x" d = 1 }`; got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestHCLToJSONRawLiteralKey(t *testing.T) {
+	// keys of the data that look like "$hcl" are escaped with one more "$"
+	src := []byte(`m = { "$hcl" = "1 + 1", "$$hcl" = [{ "$hcl" = 2 }], a = "$hcl" }
+port = 1 + 1
+item "$hcl" {
+  x = 1
+}
+`)
+	js, err := HCLToJSONRaw(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"item":{"$$hcl":{"x":1}},"m":{"$$$hcl":[{"$$hcl":2}],"$$hcl":"1 + 1","a":"$hcl"},"port":{"$hcl":"1 + 1"}}`
+	if string(js) != want {
+		t.Errorf("got  %s\nwant %s", js, want)
+	}
+
+	back, err := JSONToHCL(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(back), "m = 1 + 1") || !strings.Contains(string(back), "port = 1 + 1") {
+		t.Errorf("got\n%s", back)
+	}
+	again, err := HCLToJSONRaw(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != want {
+		t.Errorf("round trip: got %s\nfrom\n%s", again, back)
+	}
+}
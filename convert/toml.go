@@ -0,0 +1,135 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// tomlUnmarshal decodes TOML data into v, a *any or a *map[string]any, to
+// match the UnmarshalFunc signature. Tables become map[string]any, arrays
+// []any, integers int64 and floats float64. Date-times have no JSON or YAML
+// counterpart and are kept as strings, e.g. "1979-05-27T07:32:00Z", with
+// local dates and times as "1979-05-27" and "07:32:00".
+func tomlUnmarshal(data []byte, v any) error {
+	root := make(map[string]any)
+	if err := toml.Unmarshal(data, &root); err != nil {
+		var derr *toml.DecodeError
+		if errors.As(err, &derr) {
+			line, _ := derr.Position()
+			return fmt.Errorf("TOML line %d: %s", line, strings.TrimPrefix(derr.Error(), "toml: "))
+		}
+		return err
+	}
+	table := tomlStrings(root).(map[string]any)
+	switch target := v.(type) {
+	case *any:
+		*target = table
+	case *map[string]any:
+		*target = table
+	default:
+		return fmt.Errorf("TOML decodes into *any or *map[string]any, got %T", v)
+	}
+	return nil
+}
+
+// tomlStrings returns value with its date-times replaced by their text.
+func tomlStrings(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = tomlStrings(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = tomlStrings(item)
+		}
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case toml.LocalDate, toml.LocalTime, toml.LocalDateTime:
+		return fmt.Sprint(v)
+	default:
+	}
+	return value
+}
+
+// tomlMarshal encodes v as TOML to match the MarshalFunc signature. A TOML
+// document is a table, so v must be a map[string]any, as decoded from a
+// JSON or YAML object or from HCL; a list or a scalar is an error. Keys are
+// sorted, and floats without fraction, like the numbers of JSON, are
+// written as integers. TOML has no null, and no integers beyond int64, so
+// those are errors too.
+func tomlMarshal(v any) ([]byte, error) {
+	table, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("TOML needs a table at the top level, got %T", v)
+	}
+	value, err := tomlValue(nil, table)
+	if err != nil {
+		return nil, err
+	}
+	return toml.Marshal(value)
+}
+
+// tomlValue returns value in the types the TOML encoder writes, at path.
+func tomlValue(path []string, value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("TOML has no null value, at %s", strings.Join(path, "."))
+	case map[string]any:
+		table := make(map[string]any, len(v))
+		for key, item := range v {
+			x, err := tomlValue(append(path, key), item)
+			if err != nil {
+				return nil, err
+			}
+			table[key] = x
+		}
+		return table, nil
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			x, err := tomlValue(path, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = x
+		}
+		return list, nil
+	case float64:
+		return tomlFloat(v), nil
+	case float32:
+		return tomlFloat(float64(v)), nil
+	case *big.Int:
+		if !v.IsInt64() {
+			return nil, fmt.Errorf("TOML integer out of range, at %s", strings.Join(path, "."))
+		}
+		return v.Int64(), nil
+	case *big.Float:
+		f, _ := v.Float64()
+		if math.IsInf(f, 0) {
+			return nil, fmt.Errorf("TOML float out of range, at %s", strings.Join(path, "."))
+		}
+		return tomlFloat(f), nil
+	default:
+	}
+	if rv := reflect.ValueOf(value); rv.CanUint() && rv.Uint() > math.MaxInt64 {
+		return nil, fmt.Errorf("TOML integer out of range, at %s", strings.Join(path, "."))
+	}
+	return value, nil
+}
+
+// tomlFloat returns f as an int64 if it is a whole number such as the 2 of
+// JSON, so that it is written as an integer.
+func tomlFloat(f float64) any {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return int64(f)
+	}
+	return f
+}
@@ -0,0 +1,255 @@
+package convert
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestTOMLUnmarshal(t *testing.T) {
+	raw := []byte(`# a document
+title = "TOML \"example\"\u00e9"
+path = 'C:\Users\app'
+"quoted key" = 1
+site.owner = "tom"
+
+text = """
+first \
+  second
+"""
+raw = '''
+keep \n'''
+
+[database]
+ports = [ 8000, 8001,
+  8002, # last
+]
+limits = { max = 1_000, ratio = 0.5 }
+enabled = true
+hex = 0xff
+temp = -inf
+created = 1979-05-27 07:32:00Z
+alarm = 07:32:00
+
+[[servers]]
+name = "alpha"
+
+[servers.tls]
+on = true
+
+[[servers]]
+name = "beta"
+
+[servers.tls]
+on = false
+`)
+	var got any
+	if err := tomlUnmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"title":      `TOML "example"é`,
+		"path":       `C:\Users\app`,
+		"quoted key": int64(1),
+		"site":       map[string]any{"owner": "tom"},
+		"text":       "first second\n",
+		"raw":        `keep \n`,
+		"database": map[string]any{
+			"ports":   []any{int64(8000), int64(8001), int64(8002)},
+			"limits":  map[string]any{"max": int64(1000), "ratio": 0.5},
+			"enabled": true,
+			"hex":     int64(255),
+			"temp":    math.Inf(-1),
+			"created": "1979-05-27T07:32:00Z",
+			"alarm":   "07:32:00",
+		},
+		"servers": []any{
+			map[string]any{"name": "alpha", "tls": map[string]any{"on": true}},
+			map[string]any{"name": "beta", "tls": map[string]any{"on": false}},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestTOMLUnmarshalErrors(t *testing.T) {
+	for _, raw := range []string{
+		"a = 1\na = 2",
+		"[a]\nx = 1\n[a]\ny = 2",
+		"a = ",
+		"a = \"open",
+		"a = [1, 2",
+		"a = 1 b = 2",
+		"a = \"\\x\"",
+		"[a\nx = 1",
+		"a = 1\n[a.b]",
+		"x = 01",
+		"t = {a=1}\n[t.b]",
+		"a.b = 1\n[a]",
+	} {
+		var got any
+		err := tomlUnmarshal([]byte(raw), &got)
+		if err == nil || !strings.Contains(err.Error(), "TOML line") {
+			t.Errorf("%q: expected an error with its line, got %v", raw, err)
+		}
+	}
+}
+
+func TestTOMLMarshal(t *testing.T) {
+	obj := map[string]any{
+		"name":  "app",
+		"port":  float64(8080),
+		"ratio": 0.25,
+		"tags":  []any{"a", "b"},
+		"nodes": []any{map[string]any{"id": 1}, map[string]any{"id": 2}},
+		"mixed": []any{1, map[string]any{"x": "y"}},
+		"db":    map[string]any{"host": "localhost", "pool": map[string]any{"size": 5}},
+		"a.b":   "quoted\n",
+	}
+	bs, err := tomlMarshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `'a.b' = "quoted\n"
+mixed = [1, {x = 'y'}]
+name = 'app'
+port = 8080
+ratio = 0.25
+tags = ['a', 'b']
+
+[db]
+host = 'localhost'
+
+[db.pool]
+size = 5
+
+[[nodes]]
+id = 1
+
+[[nodes]]
+id = 2
+`
+	if string(bs) != expected {
+		t.Errorf("got\n%s\nwant\n%s", bs, expected)
+	}
+
+	var back any
+	if err := tomlUnmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.(map[string]any)["a.b"] != "quoted\n" || len(back.(map[string]any)["nodes"].([]any)) != 2 {
+		t.Errorf("round trip: %#v", back)
+	}
+}
+
+func TestTOMLConversions(t *testing.T) {
+	for _, fn := range []string{"x", "y", "z"} {
+		t.Run(fn, func(t *testing.T) {
+			rawjson, err := os.ReadFile(fn + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			toml, err := JSONToTOML(rawjson)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jsn, err := TOMLToJSON(toml)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(string(jsn)) != strings.TrimSpace(string(rawjson)) {
+				t.Errorf("jsn: %s\nraw: %s", jsn, rawjson)
+			}
+
+			rawyml, err := os.ReadFile(fn + ".yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromYAML, err := YAMLToTOML(rawyml)
+			if err != nil {
+				t.Fatal(err)
+			}
+			yml, err := TOMLToYAML(fromYAML)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expected, got map[string]any
+			if err := yaml.Unmarshal(rawyml, &expected); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal(yml, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("yaml: %#v\nexpected: %#v", got, expected)
+			}
+
+			rawhcl, err := os.ReadFile(fn + ".hcl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromHCL, err := HCLToTOML(rawhcl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hcl, err := TOMLToHCL(fromHCL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			jsn, err = HCLToJSON(hcl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want, have any
+			if err := json.Unmarshal(rawjson, &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(jsn, &have); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(have, want) {
+				t.Errorf("hcl: %s", jsn)
+			}
+		})
+	}
+}
+
+func TestTOMLConversionErrors(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		want  string
+	}{
+		{`[1, 2, 3]`, "table at the top level"},
+		{`"text"`, "table at the top level"},
+		{`{"a": null}`, "no null value, at a"},
+		{`{"a": {"b": [1, null]}}`, "no null value, at a.b"},
+	} {
+		_, err := JSONToTOML([]byte(tc.input))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q, got %v", tc.input, tc.want, err)
+		}
+	}
+
+	// TOML integers are 64-bit
+	if _, err := HCLToTOML([]byte("c = 1234567890123456789012345678901234567890\n")); err == nil || !strings.Contains(err.Error(), "integer out of range, at c") {
+		t.Errorf("expected an out of range error, got %v", err)
+	}
+	bs, err := tomlMarshal(map[string]any{"a": big.NewInt(5), "b": big.NewFloat(1.5)})
+	if err != nil || string(bs) != "a = 5\nb = 1.5\n" {
+		t.Errorf("got %q, %v", bs, err)
+	}
+
+	if _, err := YAMLToTOML([]byte("- a\n- b\n")); err == nil {
+		t.Errorf("expected an error for a YAML list")
+	}
+	if _, err := TOMLToJSON(nil); err == nil {
+		t.Errorf("expected an error for empty input")
+	}
+}
@@ -0,0 +1,39 @@
+package dethcl
+
+// Alias registers alias as another class name for the type registered in ref
+// under name, so that specs can use the vocabulary of the configuration rather
+// than Go type names. The ref map must not be nil.
+//
+// When a spec names a class, an alias takes precedence over a type registered
+// directly under the same name. An alias may refer to another alias.
+//
+// Example:
+//
+//	ref := map[string]any{"PostgresDB": &PostgresDB{}}
+//	dethcl.Alias(ref, "pg", "PostgresDB")
+//	spec, _ := schema.NewStruct("Config", map[string]any{"Database": "pg"})
+//	err := dethcl.UnmarshalSpec(hclBytes, &cfg, spec, ref)
+func Alias(ref map[string]any, alias, name string) {
+	aliases, ok := ref[contextKeyAliases].(map[string]string)
+	if !ok {
+		aliases = make(map[string]string)
+		ref[contextKeyAliases] = aliases
+	}
+	aliases[alias] = name
+}
+
+// lookupType returns the zero-value instance registered in ref for className,
+// resolving aliases first. It returns nil if there is none.
+func lookupType(ref map[string]any, className string) any {
+	if aliases, ok := ref[contextKeyAliases].(map[string]string); ok {
+		// the bound stops alias cycles
+		for i := 0; i <= len(aliases); i++ {
+			name, ok := aliases[className]
+			if !ok {
+				break
+			}
+			className = name
+		}
+	}
+	return ref[className]
+}
@@ -0,0 +1,52 @@
+package dethcl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/OpenUdon/schema"
+)
+
+func TestAlias(t *testing.T) {
+	hclData := []byte(`
+name = "drawing"
+shape {
+  radius = 2
+}
+`)
+	spec, err := schema.NewStruct("geo", map[string]any{"Shape": "round"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref := map[string]any{"circle": new(circle), "square": new(square)}
+	Alias(ref, "round", "circle")
+	g := new(geo)
+	if err := UnmarshalSpec(hclData, g, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.Shape, &circle{Radius: 2}) {
+		t.Errorf("got %#v", g.Shape)
+	}
+
+	// an alias wins over a type registered under the same name, and may
+	// refer to another alias
+	ref = map[string]any{"circle": new(circle), "round": new(square)}
+	Alias(ref, "round", "disk")
+	Alias(ref, "disk", "circle")
+	g = new(geo)
+	if err := UnmarshalSpec(hclData, g, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.Shape, &circle{Radius: 2}) {
+		t.Errorf("got %#v", g.Shape)
+	}
+
+	// cycles end without finding a type
+	ref = map[string]any{"circle": new(circle)}
+	Alias(ref, "round", "disk")
+	Alias(ref, "disk", "round")
+	if err := UnmarshalSpec(hclData, new(geo), spec, ref); err == nil {
+		t.Error("expected error for alias cycle")
+	}
+}
@@ -0,0 +1,218 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/OpenUdon/schema"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// UnmarshalAuto decodes HCL data like UnmarshalSpec, building the spec and
+// the ref map itself. implementations maps the name of each interface to its
+// concrete types, e.g.
+//
+//	err := UnmarshalAuto(hclData, &geo, map[string][]any{
+//	    "Shape": {&Circle{}},
+//	})
+//
+// Struct types reachable from current are registered like UnmarshalSpec does.
+// A field of an interface with a single implementation, or a slice or map of
+// it, is decoded into that implementation, at any depth. Interfaces with
+// several implementations get no spec entry: a block of theirs needs a
+// typefield or _type attribute, a directive or a default from
+// RegisterDefault naming its class, or UnmarshalAuto returns an error, e.g.
+// field Shape: interface Shape is ambiguous: 2 implementations. Use
+// UnmarshalSpec with a spec otherwise.
+func UnmarshalAuto(hclData []byte, current any, implementations map[string][]any) error {
+	if current == nil {
+		return nil
+	}
+	spec, err := autoSpec(reflect.TypeOf(current), implementations)
+	if err != nil {
+		return err
+	}
+	ref := collectStructTypesFromObject(current, implementations)
+	ambiguous := make(map[string]int)
+	for name, impls := range implementations {
+		if len(impls) > 1 {
+			ambiguous[name] = len(impls)
+		}
+	}
+	if len(ambiguous) > 0 {
+		ref[contextKeyAmbiguous] = ambiguous
+	}
+	return UnmarshalSpec(hclData, current, spec, ref)
+}
+
+// checkAmbiguous returns an error for a block of bd given to an interface
+// field of structType that has several implementations in UnmarshalAuto, if
+// neither objectMap, filled from the spec and the typed blocks, nor a default
+// from RegisterDefault names its class.
+func checkAmbiguous(structType reflect.Type, bd *hclsyntax.Body, objectMap map[string]*schema.Value, ref map[string]any) error {
+	ambiguous, _ := ref[contextKeyAmbiguous].(map[string]int)
+	if ambiguous == nil {
+		return nil
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if _, ok := objectMap[field.Name]; ok {
+			continue
+		}
+		typ := field.Type
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch typ.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			typ = typ.Elem()
+		default:
+		}
+		n := ambiguous[typ.Name()]
+		if typ.Kind() != reflect.Interface || n == 0 {
+			continue
+		}
+		if _, ok := defaultImpls.Load(typ); ok {
+			continue
+		}
+		name, _ := HCLName(field)
+		for _, block := range bd.Blocks {
+			if block.Type == name {
+				return fmt.Errorf("field %s: interface %s is ambiguous: %d implementations; name the class of block %s at %s with a %s attribute", field.Name, typ.Name(), n, block.Type, block.DefRange(), discriminator(ref))
+			}
+		}
+	}
+	return nil
+}
+
+// autoSpec returns the spec of the struct typ, or of the struct it points
+// to, for the interface fields resolved by implementations. It returns nil
+// if no field needs one.
+func autoSpec(typ reflect.Type, implementations map[string][]any) (*schema.Struct, error) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	fields := autoFieldSpecs(typ, implementations, map[reflect.Type]bool{})
+	if fields == nil {
+		return nil, nil
+	}
+	return schema.NewStruct(typ.Name(), fields)
+}
+
+// autoFieldSpecs returns the spec entries of the fields of the struct typ,
+// keyed by field name, or nil if there are none. Types in visiting are being
+// described already, which stops recursive types.
+func autoFieldSpecs(typ reflect.Type, implementations map[string][]any, visiting map[reflect.Type]bool) map[string]any {
+	if visiting[typ] {
+		return nil
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+
+	var fields map[string]any
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() || isCommentField(field) {
+			continue
+		}
+		tagName := parseHCLTag(field.Tag)[0]
+		if tagName == tagIgnore || (len(tagName) >= 2 && tagName[len(tagName)-2:] == tagIgnoreSuffix) {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		// an embedded struct shares the fields of its parent
+		if field.Anonymous && tagName == "" && fieldType.Kind() == reflect.Struct {
+			for name, value := range autoFieldSpecs(fieldType, implementations, visiting) {
+				if fields == nil {
+					fields = make(map[string]any)
+				}
+				fields[name] = value
+			}
+			continue
+		}
+		value, ok := autoValueSpec(fieldType, implementations, visiting)
+		if !ok {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]any)
+		}
+		fields[field.Name] = value
+	}
+	return fields
+}
+
+// autoValueSpec returns the spec entry of a field of type typ, in a form
+// accepted by schema.NewValue. A slice or map gets a single entry, which
+// applies to all of its blocks.
+func autoValueSpec(typ reflect.Type, implementations map[string][]any, visiting map[reflect.Type]bool) (any, bool) {
+	switch typ.Kind() {
+	case reflect.Slice:
+		if spec, ok := autoClassSpec(typ.Elem(), implementations, visiting); ok {
+			return [][2]any{spec}, true
+		}
+	case reflect.Map:
+		spec, ok := autoClassSpec(typ.Elem(), implementations, visiting)
+		if !ok {
+			break
+		}
+		if typ.Key().Kind() == reflect.Array && typ.Key().Len() == 2 {
+			return map[[2]string][2]any{{"", ""}: spec}, true
+		}
+		return map[string][2]any{"": spec}, true
+	default:
+		return autoClassSpec(typ, implementations, visiting)
+	}
+	return nil, false
+}
+
+// autoClassSpec returns the class name of typ, or of its single
+// implementation if typ is an interface, along with the spec entries of its
+// fields. It returns false for a struct that needs no entries, as its type
+// is found anyway, and for an interface without exactly one implementation.
+func autoClassSpec(typ reflect.Type, implementations map[string][]any, visiting map[reflect.Type]bool) ([2]any, bool) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var spec [2]any
+	switch {
+	case typ.Kind() == reflect.Interface:
+		impls := implementations[typ.Name()]
+		if len(impls) != 1 {
+			return spec, false
+		}
+		implType := reflect.TypeOf(impls[0])
+		if implType == nil {
+			return spec, false
+		}
+		if implType.Kind() == reflect.Pointer {
+			implType = implType.Elem()
+		}
+		spec[0] = implType.Name()
+		if implType.Kind() == reflect.Struct {
+			if fields := autoFieldSpecs(implType, implementations, visiting); fields != nil {
+				spec[1] = fields
+			}
+		}
+		return spec, true
+	case typ.Kind() == reflect.Struct && !isTimeType(typ) && !isTextType(typ):
+		fields := autoFieldSpecs(typ, implementations, visiting)
+		if fields == nil {
+			return spec, false
+		}
+		spec[0] = typ.Name()
+		spec[1] = fields
+		return spec, true
+	default:
+		return spec, false
+	}
+}
@@ -0,0 +1,116 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+)
+
+type autoFrame struct {
+	Title   string              `hcl:"title"`
+	Main    inter               `hcl:"main,block"`
+	Layers  []inter             `hcl:"layer,block"`
+	Named   map[string]inter    `hcl:"named,block"`
+	Pairs   map[[2]string]inter `hcl:"pair,block"`
+	Inset   *geo                `hcl:"inset,block"`
+	Gallery map[string]*geo     `hcl:"gallery,block"`
+}
+
+func TestUnmarshalAuto(t *testing.T) {
+	data := `title = "auto"
+main {
+  sx = 1
+  sy = 2
+}
+layer {
+  sx = 3
+  sy = 4
+}
+layer {
+  sx = 5
+  sy = 6
+}
+named "a" {
+  sx = 7
+  sy = 8
+}
+pair "b" "c" {
+  sx = 9
+  sy = 10
+}
+inset {
+  name = "inner"
+  shape {
+    sx = 11
+    sy = 12
+  }
+}
+gallery "north" {
+  name = "far"
+  shape {
+    sx = 13
+    sy = 14
+  }
+}
+`
+	var frame autoFrame
+	if err := UnmarshalAuto([]byte(data), &frame, map[string][]any{"inter": {new(square)}}); err != nil {
+		t.Fatal(err)
+	}
+	area := func(s inter) float32 {
+		if _, ok := s.(*square); !ok {
+			t.Fatalf("got %#v", s)
+		}
+		return s.Area()
+	}
+	if frame.Title != "auto" || area(frame.Main) != 2 || len(frame.Layers) != 2 || area(frame.Layers[1]) != 30 {
+		t.Errorf("got %#v", frame)
+	}
+	if area(frame.Named["a"]) != 56 || area(frame.Pairs[[2]string{"b", "c"}]) != 90 {
+		t.Errorf("got maps %#v and %#v", frame.Named, frame.Pairs)
+	}
+	if frame.Inset == nil || frame.Inset.Name != "inner" || area(frame.Inset.Shape) != 132 {
+		t.Errorf("got inset %#v", frame.Inset)
+	}
+	if g := frame.Gallery["north"]; g == nil || g.Name != "far" || area(g.Shape) != 182 {
+		t.Errorf("got gallery %#v", frame.Gallery)
+	}
+
+	// without a single implementation, the block needs its class named
+	var g geo
+	two := map[string][]any{"inter": {new(square), new(circle)}}
+	err := UnmarshalAuto([]byte("name = \"x\"\nshape {\n  radius = 1\n}"), &g, two)
+	if err == nil || !strings.Contains(err.Error(), "field Shape: interface inter is ambiguous: 2 implementations") {
+		t.Errorf("expected an ambiguity error, got %#v, %v", g, err)
+	}
+	g = geo{}
+	err = UnmarshalAuto([]byte("name = \"x\"\nshape {\n  _type = \"circle\"\n  radius = 1\n}"), &g, two)
+	if c, ok := g.Shape.(*circle); err != nil || !ok || c.Radius != 1 {
+		t.Errorf("got %#v, %v", g, err)
+	}
+	g = geo{}
+	if err := UnmarshalAuto([]byte("name = \"x\""), &g, two); err != nil || g.Name != "x" {
+		t.Errorf("got %#v, %v", g, err)
+	}
+	// figure has a default implementation
+	var drawing struct {
+		Figure figure `hcl:"figure,block"`
+	}
+	err = UnmarshalAuto([]byte("figure {\n  radius = 3\n}"), &drawing, map[string][]any{"figure": {new(square), new(circle)}})
+	if c, ok := drawing.Figure.(*circle); err != nil || !ok || c.Radius != 3 {
+		t.Errorf("got %#v, %v", drawing.Figure, err)
+	}
+
+	// structs without interfaces need no implementations
+	var plain struct {
+		Y7 *X7 `hcl:"y7,block"`
+	}
+	if err := UnmarshalAuto([]byte("y7 {\n  many = 3\n}"), &plain, nil); err != nil || plain.Y7 == nil || plain.Y7.Many != 3 {
+		t.Errorf("got %#v, %v", plain.Y7, err)
+	}
+	if err := UnmarshalAuto([]byte(`a = 1`), nil, nil); err != nil {
+		t.Error(err)
+	}
+	if err := UnmarshalAuto([]byte(`title = `), &autoFrame{}, nil); err == nil || !strings.Contains(err.Error(), "failed to parse") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package dethcl
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+)
+
+// isBytesType reports whether typ is a slice or an array of bytes, e.g.
+// []byte or [32]byte, which are written as base64 strings rather than as
+// lists of numbers.
+func isBytesType(typ reflect.Type) bool {
+	return (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) && typ.Elem().Kind() == reflect.Uint8
+}
+
+// marshalBytes returns v, a slice or an array of bytes, in standard base64.
+func marshalBytes(v reflect.Value) string {
+	bs := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(bs), v)
+	return base64.StdEncoding.EncodeToString(bs)
+}
+
+// parseBytes decodes the base64 string s into a value of typ, a slice or an
+// array of bytes. An array needs exactly as many bytes as its length.
+func parseBytes(s string, typ reflect.Type) (reflect.Value, error) {
+	bs, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("invalid base64 for %v: %w", typ, err)
+	}
+	if typ.Kind() == reflect.Slice {
+		return reflect.ValueOf(bs).Convert(typ), nil
+	}
+	if len(bs) != typ.Len() {
+		return reflect.Value{}, fmt.Errorf("expected %d bytes for %v, got %d", typ.Len(), typ, len(bs))
+	}
+	v := reflect.New(typ).Elem()
+	reflect.Copy(v, reflect.ValueOf(bs))
+	return v, nil
+}
@@ -0,0 +1,59 @@
+package dethcl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type blob []byte
+
+type keyring struct {
+	Data  []byte   `hcl:"data"`
+	Key   [32]byte `hcl:"key"`
+	Nonce *[4]byte `hcl:"nonce,optional"`
+	Blob  blob     `hcl:"blob,optional"`
+}
+
+func TestMarshalBytes(t *testing.T) {
+	k := &keyring{
+		Data:  []byte("hello, world"),
+		Nonce: &[4]byte{1, 2, 3, 4},
+		Blob:  blob{0xff, 0x00},
+	}
+	for i := range k.Key {
+		k.Key[i] = byte(i)
+	}
+	bs, err := Marshal(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(bs)
+	for _, line := range []string{`data  = "aGVsbG8sIHdvcmxk"`, `key   = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="`, `nonce = "AQIDBA=="`, `blob  = "/wA="`} {
+		if !strings.Contains(got, line) {
+			t.Errorf("missing %q in\n%s", line, got)
+		}
+	}
+
+	back := new(keyring)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back.Data, k.Data) || back.Key != k.Key || back.Nonce == nil || *back.Nonce != *k.Nonce || !bytes.Equal(back.Blob, k.Blob) {
+		t.Errorf("got %#v", back)
+	}
+
+	for _, tc := range []struct {
+		data string
+		want string
+	}{
+		{`data = "AA=="` + "\n" + `key = "AAEC"`, "expected 32 bytes for [32]uint8, got 3"},
+		{`data = "not base64!"` + "\n" + `key = "AAEC"`, "invalid base64"},
+		{`data = [1, 2]` + "\n" + `key = "AAEC"`, "expected a string"},
+	} {
+		err := Unmarshal([]byte(tc.data), new(keyring))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q, got %v", tc.data, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package dethcl
+
+import (
+	"github.com/OpenUdon/schema"
+	"google.golang.org/protobuf/proto"
+)
+
+// CloneSpec returns a deep copy of spec, including the specs of its fields
+// at any depth, so that a base spec can be tweaked per request without
+// changing the shared one:
+//
+//	spec := dethcl.CloneSpec(base)
+//	spec.Fields["Shape"], err = schema.NewValue("Square")
+//
+// Decoding never changes the spec it is given, so a spec used as is needs
+// no copy. Returns nil for a nil spec.
+func CloneSpec(spec *schema.Struct) *schema.Struct {
+	if spec == nil {
+		return nil
+	}
+	return proto.Clone(spec).(*schema.Struct)
+}
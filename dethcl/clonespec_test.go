@@ -0,0 +1,54 @@
+package dethcl
+
+import (
+	"testing"
+
+	"github.com/OpenUdon/schema"
+)
+
+func TestCloneSpec(t *testing.T) {
+	if CloneSpec(nil) != nil {
+		t.Error("expected nil for a nil spec")
+	}
+	base, err := schema.NewStruct("picture", map[string]any{
+		"Drawings": []string{"circle", "square"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]any{"circle": new(circle), "square": new(square)}
+	want := DescribeSpec(base)
+
+	spec := CloneSpec(base)
+	if got := DescribeSpec(spec); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	spec.Fields["Drawings"].GetListStruct().ListFields[0].ClassName = "square"
+	if got := DescribeSpec(base); got != want {
+		t.Errorf("base changed to %s", got)
+	}
+
+	// decoding with the copy leaves both specs as they are
+	p := new(picture)
+	if err := UnmarshalSpec([]byte(`name = "p"
+drawings {
+  sx = 1
+  sy = 2
+}
+drawings {
+  sx = 3
+  sy = 4
+}
+`), p, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := p.Drawings[0].(*square); !ok || s.SX != 1 {
+		t.Errorf("got %#v", p.Drawings)
+	}
+	if got := DescribeSpec(base); got != want {
+		t.Errorf("base changed to %s", got)
+	}
+	if got := DescribeSpec(spec); got != "picture { Drawings: [square] }" {
+		t.Errorf("spec changed to %s", got)
+	}
+}
@@ -1,10 +1,57 @@
 package dethcl
 
 import (
+	"fmt"
 	"reflect"
 	"strings"
 )
 
+// NewRef returns a ref map holding each of samples, usually pointers to zero
+// structs, under its short type name and its package-qualified name, e.g.
+// "Circle" and "shapes.Circle":
+//
+//	ref := dethcl.NewRef(&Circle{}, &Square{}, &Geo{})
+//
+// A struct value is registered as a pointer to a copy. Passing the same type
+// twice is a no-op; NewRef panics if two distinct types share a name, or if a
+// sample is not a named struct or a pointer to one, like Register does.
+func NewRef(samples ...any) map[string]any {
+	ref := make(map[string]any, 2*len(samples))
+	for _, sample := range samples {
+		typ := reflect.TypeOf(sample)
+		if typ != nil && typ.Kind() == reflect.Struct {
+			ptr := reflect.New(typ)
+			ptr.Elem().Set(reflect.ValueOf(sample))
+			sample = ptr.Interface()
+			typ = ptr.Type()
+		}
+		if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct || typ.Elem().Name() == "" {
+			panic(fmt.Sprintf("dethcl: NewRef: %T is not a named struct or a pointer to one", sample))
+		}
+		for _, name := range typeNames(typ.Elem()) {
+			if old, ok := ref[name]; ok {
+				if reflect.TypeOf(old) != typ {
+					panic(fmt.Sprintf("dethcl: NewRef: %q is registered for %T, not %T", name, old, sample))
+				}
+				continue
+			}
+			ref[name] = sample
+		}
+	}
+	return ref
+}
+
+// typeNames returns the short name of the named type t, e.g. "Config", and
+// its package-qualified name, e.g. "cell.Config", if it has a package.
+func typeNames(t reflect.Type) []string {
+	names := []string{t.Name()}
+	if pkgPath := t.PkgPath(); pkgPath != "" {
+		parts := strings.Split(pkgPath, "/")
+		names = append(names, parts[len(parts)-1]+"."+t.Name())
+	}
+	return names
+}
+
 // collectStructTypesFromObject returns a type registry for unmarshaling any struct.
 // It discovers all struct types by traversing the object's fields recursively.
 // The implementations map provides concrete types for interface fields, mapping
@@ -62,11 +109,9 @@ func collectStructTypes(t reflect.Type, ref map[string]any, implementations map[
 		return
 	}
 	obj := reflect.New(t).Interface()
-	ref[name] = obj
-	// Add package-qualified name (e.g., "cell.Config")
-	if pkgPath := t.PkgPath(); pkgPath != "" {
-		parts := strings.Split(pkgPath, "/")
-		ref[parts[len(parts)-1]+"."+name] = obj
+	// Add the short and package-qualified names (e.g., "cell.Config")
+	for _, typeName := range typeNames(t) {
+		ref[typeName] = obj
 	}
 
 	// Recurse into fields
@@ -2,6 +2,8 @@ package dethcl
 
 import (
 	"testing"
+
+	"github.com/OpenUdon/schema"
 )
 
 // Test types for collectStructTypesFromObject
@@ -121,3 +123,46 @@ func Test_collectStructTypesFromObject(t *testing.T) {
 		}
 	})
 }
+
+func TestNewRef(t *testing.T) {
+	circle := &testCircle{}
+	ref := NewRef(circle, testSquare{Side: 2}, &testGeo{}, circle)
+	if len(ref) != 6 {
+		t.Errorf("expected 6 names, got %v", ref)
+	}
+	if ref["testCircle"] != circle || ref["dethcl.testCircle"] != circle {
+		t.Errorf("got %#v", ref)
+	}
+	if sq, ok := ref["testSquare"].(*testSquare); !ok || sq.Side != 2 || ref["dethcl.testSquare"] != ref["testSquare"] {
+		t.Errorf("got %#v", ref["testSquare"])
+	}
+
+	spec, err := schema.NewStruct("testGeo", map[string]any{"Shape": "testCircle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var geo testGeo
+	if err := UnmarshalSpec([]byte("name = \"g\"\nshape {\n  radius = 2\n}"), &geo, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := geo.Shape.(*testCircle); !ok || c.Radius != 2 {
+		t.Errorf("got %#v", geo.Shape)
+	}
+
+	expectPanic := func(name string, samples ...any) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: expected a panic", name)
+			}
+		}()
+		NewRef(samples...)
+	}
+	type testCircle struct {
+		Diameter float64 `hcl:"diameter"`
+	}
+	expectPanic("collision", &testCircle{}, circle)
+	expectPanic("nil", nil)
+	expectPanic("not a struct", new(int))
+	expectPanic("unnamed", &struct{}{})
+}
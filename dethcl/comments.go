@@ -0,0 +1,159 @@
+package dethcl
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// commentsType is the type of a field tagged `hcl:",comment"`.
+var commentsType = reflect.TypeOf(map[string]string(nil))
+
+// isCommentField reports whether field is tagged comment, e.g.
+//
+//	Comments map[string]string `hcl:",comment"`
+func isCommentField(field reflect.StructField) bool {
+	return hasTagOption(parseHCLTag(field.Tag)[1], tagModifierComment)
+}
+
+// processCommentField sets the comment field of structType, if any, in
+// oriTobe to the comments above the attributes and blocks of body, parsed
+// from hclData. Fields of another type than map[string]string are an error.
+func processCommentField(structType reflect.Type, oriTobe reflect.Value, hclData []byte, body *hclsyntax.Body) error {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() || !isCommentField(field) {
+			continue
+		}
+		if field.Type != commentsType {
+			return fmt.Errorf("comment field %s must be map[string]string, got %v", field.Name, field.Type)
+		}
+		if comments := leadingComments(hclData, body); comments != nil {
+			oriTobe.Elem().Field(i).Set(reflect.ValueOf(comments))
+		}
+		return nil
+	}
+	return nil
+}
+
+// leadingComments returns the comments on the lines right above the
+// attributes and blocks of body, keyed by attribute name or block type; for
+// several blocks of a type, the first one with a comment counts. hclsyntax
+// drops comments when parsing, so they are read from the tokens of hclData.
+func leadingComments(hclData []byte, body *hclsyntax.Body) map[string]string {
+	tokens, starts := commentTokens(hclData)
+	var comments map[string]string
+	add := func(name string, start int) {
+		i, ok := starts[start]
+		if !ok {
+			return
+		}
+		if _, ok := comments[name]; ok {
+			return
+		}
+		if text := commentAbove(tokens, i); text != "" {
+			if comments == nil {
+				comments = make(map[string]string)
+			}
+			comments[name] = text
+		}
+	}
+	for name, attr := range body.Attributes {
+		add(name, attr.SrcRange.Start.Byte)
+	}
+	for _, block := range body.Blocks {
+		add(block.Type, block.TypeRange.Start.Byte)
+	}
+	return comments
+}
+
+// commentTokens lexes hclData, keeping the comments, and indexes the tokens
+// by their start byte.
+func commentTokens(hclData []byte) (hclsyntax.Tokens, map[int]int) {
+	tokens, _ := hclsyntax.LexConfig(hclData, "", hcl.Pos{Line: 1, Column: 1})
+	starts := make(map[int]int, len(tokens))
+	for i, token := range tokens {
+		starts[token.Range.Start.Byte] = i
+	}
+	return tokens, starts
+}
+
+// commentAbove returns the text of the comment tokens right before the
+// token at i, one line each without the comment markers. A comment trailing
+// the previous attribute, e.g. port = 80 # web, is not included.
+func commentAbove(tokens hclsyntax.Tokens, i int) string {
+	start := i
+	for start > 0 && (tokens[start-1].Type == hclsyntax.TokenComment || blockCommentEnd(tokens, start-1)) {
+		start--
+	}
+	// a comment after other tokens trails the line before, e.g. port = 80 # web
+	if start > 0 && start < i && tokens[start-1].Type != hclsyntax.TokenNewline && tokens[start-1].Type != hclsyntax.TokenOBrace {
+		start++
+	}
+	var lines []string
+	for _, token := range tokens[start:i] {
+		if token.Type == hclsyntax.TokenComment {
+			lines = append(lines, commentLines(string(token.Bytes))...)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// blockCommentEnd reports whether tokens[k] is the newline after a /* */
+// comment, which unlike a line comment does not hold its newline.
+func blockCommentEnd(tokens hclsyntax.Tokens, k int) bool {
+	return tokens[k].Type == hclsyntax.TokenNewline && k > 0 && tokens[k-1].Type == hclsyntax.TokenComment && !bytes.HasSuffix(tokens[k-1].Bytes, []byte("\n"))
+}
+
+// commentLines strips the markers of a #, // or /* */ comment.
+func commentLines(text string) []string {
+	text = strings.TrimSpace(text)
+	if block, ok := strings.CutPrefix(text, "/*"); ok {
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSuffix(block, "*/"), "\n") {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*")))
+		}
+		return lines
+	}
+	if line, ok := strings.CutPrefix(text, "#"); ok {
+		return []string{strings.TrimSpace(line)}
+	}
+	return []string{strings.TrimSpace(strings.TrimPrefix(text, "//"))}
+}
+
+// sourceComments is the Commenter of a struct with a comment field, giving
+// back the comments read by Unmarshal above the same attributes and blocks.
+type sourceComments struct {
+	comments   map[string]string
+	structType reflect.Type
+}
+
+// HCLComment implements Commenter.
+func (s sourceComments) HCLComment(fieldName string) string {
+	field, ok := s.structType.FieldByName(fieldName)
+	if !ok {
+		return ""
+	}
+	name, _ := HCLName(field)
+	return s.comments[name]
+}
+
+// commentsOf returns the Commenter of the comment field of structValue, or
+// nil if it has none or the field is empty.
+func commentsOf(structValue reflect.Value) Commenter {
+	structType := structValue.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.IsExported() && isCommentField(field) && field.Type == commentsType {
+			if comments := structValue.Field(i).Interface().(map[string]string); len(comments) > 0 {
+				return sourceComments{comments: comments, structType: structType}
+			}
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package dethcl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type commentedService struct {
+	Image    string            `hcl:"image"`
+	Port     int               `hcl:"port"`
+	Comments map[string]string `hcl:",comment"`
+}
+
+type commentedConfig struct {
+	Name     string                       `hcl:"name"`
+	Services map[string]*commentedService `hcl:"service,block"`
+	Comments map[string]string            `hcl:",comment"`
+}
+
+func TestUnmarshalComments(t *testing.T) {
+	data := `# application name
+// shown in logs
+name = "app"
+
+# not attached
+
+# the public API
+service "api" {
+  /* image
+     to run */
+  image = "nginx" # trailing
+  port  = 80
+}
+`
+	cfg := new(commentedConfig)
+	if err := Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"name": "application name\nshown in logs", "service": "the public API"}
+	if !reflect.DeepEqual(cfg.Comments, expected) {
+		t.Errorf("got %#v", cfg.Comments)
+	}
+	api := cfg.Services["api"]
+	if api == nil || !reflect.DeepEqual(api.Comments, map[string]string{"image": "image\nto run"}) {
+		t.Fatalf("got %#v", api)
+	}
+
+	bs, err := MarshalBody(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# application name\n# shown in logs\nname = \"app\"",
+		"# the public API\nservice \"api\" {",
+		"  # image\n  # to run\n  image = \"nginx\"",
+	} {
+		if !strings.Contains(string(bs), want) {
+			t.Errorf("missing %q in\n%s", want, bs)
+		}
+	}
+
+	back := new(commentedConfig)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, cfg) {
+		t.Errorf("round trip: %#v", back)
+	}
+
+	if err := UnmarshalStrict([]byte(data), new(commentedConfig)); err != nil {
+		t.Errorf("strict: %v", err)
+	}
+}
+
+func TestUnmarshalCommentsType(t *testing.T) {
+	type config struct {
+		Name     string `hcl:"name"`
+		Comments string `hcl:",comment"`
+	}
+	err := Unmarshal([]byte("# x\nname = \"a\"\n"), new(config))
+	if err == nil || !strings.Contains(err.Error(), "map[string]string") {
+		t.Errorf("expected a type error, got %v", err)
+	}
+}
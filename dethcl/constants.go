@@ -17,6 +17,71 @@ const (
 	// tagModifierOptional indicates a field is optional
 	tagModifierOptional = "optional"
 
+	// tagModifierTrim trims surrounding whitespace from a decoded string field
+	tagModifierTrim = "trim"
+
+	// tagModifierExplicitNull writes `name = null` for a nil pointer field
+	// instead of omitting it
+	tagModifierExplicitNull = "explicitnull"
+
+	// tagModifierRequired makes Marshal fail on a nil pointer field instead
+	// of omitting it
+	tagModifierRequired = "required"
+
+	// tagModifierRepeated writes a slice of primitives as one block per
+	// element, e.g. tag { value = "a" }, instead of a list attribute
+	tagModifierRepeated = "repeated"
+
+	// tagModifierCollapse writes a map of structs as one object attribute,
+	// service = { api = { ... } }, instead of a labeled block per entry
+	tagModifierCollapse = "collapse"
+
+	// tagModifierSensitive marks a field holding a secret, which
+	// MarshalOptions.RedactSensitive replaces by redactedValue and
+	// SensitiveFields reports after decoding
+	tagModifierSensitive = "sensitive"
+
+	// tagModifierOmitEmpty leaves a field out of Marshal's output when it
+	// holds the zero value or an empty slice or map; otherwise it acts
+	// like tagModifierOptional
+	tagModifierOmitEmpty = "omitempty"
+
+	// tagModifierComment marks a map[string]string field that Unmarshal fills
+	// with the comments above attributes and blocks, and Marshal writes back
+	tagModifierComment = "comment"
+
+	// inlineSliceWidth is the widest line, with indentation, of a list written
+	// on one line by MarshalOptions.InlinePrimitiveSlices
+	inlineSliceWidth = 80
+
+	// redactedValue is written in place of a redacted sensitive field
+	redactedValue = "(sensitive)"
+
+	// repeatedValueAttr is the attribute holding the element in the blocks of
+	// a repeated field
+	repeatedValueAttr = "value"
+
+	// tagOptionMapKey names the object attribute used as map key when a list of
+	// objects is decoded into a map, e.g. `hcl:"entries,mapkey=key"`
+	tagOptionMapKey = "mapkey"
+
+	// tagOptionTypeField names the attribute of each block of an interface
+	// field that holds the class name of the block, e.g.
+	// `hcl:"steps,block,typefield=type"`
+	tagOptionTypeField = "typefield"
+
+	// tagKeyPrefix is the struct tag key of a string added in front of a string
+	// field value in HCL, e.g. `hcl:"path" hclprefix:"/etc/"`
+	tagKeyPrefix = "hclprefix"
+
+	// tagKeySuffix is the struct tag key of a string added after a string
+	// field value in HCL, e.g. `hcl:"file" hclsuffix:".conf"`
+	tagKeySuffix = "hclsuffix"
+
+	// tagKeyTime is the struct tag key of the layout of a time.Time field,
+	// e.g. `hcl:"date" hcltime:"2006-01-02"`
+	tagKeyTime = "hcltime"
+
 	// tagIgnore indicates a field should be ignored
 	tagIgnore = "-"
 
@@ -31,6 +96,39 @@ const (
 	// This marker is intentionally cryptic to avoid collision with user data.
 	// It's only used internally and never appears in output.
 	markerNoBrackets = "__DETHCL_NO_BRACKETS_MARKER__"
+
+	// contextKeyDecodeState is the ref key holding the *decodeState of one
+	// Unmarshal call. Like markerNoBrackets it cannot collide with type names.
+	contextKeyDecodeState = "__DETHCL_DECODE_STATE__"
+
+	// contextKeyAliases is the ref key holding the map[string]string of type
+	// aliases registered with Alias
+	contextKeyAliases = "__DETHCL_ALIASES__"
+
+	// contextKeyAmbiguous is the ref key holding the map[string]int of the
+	// interfaces given several implementations to UnmarshalAuto, by name
+	contextKeyAmbiguous = "__DETHCL_AMBIGUOUS__"
+
+	// contextKeyDirectives is the ref key set by EnableDirectives
+	contextKeyDirectives = "__DETHCL_DIRECTIVES__"
+
+	// directivePrefix starts a comment directive, e.g. # dethcl:type=circle
+	directivePrefix = "dethcl:"
+
+	// directiveType names the class of the block below it
+	directiveType = "type"
+
+	// contextKeyDiscriminator is the ref key holding the attribute name set
+	// by SetDiscriminator
+	contextKeyDiscriminator = "__DETHCL_DISCRIMINATOR__"
+
+	// defaultDiscriminator is the attribute naming the class of a block of
+	// an interface field, e.g. _type = "circle"
+	defaultDiscriminator = "_type"
+
+	// defaultMaxDepth is the nesting depth allowed when MarshalOptions and
+	// UnmarshalOptions do not set MaxDepth
+	defaultMaxDepth = 1000
 )
 
 // File extension constants
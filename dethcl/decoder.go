@@ -0,0 +1,43 @@
+package dethcl
+
+import (
+	"io"
+
+	"github.com/OpenUdon/schema"
+)
+
+// Decoder reads an HCL document from an input stream, such as an HTTP request
+// body or an open file.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads r to the end and decodes it into v like Unmarshal. An empty
+// stream leaves v untouched and returns nil.
+func (d *Decoder) Decode(v any, labels ...string) error {
+	bs, err := d.read()
+	if err != nil || len(bs) == 0 {
+		return err
+	}
+	return Unmarshal(bs, v, labels...)
+}
+
+// DecodeSpec reads r to the end and decodes it into v like UnmarshalSpec, for
+// structs with interface fields. An empty stream leaves v untouched and
+// returns nil.
+func (d *Decoder) DecodeSpec(v any, spec *schema.Struct, ref map[string]any, labels ...string) error {
+	bs, err := d.read()
+	if err != nil || len(bs) == 0 {
+		return err
+	}
+	return UnmarshalSpec(bs, v, spec, ref, labels...)
+}
+
+func (d *Decoder) read() ([]byte, error) {
+	return io.ReadAll(d.r)
+}
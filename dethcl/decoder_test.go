@@ -0,0 +1,57 @@
+package dethcl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/OpenUdon/schema"
+)
+
+func TestDecoder(t *testing.T) {
+	type config struct {
+		Name string `hcl:"name"`
+		Port int    `hcl:"port,optional"`
+	}
+
+	var cfg config
+	if err := NewDecoder(strings.NewReader(`name = "api"
+port = 80`)).Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "api" || cfg.Port != 80 {
+		t.Errorf("got %#v", cfg)
+	}
+
+	if err := NewDecoder(strings.NewReader("")).Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "api" || cfg.Port != 80 {
+		t.Errorf("empty stream changed %#v", cfg)
+	}
+
+	failure := errors.New("connection reset")
+	if err := NewDecoder(iotest.ErrReader(failure)).Decode(&cfg); !errors.Is(err, failure) {
+		t.Errorf("expected the reader error, got %v", err)
+	}
+}
+
+func TestDecoderSpec(t *testing.T) {
+	spec, err := schema.NewStruct("geo", map[string]any{"Shape": "circle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]any{"circle": new(circle)}
+
+	g := new(geo)
+	if err := NewDecoder(strings.NewReader(`name = "peter"
+shape {
+  radius = 1.5
+}`)).DecodeSpec(g, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := g.Shape.(*circle); !ok || c.Radius != 1.5 || g.Name != "peter" {
+		t.Errorf("got %#v", g)
+	}
+}
@@ -0,0 +1,60 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// defaultImpls maps the reflect.Type of each interface registered with
+// RegisterDefault to the pointer type of its default implementation.
+var defaultImpls sync.Map
+
+// RegisterDefault registers impl, a pointer to a struct, as the concrete type
+// that Unmarshal decodes into a field of the interface type T when the spec
+// does not name one, e.g. a Shape field written as shape { radius = 1 }.
+// Slices and maps of T use it for their blocks as well.
+//
+// A spec entry for the field always wins over the default, so the common case
+// may omit the type and only the overrides need a spec. Only the type of impl
+// is used; the decoded value starts from its zero value.
+//
+// Registering T again replaces its default. RegisterDefault panics if T is not
+// an interface or impl is not a pointer to a struct implementing T.
+//
+// Example:
+//
+//	dethcl.RegisterDefault[Shape](&Circle{})
+//
+//	type Geo struct {
+//	    Shape Shape `hcl:"shape,block"`
+//	}
+//	// shape { radius = 1 } decodes into a *Circle
+func RegisterDefault[T any](impl T) {
+	iface := reflect.TypeOf((*T)(nil)).Elem()
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("dethcl: RegisterDefault: %v is not an interface", iface))
+	}
+	typ := reflect.TypeOf(impl)
+	if typ == nil || typ.Kind() != reflect.Pointer || typ.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("dethcl: RegisterDefault: default of %v must be a pointer to a struct, got %v", iface, typ))
+	}
+	defaultImpls.Store(iface, typ)
+}
+
+// defaultFor returns the class name of the default implementation of the
+// interface typ, registering a prototype under that name in ref. It returns
+// false if typ is not an interface with a default.
+func defaultFor(typ reflect.Type, ref map[string]any) (string, bool) {
+	if typ.Kind() != reflect.Interface {
+		return "", false
+	}
+	impl, ok := defaultImpls.Load(typ)
+	if !ok {
+		return "", false
+	}
+	implType := impl.(reflect.Type)
+	className := implType.Elem().String()
+	ref[className] = reflect.New(implType.Elem()).Interface()
+	return className, true
+}
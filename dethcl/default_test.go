@@ -0,0 +1,74 @@
+package dethcl
+
+import (
+	"testing"
+
+	"github.com/OpenUdon/schema"
+)
+
+// figure has a default implementation for the tests, unlike inter, so that
+// the registration does not change other tests.
+type figure interface {
+	Area() float32
+}
+
+func init() {
+	RegisterDefault[figure](&circle{})
+}
+
+func TestRegisterDefault(t *testing.T) {
+	type drawing struct {
+		Name    string            `hcl:"name"`
+		Figure  figure            `hcl:"figure,block"`
+		Figures []figure          `hcl:"figures,block,optional"`
+		Named   map[string]figure `hcl:"named,block,optional"`
+	}
+	data := `
+name = "d"
+figure {
+  radius = 2
+}
+figures {
+  radius = 3
+}
+named "x" {
+  radius = 4
+}
+`
+	var d drawing
+	if err := Unmarshal([]byte(data), &d); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := d.Figure.(*circle); !ok || c.Radius != 2 {
+		t.Errorf("figure: %#v", d.Figure)
+	}
+	if len(d.Figures) != 1 || d.Figures[0].(*circle).Radius != 3 {
+		t.Errorf("figures: %#v", d.Figures)
+	}
+	if c, ok := d.Named["x"].(*circle); !ok || c.Radius != 4 {
+		t.Errorf("named: %#v", d.Named)
+	}
+
+	// a spec entry wins over the default
+	spec, err := schema.NewStruct("drawing", map[string]any{"Figure": "square"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]any{"square": &square{}}
+	var s drawing
+	if err := UnmarshalSpec([]byte("name = \"s\"\nfigure {\n  sx = 2\n  sy = 3\n}\n"), &s, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if sq, ok := s.Figure.(*square); !ok || sq.SX != 2 || sq.SY != 3 {
+		t.Errorf("spec: %#v", s.Figure)
+	}
+}
+
+func TestRegisterDefaultPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a non-pointer default")
+		}
+	}()
+	RegisterDefault[any](circle{})
+}
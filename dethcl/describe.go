@@ -0,0 +1,127 @@
+package dethcl
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/OpenUdon/schema"
+)
+
+// DescribeSpec renders spec as a compact one-line tree, to check it against
+// the Go types before decoding, e.g.
+//
+//	Outer { Items: [Item], Nested: map[string]Config, Shape: Circle }
+//
+// Fields are listed in name order. A list or map whose entries use different
+// classes shows each entry, e.g. [Circle, Square] or map[string]{a: A, b: B};
+// a Map2Struct is shown as map[[2]string]Class with keys joined by a slash.
+// A service name follows the class after an @.
+func DescribeSpec(spec *schema.Struct) string {
+	if spec == nil {
+		return "<nil>"
+	}
+	var sb strings.Builder
+	describeStruct(&sb, spec)
+	return sb.String()
+}
+
+func describeStruct(sb *strings.Builder, spec *schema.Struct) {
+	sb.WriteString(spec.GetClassName())
+	if spec.GetServiceName() != "" {
+		sb.WriteString("@" + spec.GetServiceName())
+	}
+	fields := spec.GetFields()
+	if len(fields) == 0 {
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sb.WriteString(" { ")
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(name + ": ")
+		describeValue(sb, fields[name])
+	}
+	sb.WriteString(" }")
+}
+
+func describeValue(sb *strings.Builder, v *schema.Value) {
+	switch kind := v.GetKind().(type) {
+	case *schema.Value_SingleStruct:
+		describeStruct(sb, kind.SingleStruct)
+	case *schema.Value_ListStruct:
+		items := kind.ListStruct.GetListFields()
+		sb.WriteString("[")
+		if sameClass(items) && len(items) > 0 {
+			describeStruct(sb, items[0])
+		} else {
+			for i, item := range items {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				describeStruct(sb, item)
+			}
+		}
+		sb.WriteString("]")
+	case *schema.Value_MapStruct:
+		sb.WriteString("map[string]")
+		describeMap(sb, kind.MapStruct.GetMapFields())
+	case *schema.Value_Map2Struct:
+		sb.WriteString("map[[2]string]")
+		entries := make(map[string]*schema.Struct)
+		for k1, inner := range kind.Map2Struct.GetMap2Fields() {
+			for k2, item := range inner.GetMapFields() {
+				entries[k1+"/"+k2] = item
+			}
+		}
+		describeMap(sb, entries)
+	default:
+		sb.WriteString("<nil>")
+	}
+}
+
+// describeMap writes the single class of entries, or each key and class if
+// they differ.
+func describeMap(sb *strings.Builder, entries map[string]*schema.Struct) {
+	keys := make([]string, 0, len(entries))
+	items := make([]*schema.Struct, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		items = append(items, entries[k])
+	}
+	if len(items) > 0 && sameClass(items) {
+		describeStruct(sb, items[0])
+		return
+	}
+	sb.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(k + ": ")
+		describeStruct(sb, entries[k])
+	}
+	sb.WriteString("}")
+}
+
+// sameClass reports whether all items render the same.
+func sameClass(items []*schema.Struct) bool {
+	if len(items) < 2 {
+		return true
+	}
+	first := DescribeSpec(items[0])
+	for _, item := range items[1:] {
+		if DescribeSpec(item) != first {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package dethcl
+
+import (
+	"testing"
+
+	"github.com/OpenUdon/schema"
+)
+
+func TestDescribeSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]any
+		want   string
+	}{
+		{
+			name: "single list and map",
+			fields: map[string]any{
+				"Shape":  "Circle",
+				"Items":  []string{"Item", "Item"},
+				"Nested": map[string]string{"a": "Config", "b": "Config"},
+			},
+			want: "Outer { Items: [Item], Nested: map[string]Config, Shape: Circle }",
+		},
+		{
+			name: "mixed classes",
+			fields: map[string]any{
+				"Items":  []string{"Circle", "Square"},
+				"Nested": map[string]string{"a": "A", "b": "B"},
+			},
+			want: "Outer { Items: [Circle, Square], Nested: map[string]{a: A, b: B} }",
+		},
+		{
+			name: "map2",
+			fields: map[string]any{
+				"Grid": map[[2]string]string{{"x", "1"}: "Cell", {"y", "2"}: "Cell"},
+			},
+			want: "Outer { Grid: map[[2]string]Cell }",
+		},
+		{
+			name: "nested fields",
+			fields: map[string]any{
+				"Shape": [2]any{"Circle", map[string]any{"Center": "Point"}},
+			},
+			want: "Outer { Shape: Circle { Center: Point } }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := schema.NewStruct("Outer", tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := DescribeSpec(spec); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+
+	if got := DescribeSpec(nil); got != "<nil>" {
+		t.Errorf("nil spec: %s", got)
+	}
+}
@@ -0,0 +1,69 @@
+package dethcl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// EnableDirectives lets the data decoded with ref choose the class of an
+// interface block by a comment directive on the line above it:
+//
+//	# dethcl:type=circle
+//	shape {
+//	  radius = 2
+//	}
+//
+// The class is looked up in ref like the classes of a spec. A spec naming
+// the class of the field takes precedence, then the typefield attribute of
+// the block, then the directive. Directives are off by default, so that
+// comments cannot change the types of documents decoded without them. The
+// ref map must not be nil.
+func EnableDirectives(ref map[string]any) {
+	ref[contextKeyDirectives] = true
+}
+
+// directivesEnabled reports whether EnableDirectives was called on ref.
+func directivesEnabled(ref map[string]any) bool {
+	enabled, _ := ref[contextKeyDirectives].(bool)
+	return enabled
+}
+
+// blockDirectives returns the directives in the comment lines right above
+// each block of body, parsed from hclData, e.g. {"type": "circle"} for
+// # dethcl:type=circle. Lines without the dethcl: prefix are ordinary
+// comments; an unknown or malformed directive is an error.
+func blockDirectives(hclData []byte, body *hclsyntax.Body) (map[*hclsyntax.Block]map[string]string, error) {
+	var tokens hclsyntax.Tokens
+	var starts map[int]int
+	var directives map[*hclsyntax.Block]map[string]string
+	for _, block := range body.Blocks {
+		if tokens == nil {
+			tokens, starts = commentTokens(hclData)
+		}
+		i, ok := starts[block.TypeRange.Start.Byte]
+		if !ok {
+			continue
+		}
+		for _, line := range strings.Split(commentAbove(tokens, i), "\n") {
+			directive, ok := strings.CutPrefix(line, directivePrefix)
+			if !ok {
+				continue
+			}
+			key, value, found := strings.Cut(directive, "=")
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !found || key != directiveType || value == "" {
+				return nil, fmt.Errorf("block %s at %s: invalid directive %q", block.Type, block.DefRange(), line)
+			}
+			if directives == nil {
+				directives = make(map[*hclsyntax.Block]map[string]string)
+			}
+			if directives[block] == nil {
+				directives[block] = make(map[string]string)
+			}
+			directives[block][key] = value
+		}
+	}
+	return directives, nil
+}
@@ -0,0 +1,108 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OpenUdon/schema"
+)
+
+type directed struct {
+	Name   string           `hcl:"name"`
+	Shape  inter            `hcl:"shape,block"`
+	Shapes map[string]inter `hcl:"shapes,block"`
+	Steps  []step           `hcl:"step,block,typefield=type"`
+}
+
+func TestDirectives(t *testing.T) {
+	data := `name = "plan"
+
+# the main shape
+# dethcl:type=circle
+shape {
+  radius = 2
+}
+
+// dethcl:type=square
+shapes "a" {
+  sx = 2
+  sy = 3
+}
+/* dethcl:type=circle */
+shapes "b" {
+  radius = 1
+}
+
+# dethcl:type=shell
+step {
+  command = "make"
+}
+# dethcl:type=shell
+step {
+  type = "http"
+  url  = "https://example.com"
+}
+`
+	ref := map[string]any{"circle": new(circle), "square": new(square), "http": new(httpStep), "shell": new(shellStep)}
+	EnableDirectives(ref)
+	d := new(directed)
+	if err := UnmarshalSpec([]byte(data), d, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := d.Shape.(*circle); !ok || c.Radius != 2 {
+		t.Errorf("shape: %#v", d.Shape)
+	}
+	if s, ok := d.Shapes["a"].(*square); !ok || s.SX != 2 || s.SY != 3 {
+		t.Errorf("shapes: %#v", d.Shapes)
+	}
+	if _, ok := d.Shapes["b"].(*circle); !ok {
+		t.Errorf("shapes: %#v", d.Shapes)
+	}
+	// the type attribute takes precedence over the directive
+	if len(d.Steps) != 2 || d.Steps[0].Run() != "sh -c make" || d.Steps[1].Run() != "GET https://example.com" {
+		t.Errorf("steps: %#v", d.Steps)
+	}
+
+	// without EnableDirectives the comments are ignored
+	ref = map[string]any{"circle": new(circle), "square": new(square), "http": new(httpStep), "shell": new(shellStep)}
+	d = new(directed)
+	if err := UnmarshalSpec([]byte("# dethcl:type=circle\nshape {\n  radius = 2\n}\n"), d, nil, ref); err != nil || d.Shape != nil {
+		t.Errorf("without directives got %#v, %v", d.Shape, err)
+	}
+}
+
+func TestDirectivesSpecFirst(t *testing.T) {
+	ref := map[string]any{"circle": new(circle), "square": new(square)}
+	EnableDirectives(ref)
+	spec, err := schema.NewStruct("directed", map[string]any{"Shape": "square"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := new(directed)
+	if err := UnmarshalSpec([]byte("# dethcl:type=circle\nshape {\n  sx = 1\n  sy = 2\n}\n"), d, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Shape.(*square); !ok {
+		t.Errorf("got %#v", d.Shape)
+	}
+}
+
+func TestDirectivesErrors(t *testing.T) {
+	ref := map[string]any{"circle": new(circle), "square": new(square)}
+	EnableDirectives(ref)
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"# dethcl:type=triangle\nshape {\n}\n", `dethcl:type "triangle" not found`},
+		{"# dethcl:kind=circle\nshape {\n}\n", "invalid directive"},
+		{"# dethcl:type=\nshape {\n}\n", "invalid directive"},
+		{"# dethcl:type=circle\nshapes \"a\" {\n  radius = 1\n}\nshapes \"b\" {\n  radius = 2\n}\n", "missing dethcl:type directive"},
+	}
+	for _, tt := range tests {
+		err := UnmarshalSpec([]byte(tt.data), new(directed), nil, ref)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error with %q, got %v", tt.data, tt.want, err)
+		}
+	}
+}
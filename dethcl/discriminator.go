@@ -0,0 +1,49 @@
+package dethcl
+
+import (
+	"bytes"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// SetDiscriminator names the attribute that chooses the class of a block of
+// an interface field in the data decoded with ref, like the discriminator of
+// a JSON union:
+//
+//	shape {
+//	  type   = "circle"
+//	  radius = 2
+//	}
+//
+// after SetDiscriminator(ref, "type"). The attribute is _type by default, and
+// an empty attr turns the lookup off. The class is looked up in ref, and the
+// attribute is consumed rather than decoded into the struct. A spec naming
+// the class of the field takes precedence, as does a typefield tag, which
+// keeps its attribute. The ref map must not be nil.
+func SetDiscriminator(ref map[string]any, attr string) {
+	ref[contextKeyDiscriminator] = attr
+}
+
+// discriminator returns the attribute set by SetDiscriminator on ref, or
+// defaultDiscriminator.
+func discriminator(ref map[string]any) string {
+	if attr, ok := ref[contextKeyDiscriminator].(string); ok {
+		return attr
+	}
+	return defaultDiscriminator
+}
+
+// blankAttributes returns a copy of src with the source of attrs replaced by
+// spaces, so that they are not decoded while the positions of the rest,
+// including lines, stay the same.
+func blankAttributes(src []byte, attrs []*hclsyntax.Attribute) []byte {
+	blanked := bytes.Clone(src)
+	for _, attr := range attrs {
+		for i := attr.SrcRange.Start.Byte; i < attr.SrcRange.End.Byte; i++ {
+			if blanked[i] != '\n' && blanked[i] != '\r' {
+				blanked[i] = ' '
+			}
+		}
+	}
+	return blanked
+}
@@ -0,0 +1,119 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OpenUdon/schema"
+)
+
+// taggedCircle has a field for the discriminator, to show that it is consumed
+type taggedCircle struct {
+	Kind   string  `hcl:"_type,optional"`
+	Radius float32 `hcl:"radius"`
+}
+
+func (c *taggedCircle) Area() float32 {
+	return 3.14159 * c.Radius
+}
+
+type discriminated struct {
+	Name   string           `hcl:"name"`
+	Shape  inter            `hcl:"shape,block"`
+	Layers []inter          `hcl:"layer,block"`
+	Shapes map[string]inter `hcl:"shapes,block"`
+}
+
+func TestDiscriminator(t *testing.T) {
+	data := `name = "union"
+shape {
+  _type  = "circle"
+  radius = 2
+}
+layer {
+  _type = "square"
+  sx    = 1
+  sy    = 2
+}
+layer {
+  _type  = "circle"
+  radius = 3
+}
+shapes "a" {
+  _type = "square"
+  sx    = 4
+  sy    = 5
+}
+`
+	ref := map[string]any{"circle": new(taggedCircle), "square": new(square)}
+	d := new(discriminated)
+	if err := UnmarshalSpec([]byte(data), d, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := d.Shape.(*taggedCircle); !ok || c.Radius != 2 || c.Kind != "" {
+		t.Errorf("shape: %#v", d.Shape)
+	}
+	if len(d.Layers) != 2 {
+		t.Fatalf("layers: %#v", d.Layers)
+	}
+	if s, ok := d.Layers[0].(*square); !ok || s.SX != 1 || s.SY != 2 {
+		t.Errorf("layers: %#v", d.Layers)
+	}
+	if c, ok := d.Layers[1].(*taggedCircle); !ok || c.Radius != 3 {
+		t.Errorf("layers: %#v", d.Layers)
+	}
+	if s, ok := d.Shapes["a"].(*square); !ok || s.SX != 4 {
+		t.Errorf("shapes: %#v", d.Shapes)
+	}
+
+	// a spec names the class first
+	spec, err := schema.NewStruct("discriminated", map[string]any{"Shape": "square"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = new(discriminated)
+	if err := UnmarshalSpec([]byte("name = \"x\"\nshape {\n  _type = \"circle\"\n  sx = 1\n  sy = 1\n}\n"), d, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Shape.(*square); !ok {
+		t.Errorf("got %#v", d.Shape)
+	}
+}
+
+func TestSetDiscriminator(t *testing.T) {
+	data := []byte("name = \"x\"\nshape {\n  type   = \"circle\"\n  radius = 1\n}\n")
+	ref := map[string]any{"circle": new(taggedCircle), "square": new(square)}
+	SetDiscriminator(ref, "type")
+	d := new(discriminated)
+	if err := UnmarshalSpec(data, d, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := d.Shape.(*taggedCircle); !ok || c.Radius != 1 {
+		t.Errorf("got %#v", d.Shape)
+	}
+
+	// turned off, the block has no class
+	SetDiscriminator(ref, "")
+	d = new(discriminated)
+	if err := UnmarshalSpec([]byte("name = \"x\"\nshape {\n  _type  = \"circle\"\n  radius = 1\n}\n"), d, nil, ref); err != nil || d.Shape != nil {
+		t.Errorf("got %#v, %v", d.Shape, err)
+	}
+}
+
+func TestDiscriminatorErrors(t *testing.T) {
+	ref := map[string]any{"circle": new(taggedCircle), "square": new(square)}
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"name = \"x\"\nshape {\n  _type = \"triangle\"\n}\n", `_type "triangle" not found`},
+		{"name = \"x\"\nshape {\n  _type = 1\n}\n", "_type must be a literal string"},
+		{"name = \"x\"\nlayer {\n  _type  = \"circle\"\n  radius = 1\n}\nlayer {\n  radius = 2\n}\n", "missing _type attribute"},
+	}
+	for _, tt := range tests {
+		err := UnmarshalSpec([]byte(tt.data), new(discriminated), nil, ref)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error with %q, got %v", tt.data, tt.want, err)
+		}
+	}
+}
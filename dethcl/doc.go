@@ -27,6 +27,43 @@
 //	// Marshal
 //	bytes, err := dethcl.Marshal(&cfg)
 //
+// Unmarshal reads native HCL syntax, which already allows a trailing comma
+// in tuples, objects and function calls, e.g. [1, 2, 3,] or { a = 1, }, so
+// loosely generated files need no lenient mode. An empty element, as in
+// [1, ,2], is still an error.
+//
+// Unmarshal ignores attributes and blocks that match no field. UnmarshalStrict
+// reports them instead, with their lines and columns, to catch typos in
+// hand-written configuration.
+//
+// Documents nesting deeper than 1000 levels, counting blocks, labels, lists
+// and objects, are rejected rather than decoded recursively without bound;
+// UnmarshalOptions.MaxDepth and MarshalOptions.MaxDepth change the limit.
+//
+// An object attribute giving the same key twice, e.g. env = { a = 1, a = 2 },
+// keeps the last value, as in HCL. UnmarshalStrict, and UnmarshalOptions with
+// RejectDuplicateKeys, report it with its position instead. Attributes of a
+// body set twice are always an error unless UnmarshalOptions.DuplicateAttr
+// says otherwise.
+//
+// HCL that fails to parse gives a *DethclError, which carries the parser
+// diagnostics and the source for rendering them with their byte ranges.
+// An attribute whose value cannot be evaluated or converted to its field, a
+// block or label that cannot be decoded, or a failed Validate gives a
+// *FieldError, whose Path names it from the root, e.g.
+// service.api.listener.port. Errors in the type specification, such as a
+// class missing from the ref map, name the Go field instead.
+//
+// An Encoder writes values to an io.Writer one at a time, separating them by
+// a blank line, so that a large document need not be built in memory:
+//
+//	enc := dethcl.NewEncoder(os.Stdout)
+//	err := enc.Encode(&cfg)
+//
+// A Decoder reads a whole document from an io.Reader, such as an HTTP
+// request body, and decodes it like Unmarshal or, with DecodeSpec, like
+// UnmarshalSpec.
+//
 // # Working with Interface Fields
 //
 // For structures containing interface fields, use UnmarshalSpec with type specifications:
@@ -59,16 +96,122 @@
 //	var geo Geo
 //	err = dethcl.UnmarshalSpec(hclBytes, &geo, spec, ref)
 //
+// UnmarshalAuto builds both the spec and ref from the implementations of
+// each interface, e.g. map[string][]any{"Shape": {&Circle{}}}, when every
+// interface field has a single implementation.
+//
+// NewRef builds a ref from sample values, e.g. NewRef(&Circle{}, &Geo{}),
+// under their short and package-qualified type names.
+//
+// Class names in a spec are looked up in ref. Alias adds another name for a
+// registered type, e.g. dethcl.Alias(ref, "round", "Circle") lets the spec say
+// "round". An alias takes precedence over a type registered directly under the
+// same name.
+//
+// Types can also be registered once, e.g. in init functions, with
+// DefaultRegistry.Register("circle", &Circle{}); UnmarshalDefault then decodes
+// with a spec and no ref. A name registered twice for different types panics.
+//
+// Without a spec entry, an interface field is decoded into the type
+// registered with RegisterDefault, e.g. dethcl.RegisterDefault[Shape](&Circle{}).
+// A spec entry for the field wins over the default.
+//
+// Generic structs such as Box[T] marshal and unmarshal like any other struct
+// once instantiated. Class names are only keys of ref, so an instantiation is
+// registered under a name of its own, conventionally the name reflect gives
+// it, e.g. ref := map[string]any{"Box[int]": &Box[int]{}} with the spec
+// naming "Box[int]"; Box[int] and Box[string] are distinct classes.
+//
+// Decoding never changes the spec it is given. CloneSpec returns a deep copy
+// of a spec, to tweak a shared base spec per request.
+//
+// DescribeSpec prints a spec as a short tree, e.g.
+// Outer { Items: [Item], Shape: Circle }, to compare it with the Go types.
+//
 // # HCL Struct Tags
 //
 // The package uses struct tags to control marshaling/unmarshaling:
 //
 //   - `hcl:"name"` - Field name in HCL
 //   - `hcl:"name,optional"` - Optional field (won't error if missing)
+//   - `hcl:"name,omitempty"` - Like optional, but Marshal also leaves out zero values and empty slices and maps
 //   - `hcl:"name,block"` - Field is an HCL block (for structs, maps, slices)
 //   - `hcl:"name,label"` - Field value becomes an HCL label (for map keys)
+//   - `hcl:"name,trim"` - Trim surrounding whitespace from a decoded string
+//   - `hcl:"name,explicitnull"` - Write `name = null` for a nil pointer or interface instead of omitting it
+//   - `hcl:"name,block,required"` - Fail to marshal a nil pointer instead of omitting it
+//   - `hcl:"name,block,collapse"` - Write a map of structs as one object attribute instead of labeled blocks
+//   - `hcl:"name,repeated"` - Write a slice of primitives as one block per element, name { value = "a" }
+//   - `hcl:",comment"` - Keep the comments above attributes and blocks in a map[string]string
+//   - `hcl:"name,sensitive"` - Mark a secret, see MarshalOptions.RedactSensitive and SensitiveFields
+//   - `hcl:"name,mapkey=key"` - Decode a list of objects into a map keyed by their "key" attribute
+//   - `hcl:"name,block,typefield=type"` - Decode each block of an interface field into the class named by its "type" attribute
 //   - `hcl:"-"` - Ignore this field
 //
+// Modifiers can be combined, e.g. `hcl:"name,optional,trim"`.
+//
+// Marshal leaves out a nil pointer field, like a struct value whose fields
+// are all zero, so an optional block is simply absent. A nil pointer tagged
+// required is an error rather than an incomplete configuration. With
+// MarshalOptions.ExplicitNulls, every nil pointer and interface is written as
+// name = null, which Unmarshal decodes back to nil, while an absent field
+// keeps its value.
+//
+// Optional fields with an empty slice or map are written as `tags = []` or
+// an empty block; omitempty leaves them out instead. An omitempty interface
+// field is judged by its dynamic value: it is omitted when nil or when it
+// holds an empty slice, map or string, but a struct behind it is always
+// written. Unmarshal treats omitempty exactly like optional.
+//
+// String fields can also carry `hclprefix:"/etc/"` and `hclsuffix:".conf"`.
+// Marshal adds them around the value and Unmarshal strips them, so the Go
+// value stays relative while the HCL holds the full string.
+//
+// A string of several lines ending with a newline, such as a script or a
+// query, is written as a heredoc, body = <<EOT ... EOT, with a delimiter not
+// found in the text. Other strings stay quoted, with \n escapes.
+//
+// A time.Time field is written as an RFC 3339 string, or in the layout of an
+// hcltime tag, e.g. `hcl:"date" hcltime:"2006-01-02"`, and parsed back the
+// same way.
+//
+// Fields tagged sensitive are written as "(sensitive)" when
+// MarshalOptions.RedactSensitive is set. After decoding, SensitiveFields
+// lists the paths of those holding a value, e.g. database.primary.password,
+// so that callers can keep them out of logs.
+//
+// With typefield, a []Step of mixed blocks such as step { type = "http" }
+// needs no spec: each class is looked up in ref by the attribute, which the
+// concrete types may keep in a field of their own to write it back.
+//
+// After EnableDirectives(ref), a comment line # dethcl:type=circle right
+// above a block of an interface field names its class as well. A spec for
+// the field comes first, then a typefield attribute, then the directive.
+//
+// Blocks of other interface fields are resolved by a _type attribute, e.g.
+// shape { _type = "circle" }, after a spec and before a directive. The
+// attribute is consumed rather than decoded into the class.
+// SetDiscriminator(ref, "kind") changes its name, and an empty name turns the
+// lookup off.
+//
+// Types implementing encoding.TextMarshaler and encoding.TextUnmarshaler,
+// directly or through a pointer, e.g. netip.Addr or net.IP, are written as
+// strings by MarshalText and decoded by UnmarshalText. So are url.URL and
+// net.IPNet, which have no text methods, e.g. subnet = "2001:db8::/32".
+//
+// A []byte or a byte array such as [32]byte is written as a base64 string,
+// not as a list of numbers. Decoding an array fails unless the string holds
+// exactly as many bytes as the array.
+//
+// Integer types registered with RegisterIntEnum are written by name, e.g.
+// level = "warn" for a Level constant, and decoded from the name or a number.
+//
+// Exported fields without a tag name use the lowercased field name. HCLName
+// returns the name and modifier used for any field. With
+// MarshalOptions.UseJSONTagFallback, Marshal names fields without hcl tag by
+// their json tag instead, omitempty included, and UnmarshalOptions has the
+// same option to read them back.
+//
 // # Map Encoding
 //
 // Maps are encoded as labeled blocks:
@@ -86,6 +229,31 @@
 //	  port = 5432
 //	}
 //
+// The keys of such maps may also be integers, e.g. map[int]*Listener keyed
+// by port, written as decimal labels such as listener "443" { ... } and
+// parsed back into the key type. Label fields may likewise be integers, or
+// string types such as type Region string, and registered enums are written
+// by name; other types are an error.
+//
+// Unmarshal also reads such a map from an object attribute, as written by
+// JSON conversions, e.g. service = { api = { port = 8080 } }.
+//
+// Tagging the field collapse writes it that way, keyed by the map keys and
+// nested once more for a [2]string key; label fields are left out, being
+// given by the keys.
+//
+// A generic map[string]any is written with its scalars, lists and nulls as
+// attributes first, then its maps as blocks, each group in key order, so
+// {"b": {"c": 2}, "a": 1} gives a = 1 followed by b { c = 2 }. A map whose
+// values are all maps adds their keys as labels, up to two. Unmarshal into a
+// map[string]any reads the output back to the same map, also for integers
+// beyond 64 bits, decoded as *big.Int and written back in full. Fields of
+// type big.Int or big.Float, or pointers to them, are numbers too.
+//
+// Named map and slice types, e.g. type Tags map[string]string, are encoded
+// like their underlying types, also as values of maps and behind pointers,
+// and so is a named generic map used as the whole document.
+//
 // # Custom Marshalers
 //
 // Implement Marshaler/Unmarshaler interfaces for custom encoding:
@@ -102,4 +270,65 @@
 //	    // Custom parsing logic
 //	    return nil
 //	}
+//
+// MarshalHCL output is reindented to its place in the document. A type
+// implementing LevelMarshaler instead gets the nesting level from
+// MarshalHCLLevel and indents its body itself, e.g. to lay out nested blocks
+// of its own; it is preferred over MarshalHCL.
+//
+// A type implementing Commenter keeps the default encoding and adds comments
+// to its fields, e.g. timeout = 30 # seconds. Unmarshal skips them.
+//
+// To keep the comments of a document instead, add a field tagged comment:
+//
+//	Comments map[string]string `hcl:",comment"`
+//
+// Unmarshal fills it with the comment lines right above each attribute and
+// block, keyed by attribute name or block type and without the # markers,
+// and Marshal writes them back above the same names. Trailing comments and
+// those separated by a blank line are not kept.
+//
+// A type implementing Validator checks its invariants once decoded:
+//
+//	func (s *Service) Validate() error {
+//	    if s.Port < 1 || s.Port > 65535 {
+//	        return fmt.Errorf("port %d out of range", s.Port)
+//	    }
+//	    return nil
+//	}
+//
+// Validate runs on the target and on every nested block after its fields are
+// set, and the first error is returned with the path of the block, e.g.
+// service.api: port 0 out of range.
+//
+// # Building Documents
+//
+// Document assembles HCL without a matching Go struct, e.g. for code generation:
+//
+//	doc := NewDocument()
+//	doc.SetAttr("name", "api")
+//	doc.AddBlock("service", []string{"api"}, &Service{Port: 8080})
+//	bs, err := doc.Bytes()
+//
+// Nested blocks are added by passing another *Document as the block value.
+//
+// MarshalBody returns the brace-less body of a struct or a map without root
+// indentation, ready to be spliced into a hand-written block or a template.
+//
+// ToMap returns a struct as the map[string]any that Unmarshal would give for
+// its HCL, without writing the HCL, e.g. to convert it to YAML.
+//
+// Format rewrites HCL in a canonical form, with attributes and blocks in key
+// order, so that two configurations can be compared byte for byte.
+//
+// UnmarshalWithVars sets variables read by expressions as var.<name>, e.g.
+// name = var.service_name, before decoding; nested values such as
+// var.db.host come from object values. UnmarshalWithNativeVars takes them
+// as Go values.
+//
+// Merge layers one configuration over another, e.g. an environment over a
+// base, as written: attributes stay attributes and expressions are kept.
+// Blocks are merged by type and label, object values key by key, and other
+// values of the override replace those of the base, or are appended to its
+// lists with MergeOptions.AppendSlices.
 package dethcl
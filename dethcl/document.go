@@ -0,0 +1,223 @@
+package dethcl
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/genelet/horizon/utils"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Document builds an HCL document incrementally, without a Go struct
+// describing its shape. It is safe for concurrent use.
+//
+// Attributes and blocks are written in the order they are added. Block values
+// are Go structs encoded by the regular marshal path, or nested Documents.
+//
+// Example:
+//
+//	doc := NewDocument()
+//	doc.SetAttr("name", "api")
+//	doc.AddBlock("service", []string{"api"}, &Service{Port: 8080})
+//	bs, err := doc.Bytes()
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(string(bs))
+//	// Output:
+//	//   name = "api"
+//	//   service "api" {
+//	//     port = 8080
+//	//   }
+type Document struct {
+	mu    sync.Mutex
+	items []*documentItem
+}
+
+// documentItem is one attribute or block of a Document.
+type documentItem struct {
+	name   string
+	labels []string
+	attr   *cty.Value // set for attributes
+	value  any        // block value: struct, pointer to struct, *Document or nil
+}
+
+// NewDocument returns an empty Document.
+func NewDocument() *Document {
+	return &Document{}
+}
+
+// SetAttr sets attribute name to value. Setting an existing attribute replaces
+// its value in place. The value can be a cty.Value or any Go value accepted by
+// utils.NativeToCty, e.g. string, number, bool, []string or map[string]any.
+func (d *Document) SetAttr(name string, value any) error {
+	if name == "" {
+		return fmt.Errorf("attribute name is empty")
+	}
+	var ctyVal cty.Value
+	switch t := value.(type) {
+	case cty.Value:
+		ctyVal = t
+	case nil:
+		ctyVal = cty.NullVal(cty.DynamicPseudoType)
+	default:
+		var err error
+		ctyVal, err = utils.NativeToCty(value)
+		if err != nil {
+			return fmt.Errorf("attribute %s: %w", name, err)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, item := range d.items {
+		if item.attr != nil && item.name == name {
+			item.attr = &ctyVal
+			return nil
+		}
+	}
+	d.items = append(d.items, &documentItem{name: name, attr: &ctyVal})
+	return nil
+}
+
+// AddBlock appends a block of type typ with the given labels. The value is a
+// struct or pointer to struct with hcl tags, a nested *Document, or nil for an
+// empty block. Label fields of a struct value that repeat the given labels are
+// not written twice.
+func (d *Document) AddBlock(typ string, labels []string, value any) error {
+	if typ == "" {
+		return fmt.Errorf("block type is empty")
+	}
+	switch t := value.(type) {
+	case nil:
+	case *Document:
+		if t == d || t.contains(d, make(map[*Document]bool)) {
+			return fmt.Errorf("block %s: document cannot contain itself", typ)
+		}
+	default:
+		kind := reflect.TypeOf(value).Kind()
+		if kind != reflect.Struct && kind != reflect.Pointer {
+			return fmt.Errorf("block %s: expected struct, pointer or *Document, got %T", typ, value)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items = append(d.items, &documentItem{name: typ, labels: append([]string(nil), labels...), value: value})
+	return nil
+}
+
+// contains reports whether target is nested in d, at any depth. Documents
+// already in seen are skipped.
+func (d *Document) contains(target *Document, seen map[*Document]bool) bool {
+	if seen[d] {
+		return false
+	}
+	seen[d] = true
+	for _, nested := range d.nested() {
+		if nested == target || nested.contains(target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// nested returns the Documents added as block values of d.
+func (d *Document) nested() []*Document {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var list []*Document
+	for _, item := range d.items {
+		if nested, ok := item.value.(*Document); ok {
+			list = append(list, nested)
+		}
+	}
+	return list
+}
+
+// MarshalHCL implements Marshaler, so a Document can be passed to Marshal or
+// used as a field value.
+func (d *Document) MarshalHCL() ([]byte, error) {
+	return d.render(0, nil)
+}
+
+// Bytes returns the HCL encoding of the document, or the error of a block
+// value that fails to marshal. It is the same as MarshalHCL.
+func (d *Document) Bytes() ([]byte, error) {
+	return d.render(0, nil)
+}
+
+// render encodes the document at the given indentation level. At level 0 the
+// output has the same layout as Marshal; nested levels are wrapped in braces.
+// Outer holds the documents being rendered around d, to stop on a cycle.
+func (d *Document) render(level int, outer map[*Document]bool) ([]byte, error) {
+	if outer[d] {
+		return nil, fmt.Errorf("document contains itself")
+	}
+	outer = maps.Clone(outer)
+	if outer == nil {
+		outer = make(map[*Document]bool)
+	}
+	outer[d] = true
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	indentation := indent(level + 1)
+	var lines []string
+
+	// consecutive attributes share one hclwrite body so that their
+	// equal signs are aligned the same way gohcl does
+	var attrs *hclwrite.File
+	flush := func() {
+		if attrs == nil {
+			return
+		}
+		text := strings.TrimRight(string(attrs.Bytes()), "\n")
+		lines = append(lines, strings.Split(text, "\n")...)
+		attrs = nil
+	}
+
+	for _, item := range d.items {
+		if item.attr != nil {
+			if attrs == nil {
+				attrs = hclwrite.NewEmptyFile()
+			}
+			attrs.Body().SetAttributeValue(item.name, *item.attr)
+			continue
+		}
+		flush()
+
+		var bs []byte
+		var err error
+		if nested, ok := item.value.(*Document); ok {
+			bs, err = nested.render(level+1, outer)
+		} else {
+			bs, err = marshalLevel(nil, item.value, false, level+1, item.labels...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isBlank(bs) {
+			bs = []byte("{\n" + indentation + "}")
+		}
+
+		line := item.name + " "
+		for _, label := range item.labels {
+			line += string(hclwrite.TokensForValue(cty.StringVal(label)).Bytes()) + " "
+		}
+		lines = append(lines, line+string(bs))
+	}
+	flush()
+
+	result := indentation + strings.Join(lines, "\n"+indentation)
+	result = strings.TrimRight(result, " \t\n\r")
+	if level > 0 {
+		result = fmt.Sprintf("{\n%s\n%s}", result, indent(level))
+	}
+	return []byte(result), nil
+}
@@ -0,0 +1,175 @@
+package dethcl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+func TestDocument(t *testing.T) {
+	type service struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+
+	inner := NewDocument()
+	if err := inner.SetAttr("path", "/health"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := NewDocument()
+	if err := doc.SetAttr("name", "api"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetAttr("replicas", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.AddBlock("service", []string{"api"}, &service{Name: "api", Port: 8080}); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.AddBlock("check", nil, inner); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.AddBlock("empty", []string{"x"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.SetAttr("replicas", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `  name     = "api"
+  replicas = 3
+  service "api" {
+    port = 8080
+  }
+  check {
+    path = "/health"
+  }
+  empty "x" {
+  }`
+	got, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+
+	type config struct {
+		Name     string             `hcl:"name"`
+		Replicas int                `hcl:"replicas"`
+		Service  map[string]service `hcl:"service,block"`
+		Check    struct {
+			Path string `hcl:"path"`
+		} `hcl:"check,block"`
+		Empty map[string]*service `hcl:"empty,block"`
+	}
+	cfg := new(config)
+	if err := Unmarshal(got, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "api" || cfg.Replicas != 3 || cfg.Service["api"].Port != 8080 || cfg.Check.Path != "/health" {
+		t.Errorf("%#v", cfg)
+	}
+
+	bs, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != expected {
+		t.Errorf("Marshal(doc) = %s", bs)
+	}
+}
+
+func TestDocumentErrors(t *testing.T) {
+	doc := NewDocument()
+	if err := doc.AddBlock("self", nil, doc); err == nil {
+		t.Error("expected error for self nesting")
+	}
+	if err := doc.AddBlock("bad", nil, 1); err == nil {
+		t.Error("expected error for non-struct block value")
+	}
+	if err := doc.SetAttr("", 1); err == nil {
+		t.Error("expected error for empty attribute name")
+	}
+
+	a, b := NewDocument(), NewDocument()
+	if err := a.AddBlock("b", nil, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddBlock("c", nil, NewDocument()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddBlock("a", nil, a); err == nil {
+		t.Error("expected error for a cycle")
+	}
+	// a cycle that bypasses AddBlock is reported when rendering
+	b.items = append(b.items, &documentItem{name: "a", value: a})
+	if _, err := a.Bytes(); err == nil {
+		t.Error("expected error rendering a cycle")
+	}
+
+	doc = NewDocument()
+	if err := doc.AddBlock("bad", nil, &failingBlock{}); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := doc.Bytes(); err == nil {
+		t.Errorf("expected error from the block value, got %s", bs)
+	}
+}
+
+// failingBlock is a block value whose encoding fails.
+type failingBlock struct{}
+
+func (*failingBlock) MarshalHCL() ([]byte, error) {
+	return nil, errors.New("cannot marshal")
+}
+
+func TestDocumentLabels(t *testing.T) {
+	labels := []string{`say "hi"`, `back\slash`, "${x}", "line\nbreak"}
+	doc := NewDocument()
+	if err := doc.AddBlock("item", labels, nil); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, diags := hclsyntax.ParseConfig(bs, "labels.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("%s\n%s", diags, bs)
+	}
+	blocks := file.Body.(*hclsyntax.Body).Blocks
+	if len(blocks) != 1 || !reflect.DeepEqual(blocks[0].Labels, labels) {
+		t.Errorf("got blocks %v from\n%s", blocks, bs)
+	}
+}
+
+func TestDocumentConcurrent(t *testing.T) {
+	doc := NewDocument()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc.SetAttr("count", i)
+			doc.AddBlock("item", nil, NewDocument())
+			if _, err := doc.Bytes(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	bs, err := doc.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(bs), "item {"); n != 20 {
+		t.Errorf("got %d blocks", n)
+	}
+}
@@ -0,0 +1,207 @@
+package dethcl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// DuplicateAttr selects how UnmarshalWithOptions handles an attribute set
+// more than once in the same body, e.g. port = 80 followed by port = 8080.
+type DuplicateAttr int
+
+const (
+	// DuplicateError rejects the document, as HCL does.
+	DuplicateError DuplicateAttr = iota
+	// DuplicateFirst keeps the first value and drops the others.
+	DuplicateFirst
+	// DuplicateLast keeps the last value, so later lines override earlier
+	// ones.
+	DuplicateLast
+	// DuplicateCollect gives a list of all the values in order, e.g.
+	// port = [80, 8080]. An attribute set once keeps its single value.
+	DuplicateCollect
+)
+
+// attrDef is the byte range of one attribute definition in the source.
+type attrDef struct {
+	start     int  // start of the name
+	exprStart int  // start of the expression
+	end       int  // end of the expression
+	heredoc   bool // the expression ends with a heredoc marker
+}
+
+// resolveDuplicates rewrites src so that each attribute is set once per
+// body, following policy. Attributes of different blocks never clash, nor
+// do the keys of object constructors, which are left alone.
+func resolveDuplicates(src []byte, policy DuplicateAttr) ([]byte, error) {
+	if policy == DuplicateError {
+		return src, nil
+	}
+	if policy < DuplicateError || policy > DuplicateCollect {
+		return nil, fmt.Errorf("unknown DuplicateAttr %d", policy)
+	}
+	tokens, diags := hclsyntax.LexConfig(src, generateTempHCLFileName(), hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to lex HCL: %w", diags)
+	}
+
+	type attrKey struct {
+		body int
+		name string
+	}
+	defs := make(map[attrKey][]attrDef)
+	var keys []attrKey
+	bodies := []int{0} // stack of the open block bodies
+	nextBody := 1
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch token.Type {
+		case hclsyntax.TokenOBrace:
+			bodies = append(bodies, nextBody)
+			nextBody++
+		case hclsyntax.TokenCBrace:
+			if len(bodies) > 1 {
+				bodies = bodies[:len(bodies)-1]
+			}
+		case hclsyntax.TokenIdent:
+			if i+1 >= len(tokens) || tokens[i+1].Type != hclsyntax.TokenEqual {
+				continue
+			}
+			if i > 0 {
+				switch tokens[i-1].Type {
+				case hclsyntax.TokenNewline, hclsyntax.TokenOBrace, hclsyntax.TokenComment:
+				default:
+					continue
+				}
+			}
+			// an expression ends at the first newline outside brackets, or
+			// at the brace closing a one-line block
+			j := i + 2
+			depth := 0
+		expression:
+			for ; j < len(tokens); j++ {
+				switch tokens[j].Type {
+				case hclsyntax.TokenOBrace, hclsyntax.TokenOBrack, hclsyntax.TokenOParen,
+					hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl,
+					hclsyntax.TokenOQuote, hclsyntax.TokenOHeredoc:
+					depth++
+				case hclsyntax.TokenCBrace, hclsyntax.TokenCBrack, hclsyntax.TokenCParen,
+					hclsyntax.TokenTemplateSeqEnd, hclsyntax.TokenCQuote, hclsyntax.TokenCHeredoc:
+					if depth == 0 {
+						break expression
+					}
+					depth--
+				case hclsyntax.TokenNewline, hclsyntax.TokenComment, hclsyntax.TokenEOF:
+					if depth == 0 {
+						break expression
+					}
+				default:
+				}
+			}
+			if i+2 >= len(tokens) || j >= len(tokens) {
+				continue
+			}
+			end := tokens[j].Range.Start.Byte
+			for end > tokens[i+2].Range.Start.Byte && (src[end-1] == ' ' || src[end-1] == '\t') {
+				end--
+			}
+			key := attrKey{body: bodies[len(bodies)-1], name: string(token.Bytes)}
+			if _, ok := defs[key]; !ok {
+				keys = append(keys, key)
+			}
+			defs[key] = append(defs[key], attrDef{
+				start:     token.Range.Start.Byte,
+				exprStart: tokens[i+2].Range.Start.Byte,
+				end:       end,
+				heredoc:   tokens[j-1].Type == hclsyntax.TokenCHeredoc,
+			})
+			i = j - 1
+		default:
+		}
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	for _, key := range keys {
+		list := defs[key]
+		if len(list) < 2 {
+			continue
+		}
+		first := list[0]
+		switch policy {
+		case DuplicateLast:
+			last := list[len(list)-1]
+			edits = append(edits, edit{first.exprStart, first.end, string(src[last.exprStart:last.end])})
+		case DuplicateCollect:
+			exprs := make([]string, len(list))
+			for k, def := range list {
+				exprs[k] = string(src[def.exprStart:def.end])
+				// the closing marker of a heredoc must end its line
+				if def.heredoc {
+					exprs[k] += "\n"
+				}
+			}
+			edits = append(edits, edit{first.exprStart, first.end, "[" + strings.Join(exprs, ", ") + "]"})
+		default:
+		}
+		for _, def := range list[1:] {
+			edits = append(edits, edit{def.start, def.end, ""})
+		}
+	}
+	if len(edits) == 0 {
+		return src, nil
+	}
+	sort.Slice(edits, func(a, b int) bool { return edits[a].start < edits[b].start })
+	var out []byte
+	start := 0
+	for _, e := range edits {
+		out = append(out, src[start:e.start]...)
+		out = append(out, e.text...)
+		start = e.end
+	}
+	return append(out, src[start:]...), nil
+}
+
+// checkDuplicateKeys fails if an object constructor in src, e.g.
+// env = { a = 1, a = 2 }, gives the same key twice, which HCL accepts by
+// keeping the last value. The error has the line, column and byte offset of
+// the second key. Keys computed from expressions, and src that does not
+// parse, are left to the decoder.
+func checkDuplicateKeys(src []byte) error {
+	file, diags := hclsyntax.ParseConfig(src, generateTempHCLFileName(), hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil
+	}
+	var errs []error
+	hclsyntax.VisitAll(file.Body.(*hclsyntax.Body), func(node hclsyntax.Node) hcl.Diagnostics {
+		object, ok := node.(*hclsyntax.ObjectConsExpr)
+		if !ok {
+			return nil
+		}
+		seen := make(map[string]hcl.Range, len(object.Items))
+		for _, item := range object.Items {
+			key, diags := item.KeyExpr.Value(nil)
+			if diags.HasErrors() || !key.IsKnown() || key.IsNull() || key.Type() != cty.String {
+				continue
+			}
+			rng := item.KeyExpr.Range()
+			if first, ok := seen[key.AsString()]; ok {
+				errs = append(errs, fmt.Errorf("duplicate key %q at line %d, column %d (byte %d), first set at line %d, column %d",
+					key.AsString(), rng.Start.Line, rng.Start.Column, rng.Start.Byte, first.Start.Line, first.Start.Column))
+				continue
+			}
+			seen[key.AsString()] = rng
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
@@ -0,0 +1,112 @@
+package dethcl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalDuplicateAttr(t *testing.T) {
+	type server struct {
+		Name  string `hcl:"name,label"`
+		Port  any    `hcl:"port"`
+		Notes any    `hcl:"notes,optional"`
+	}
+	type config struct {
+		Port    any       `hcl:"port"`
+		Tags    any       `hcl:"tags,optional"`
+		Servers []*server `hcl:"server,block"`
+	}
+
+	data := []byte(`port = 80 # plain
+tags = { port = 1, env = "dev" }
+server "a" {
+  port  = 1
+  notes = <<EOT
+first
+EOT
+  port  = 2
+  notes = "second"
+}
+server "b" { port = 3 }
+port = 8080
+`)
+
+	tests := []struct {
+		policy  DuplicateAttr
+		port    any
+		portA   any
+		notesA  any
+		wantErr string
+	}{
+		{DuplicateError, nil, nil, nil, "redefined"},
+		{DuplicateFirst, 80, 1, "first\n", ""},
+		{DuplicateLast, 8080, 2, "second", ""},
+		{DuplicateCollect, []any{80, 8080}, []any{1, 2}, []any{"first\n", "second"}, ""},
+	}
+	for _, tt := range tests {
+		cfg := new(config)
+		err := UnmarshalWithOptions(data, cfg, UnmarshalOptions{DuplicateAttr: tt.policy})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("policy %d: expected error with %q, got %v", tt.policy, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy %d: %v", tt.policy, err)
+		}
+		if !reflect.DeepEqual(cfg.Port, tt.port) || len(cfg.Servers) != 2 ||
+			!reflect.DeepEqual(cfg.Servers[0].Port, tt.portA) || !reflect.DeepEqual(cfg.Servers[0].Notes, tt.notesA) {
+			t.Errorf("policy %d: got %#v %#v", tt.policy, cfg.Port, cfg.Servers[0])
+		}
+		// attributes of other blocks and object keys are not duplicates
+		if cfg.Servers[1].Port != 3 || !reflect.DeepEqual(cfg.Tags, map[string]any{"port": 1, "env": "dev"}) {
+			t.Errorf("policy %d: got %#v %#v", tt.policy, cfg.Servers[1], cfg.Tags)
+		}
+	}
+
+	if err := UnmarshalWithOptions([]byte("port = 1"), new(config), UnmarshalOptions{DuplicateAttr: 9}); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestRejectDuplicateKeys(t *testing.T) {
+	type config struct {
+		Env  map[string]string `hcl:"env"`
+		Tags any               `hcl:"tags,optional"`
+	}
+	data := []byte(`env = { a = "1", b = "2" }
+tags = {
+  nested = { x = 1, "x" = 2 }
+}
+`)
+	opts := UnmarshalOptions{RejectDuplicateKeys: true}
+
+	// tolerated by default, the last value wins
+	cfg := new(config)
+	if err := Unmarshal(data, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Tags, map[string]any{"nested": map[string]any{"x": 2}}) {
+		t.Errorf("got %#v", cfg.Tags)
+	}
+
+	want := `duplicate key "x" at line 3, column 21 (byte 56), first set at line 3, column 14`
+	for name, unmarshal := range map[string]func() error{
+		"options": func() error { return UnmarshalWithOptions(data, new(config), opts) },
+		"strict":  func() error { return UnmarshalStrict(data, new(config)) },
+	} {
+		if err := unmarshal(); err == nil || err.Error() != want {
+			t.Errorf("%s: expected %q, got %v", name, want, err)
+		}
+	}
+
+	cfg = new(config)
+	if err := UnmarshalWithOptions([]byte(`env = { a = "1", b = "2" }`), cfg, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Env, map[string]string{"a": "1", "b": "2"}) {
+		t.Errorf("got %#v", cfg.Env)
+	}
+}
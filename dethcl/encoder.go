@@ -0,0 +1,53 @@
+package dethcl
+
+import (
+	"io"
+)
+
+// Encoder writes HCL encodings of values to an output stream, such as
+// os.Stdout, a bytes.Buffer or a gzip.Writer, so that a configuration made of
+// many parts need not be collected in one slice.
+type Encoder struct {
+	w       io.Writer
+	opts    MarshalOptions
+	written bool
+}
+
+// NewEncoder returns an Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SetOptions makes later calls to Encode marshal like MarshalWithOptions with opts.
+func (e *Encoder) SetOptions(opts MarshalOptions) {
+	e.opts = opts
+}
+
+// Encode writes the HCL encoding of v, followed by a newline. Successive
+// values are separated by a blank line, so that each call can add top-level
+// attributes and blocks to the same document. A value with an empty encoding
+// writes nothing. Errors of the writer are returned as they occur.
+func (e *Encoder) Encode(v any) error {
+	bs, err := MarshalWithOptions(v, e.opts)
+	if err != nil {
+		return err
+	}
+	if isBlank(bs) {
+		return nil
+	}
+	if e.written {
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+	if _, err := e.w.Write(bs); err != nil {
+		return err
+	}
+	if bs[len(bs)-1] != '\n' {
+		if _, err := io.WriteString(e.w, "\n"); err != nil {
+			return err
+		}
+	}
+	e.written = true
+	return nil
+}
@@ -0,0 +1,55 @@
+package dethcl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEncoder(t *testing.T) {
+	type service struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type server struct {
+		Service *service `hcl:"service,block"`
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, s := range []*server{{Service: &service{Name: "api", Port: 80}}, nil, {Service: &service{Name: "web", Port: 81}}} {
+		if err := enc.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := `  service "api" {
+    port = 80
+  }
+
+  service "web" {
+    port = 81
+  }
+`
+	if buf.String() != expected {
+		t.Errorf("got\n%q\nwant\n%q", buf.String(), expected)
+	}
+
+	// the stream decodes as one document
+	var back map[string]any
+	if err := Unmarshal(buf.Bytes(), &back); err != nil {
+		t.Fatal(err)
+	}
+	if services, ok := back["service"].(map[string]any); !ok || len(services) != 2 {
+		t.Errorf("got %#v", back)
+	}
+
+	if err := NewEncoder(failingWriter{}).Encode(&server{Service: &service{Name: "a"}}); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the writer error, got %v", err)
+	}
+}
@@ -2,11 +2,15 @@ package dethcl
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
 	"sort"
 	"strings"
 
 	"github.com/genelet/horizon/utils"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // mapStructureType represents different types of map structures.
@@ -18,10 +22,26 @@ const (
 	nestedMap  mapStructureType = 2 // Map with all values being maps
 )
 
+// genericMapType is the type of the maps written as generic HCL bodies.
+var genericMapType = reflect.TypeOf(map[string]any(nil))
+
+// genericMap returns item as a map[string]any, also if it is of a named
+// type such as type Doc map[string]any.
+func genericMap(item any) (map[string]any, bool) {
+	if m, ok := item.(map[string]any); ok {
+		return m, true
+	}
+	rv := reflect.ValueOf(item)
+	if rv.Kind() != reflect.Map || !rv.Type().ConvertibleTo(genericMapType) {
+		return nil, false
+	}
+	return rv.Convert(genericMapType).Interface().(map[string]any), true
+}
+
 // classifyMapStructure determines if an item is a map and what type of map it is.
 // Returns the map type and the map itself (nil if not a map).
 func classifyMapStructure(item any) (mapStructureType, map[string]any) {
-	m, ok := item.(map[string]any)
+	m, ok := genericMap(item)
 	if !ok {
 		return notAMap, nil
 	}
@@ -33,7 +53,7 @@ func classifyMapStructure(item any) (mapStructureType, map[string]any) {
 	// Check if all values are maps
 	allMaps := true
 	for _, v := range m {
-		if _, ok := v.(map[string]any); !ok {
+		if _, ok := genericMap(v); !ok {
 			allMaps = false
 			break
 		}
@@ -50,10 +70,14 @@ func classifyMapStructure(item any) (mapStructureType, map[string]any) {
 // Returns: (primitiveString, recursiveBytes, error)
 // - If primitiveString != "", use that (it's a simple value)
 // - If recursiveBytes != nil, use that (it's a complex value)
-func encodePrimitiveOrRecurse(item any, equal bool, level int) (string, []byte, error) {
+func encodePrimitiveOrRecurse(opts *MarshalOptions, item any, equal bool, level int) (string, []byte, error) {
+	if name, ok := enumName(reflect.ValueOf(item)); ok {
+		return fmt.Sprintf("%q", name), nil, nil
+	}
 	switch item.(type) {
 	case string:
-		return fmt.Sprintf("\"%s\"", item), nil, nil
+		// quoted and escaped, including newlines, quotes and ${
+		return string(hclwrite.TokensForValue(cty.StringVal(item.(string))).Bytes()), nil, nil
 	case bool:
 		return fmt.Sprintf("%t", item), nil, nil
 	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
@@ -73,15 +97,22 @@ func encodePrimitiveOrRecurse(item any, equal bool, level int) (string, []byte,
 		default:
 		}
 		return fmt.Sprintf("%f", n), nil, nil
+	case *big.Int:
+		return item.(*big.Int).String(), nil, nil
+	case *big.Float:
+		return item.(*big.Float).Text('g', -1), nil, nil
 	default:
 	}
 
-	bs, err := marshalLevel(item, equal, level+1)
+	bs, err := marshalLevel(opts, item, equal, level+1)
 	return "", bs, err
 }
 
-func loopHash(lines *[]string, header string, item any, equal bool, depth, level int, keyname ...string) error {
+func loopHash(opts *MarshalOptions, lines *[]string, header string, item any, equal bool, depth, level int, keyname ...string) error {
 	mapType, nextMap := classifyMapStructure(item)
+	if opts.terraform() && mapType != notAMap {
+		return terraformHash(opts, lines, header, nextMap, depth, level)
+	}
 
 	// Limit HCL labels to 2. If deeper, treat as block body.
 	if depth >= 2 && mapType == nestedMap {
@@ -100,42 +131,70 @@ func loopHash(lines *[]string, header string, item any, equal bool, depth, level
 		for _, key := range keys {
 			value := nextMap[key]
 			nextHeader := header + ` "` + key + `"`
-			err := loopHash(lines, nextHeader, value, false, depth+1, level)
+			err := loopHash(opts, lines, nextHeader, value, false, depth+1, level)
 			if err != nil {
 				return err
 			}
 		}
 	case shallowMap:
+		// a key such as "$hcl" or "a.b" is no attribute name, so such a map
+		// is an object value, in which the key is quoted
+		if depth == 0 && hasQuotedKey(nextMap) {
+			bs, err := marshalLevel(opts, item, true, level+1, markerNoBrackets)
+			if err != nil {
+				return err
+			}
+			*lines = append(*lines, header+" = "+string(bs))
+			return nil
+		}
 		// pass 'header' as the keyname to the next 'default' below
-		bs, err := marshalLevel(item, equal, level+1, header)
+		bs, err := marshalLevel(opts, item, equal, level+1, header)
 		if err != nil {
 			return err
 		}
-		*lines = append(*lines, fmt.Sprintf("%s %s", header, bs))
+		*lines = append(*lines, header+" "+string(bs))
 	default:
-		str, bs, err := encodePrimitiveOrRecurse(item, equal, level)
+		str, bs, err := encodePrimitiveOrRecurse(opts, item, equal, level)
 		if err != nil {
 			return err
 		}
 		if bs != nil && len(keyname) > 0 && matchlast(keyname[0], string(bs)) {
 			return nil
 		}
-		if str != "" {
-			*lines = append(*lines, fmt.Sprintf("%s = %s", header, str))
-		} else {
-			*lines = append(*lines, fmt.Sprintf("%s = %s", header, bs))
+		if str == "" {
+			str = string(bs)
 		}
+		*lines = append(*lines, header+" = "+str)
 	}
 	return nil
 }
 
+// objectKey returns k as a key of an object value, quoted if it is not a
+// valid identifier.
+func objectKey(k string) string {
+	if hclsyntax.ValidIdentifier(k) {
+		return k
+	}
+	return string(hclwrite.TokensForValue(cty.StringVal(k)).Bytes())
+}
+
+// hasQuotedKey reports whether a key of m is not a valid identifier.
+func hasQuotedKey(m map[string]any) bool {
+	for k := range m {
+		if !hclsyntax.ValidIdentifier(k) {
+			return true
+		}
+	}
+	return false
+}
+
 func matchlast(keyname string, name string) bool {
 	names := strings.Split(keyname, " ")
 	keyname = names[len(names)-1]
 	return keyname == name
 }
 
-func encoding(current any, equal bool, level int, keyname ...string) ([]byte, error) {
+func encoding(opts *MarshalOptions, current any, equal bool, level int, keyname ...string) ([]byte, error) {
 	var str string
 	if current == nil {
 		return nil, nil
@@ -144,16 +203,19 @@ func encoding(current any, equal bool, level int, keyname ...string) ([]byte, er
 	rv := reflect.ValueOf(current)
 	switch rv.Kind() {
 	case reflect.Struct:
-		return marshalLevel(current, false, level, keyname...)
+		return marshalLevel(opts, current, false, level, keyname...)
 	case reflect.Pointer:
-		return marshalLevel(rv.Elem().Interface(), equal, level, keyname...)
+		if !isBigNumber(rv) {
+			return marshalLevel(opts, rv.Elem().Interface(), equal, level, keyname...)
+		}
+		str, _, _ = encodePrimitiveOrRecurse(opts, current, equal, level)
 	case reflect.Map:
-		return encodeMap(rv, equal, level, keyname...)
+		return encodeMap(opts, rv, equal, level, keyname...)
 	case reflect.Slice, reflect.Array:
-		return encodeSlice(rv, level)
+		return encodeSlice(opts, rv, level)
 	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64:
 		var err error
-		str, _, err = encodePrimitiveOrRecurse(current, equal, level)
+		str, _, err = encodePrimitiveOrRecurse(opts, current, equal, level)
 		if err != nil {
 			return nil, err
 		}
@@ -164,55 +226,69 @@ func encoding(current any, equal bool, level int, keyname ...string) ([]byte, er
 	return []byte(str), nil
 }
 
-func encodeMap(rv reflect.Value, equal bool, level int, keyname ...string) ([]byte, error) {
-	var arr []string
-	iter := rv.MapRange()
-	for iter.Next() {
-		key := iter.Key()
-		if key.Kind() != reflect.String {
-			return nil, fmt.Errorf("map key must be string, got %v", key.Kind())
-		}
-		switch iter.Value().Kind() {
+func encodeMap(opts *MarshalOptions, rv reflect.Value, equal bool, level int, keyname ...string) ([]byte, error) {
+	if rv.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("map key must be string, got %v", rv.Type().Key().Kind())
+	}
+	keys := make([]string, 0, rv.Len())
+	for _, key := range rv.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	// attributes come first, then blocks, each in key order, so that
+	// { "b": {"c": 2}, "a": 1 } gives a = 1 followed by b { c = 2 }
+	arr := make([]string, 0, rv.Len())
+	var blocks []string
+	for _, k := range keys {
+		value := rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()))
+		switch value.Kind() {
 		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func:
-			if iter.Value().IsNil() {
-				arr = append(arr, fmt.Sprintf("%s = null", key.String()))
+			if value.IsNil() {
+				arr = append(arr, k+" = null")
 				continue
 			}
 		default:
 		}
 		if len(keyname) > 0 && keyname[0] == markerNoBrackets {
-			str, bs, err := encodePrimitiveOrRecurse(iter.Value().Interface(), equal, level)
+			str, bs, err := encodePrimitiveOrRecurse(opts, value.Interface(), equal, level)
 			if err != nil {
 				return nil, err
 			}
-			if str != "" {
-				arr = append(arr, fmt.Sprintf("%s = %s", key.String(), str))
-			} else {
-				arr = append(arr, fmt.Sprintf("%s = %s", key.String(), bs))
-			}
-		} else {
-			err := loopHash(&arr, key.String(), iter.Value().Interface(), equal, 0, level, keyname...)
-			if err != nil {
-				return nil, err
+			if str == "" {
+				str = string(bs)
 			}
+			arr = append(arr, objectKey(k)+" = "+str)
+			continue
+		}
+		lines := &arr
+		if mapType, _ := classifyMapStructure(value.Interface()); mapType != notAMap && !opts.terraform() {
+			lines = &blocks
+		}
+		err := loopHash(opts, lines, k, value.Interface(), equal, 0, level, keyname...)
+		if err != nil {
+			return nil, err
 		}
 	}
+	arr = append(arr, blocks...)
 
-	leading := strings.Repeat("  ", level+1)
-	lessLeading := strings.Repeat("  ", level)
-	var str string
-	if level == 0 {
-		str = fmt.Sprintf("\n%s\n%s", leading+strings.Join(arr, "\n"+leading), lessLeading)
-	} else {
-		str = fmt.Sprintf("{\n%s\n%s}", leading+strings.Join(arr, "\n"+leading), lessLeading)
+	leading := opts.indent(level + 1)
+	lessLeading := opts.indent(level)
+	str := "\n" + leading + strings.Join(arr, "\n"+leading) + "\n" + lessLeading
+	if level > 0 {
+		str = "{" + str + "}"
 	}
 	return []byte(str), nil
 }
 
-func encodeSlice(rv reflect.Value, level int) ([]byte, error) {
-	var arr []string
+func encodeSlice(opts *MarshalOptions, rv reflect.Value, level int) ([]byte, error) {
+	if isNestedSimpleSlice(rv.Type()) {
+		return encodeInlineSlice(rv)
+	}
+
+	arr := make([]string, 0, rv.Len())
 	for i := 0; i < rv.Len(); i++ {
-		bs, err := marshalLevel(rv.Index(i).Interface(), true, level+1, markerNoBrackets)
+		bs, err := marshalLevel(opts, rv.Index(i).Interface(), true, level+1, markerNoBrackets)
 		if err != nil {
 			return nil, err
 		}
@@ -223,8 +299,130 @@ func encodeSlice(rv reflect.Value, level int) ([]byte, error) {
 		arr = append(arr, item)
 	}
 
-	leading := strings.Repeat("  ", level+1)
-	lessLeading := strings.Repeat("  ", level)
-	str := fmt.Sprintf("[\n%s\n%s]", leading+strings.Join(arr, ",\n"+leading), lessLeading)
+	leading := opts.indent(level + 1)
+	lessLeading := opts.indent(level)
+	if opts.inlineSlices() && isPrimitiveSlice(rv) {
+		str := "[" + strings.Join(arr, ", ") + "]"
+		if !strings.Contains(str, "\n") && len(leading)+len(str) <= inlineSliceWidth {
+			return []byte(str), nil
+		}
+	}
+	str := "[\n" + leading + strings.Join(arr, ",\n"+leading) + "\n" + lessLeading + "]"
 	return []byte(str), nil
 }
+
+// isNestedSimpleSlice reports whether typ is a slice of slices whose innermost
+// elements are primitives, e.g. [][]int or [][]string.
+func isNestedSimpleSlice(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Slice && typ.Kind() != reflect.Array {
+		return false
+	}
+	elem := typ.Elem()
+	if elem.Kind() != reflect.Slice && elem.Kind() != reflect.Array {
+		return false
+	}
+	for elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array {
+		elem = elem.Elem()
+	}
+	return isPrimitiveKind(elem.Kind())
+}
+
+// isPrimitiveKind reports whether values of kind are strings, numbers or
+// booleans.
+func isPrimitiveKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// isBigType reports whether typ is big.Int or big.Float, or a pointer to
+// one. Though text types, they are written as number literals.
+func isBigType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ == bigIntType || typ == bigFloatType
+}
+
+// bigNumber returns a copy of v, a value of a big type, as a *big.Int or a
+// *big.Float.
+func bigNumber(v reflect.Value) any {
+	if v.Kind() != reflect.Pointer {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	}
+	if x, ok := v.Interface().(*big.Int); ok {
+		return new(big.Int).Set(x)
+	}
+	return new(big.Float).Copy(v.Interface().(*big.Float))
+}
+
+// isBigNumber reports whether rv is a *big.Int or a *big.Float, which are
+// written as number literals.
+func isBigNumber(rv reflect.Value) bool {
+	switch rv.Interface().(type) {
+	case *big.Int, *big.Float:
+		return !rv.IsNil()
+	default:
+		return false
+	}
+}
+
+// isPrimitiveSlice reports whether every element of rv, a slice or an array,
+// is a primitive or null, looking into interfaces, e.g. []any{"a", 1}.
+func isPrimitiveSlice(rv reflect.Value) bool {
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		for (item.Kind() == reflect.Interface || item.Kind() == reflect.Pointer) && !item.IsNil() && !isBigNumber(item) {
+			item = item.Elem()
+		}
+		switch {
+		case isPrimitiveKind(item.Kind()), isBigNumber(item):
+		case item.Kind() == reflect.Interface || item.Kind() == reflect.Pointer:
+			// nil, written as null
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// encodeInlineSlice encodes a nested slice of primitives as array literals on
+// one line, e.g. [[1, 2], [3, 4]].
+func encodeInlineSlice(rv reflect.Value) ([]byte, error) {
+	arr := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		switch item.Kind() {
+		case reflect.Slice, reflect.Array:
+			if item.Kind() == reflect.Slice && item.IsNil() {
+				arr = append(arr, "null")
+				continue
+			}
+			bs, err := encodeInlineSlice(item)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, string(bs))
+		default:
+			str, _, err := encodePrimitiveOrRecurse(nil, item.Interface(), true, 0)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, str)
+		}
+	}
+	return []byte("[" + strings.Join(arr, ", ") + "]"), nil
+}
@@ -0,0 +1,108 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// intEnum is the name table of an integer type registered with RegisterIntEnum.
+type intEnum struct {
+	names  map[int64]string
+	values map[string]int64
+}
+
+// intEnums maps the reflect.Type of each registered integer type to its *intEnum.
+var intEnums sync.Map
+
+// RegisterIntEnum registers the symbolic names of the integer type T, usually
+// a set of constants declared with iota. Marshal writes a field of type T as
+// its quoted name, and Unmarshal parses the name back. A number is accepted by
+// Unmarshal as well, and values without a name are written as numbers.
+//
+// Registering T again replaces its names. RegisterIntEnum panics if two
+// values share a name, like gob.Register does for conflicting types.
+//
+// Example:
+//
+//	type Level int
+//
+//	const (
+//	    Debug Level = iota
+//	    Info
+//	    Warn
+//	)
+//
+//	dethcl.RegisterIntEnum(map[Level]string{Debug: "debug", Info: "info", Warn: "warn"})
+//
+//	type Config struct {
+//	    Level Level `hcl:"level"`
+//	}
+//	// Config{Level: Warn} is written as level = "warn"
+func RegisterIntEnum[T ~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32](names map[T]string) {
+	enum := &intEnum{
+		names:  make(map[int64]string, len(names)),
+		values: make(map[string]int64, len(names)),
+	}
+	for value, name := range names {
+		if other, ok := enum.values[name]; ok {
+			panic(fmt.Sprintf("dethcl: RegisterIntEnum: name %q used by both %d and %d", name, other, int64(value)))
+		}
+		enum.names[int64(value)] = name
+		enum.values[name] = int64(value)
+	}
+	intEnums.Store(reflect.TypeOf(T(0)), enum)
+}
+
+// lookupIntEnum returns the name table of typ, or nil if typ is not registered.
+func lookupIntEnum(typ reflect.Type) *intEnum {
+	enum, ok := intEnums.Load(typ)
+	if !ok {
+		return nil
+	}
+	return enum.(*intEnum)
+}
+
+// enumName returns the registered name of v, if its type is a registered
+// enum and the value has a name.
+func enumName(v reflect.Value) (string, bool) {
+	if !v.IsValid() {
+		return "", false
+	}
+	enum := lookupIntEnum(v.Type())
+	if enum == nil {
+		return "", false
+	}
+	var n int64
+	if v.CanInt() {
+		n = v.Int()
+	} else {
+		n = int64(v.Uint())
+	}
+	name, ok := enum.names[n]
+	return name, ok
+}
+
+// enumValue converts a string ctyVal to a value of the registered enum type
+// typ. It returns ok false if typ is not registered or ctyVal is not a
+// string, leaving numbers to the regular conversion.
+func enumValue(ctyVal cty.Value, typ reflect.Type) (any, bool, error) {
+	enum := lookupIntEnum(typ)
+	if enum == nil || ctyVal.IsNull() || !ctyVal.IsKnown() || ctyVal.Type() != cty.String {
+		return nil, false, nil
+	}
+	name := ctyVal.AsString()
+	n, ok := enum.values[name]
+	if !ok {
+		return nil, true, fmt.Errorf("unknown %v name %q", typ, name)
+	}
+	v := reflect.New(typ).Elem()
+	if v.CanInt() {
+		v.SetInt(n)
+	} else {
+		v.SetUint(uint64(n))
+	}
+	return v.Interface(), true, nil
+}
@@ -0,0 +1,84 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+)
+
+func init() {
+	RegisterIntEnum(map[logLevel]string{levelDebug: "debug", levelInfo: "info", levelWarn: "warn"})
+}
+
+func TestIntEnum(t *testing.T) {
+	type logger struct {
+		Name  string   `hcl:"name"`
+		Level logLevel `hcl:"level"`
+		Floor logLevel `hcl:"floor,optional"`
+	}
+	cfg := &logger{Name: "app", Level: levelWarn}
+	bs, err := MarshalWithOptions(cfg, MarshalOptions{ShowOmitted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`level = "warn"`, `# floor = "debug"  (optional, omitted)`} {
+		if !strings.Contains(string(bs), line) {
+			t.Errorf("expected %s in:\n%s", line, bs)
+		}
+	}
+
+	var back logger
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != *cfg {
+		t.Errorf("got %#v", back)
+	}
+
+	// numbers are accepted, and values without a name are written as numbers
+	back = logger{}
+	if err := Unmarshal([]byte("name = \"app\"\nlevel = 1\nfloor = 7"), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Level != levelInfo || back.Floor != 7 {
+		t.Errorf("got %#v", back)
+	}
+	bs, err = Marshal(&back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "floor = 7") {
+		t.Errorf("expected floor = 7 in:\n%s", bs)
+	}
+
+	err = Unmarshal([]byte("name = \"app\"\nlevel = \"verbose\""), &back)
+	if err == nil || !strings.Contains(err.Error(), `unknown dethcl.logLevel name "verbose"`) {
+		t.Errorf("expected unknown name error, got %v", err)
+	}
+
+	bs, err = Marshal(map[string]any{"level": levelInfo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), `level = "info"`) {
+		t.Errorf("expected level = \"info\" in:\n%s", bs)
+	}
+}
+
+func TestRegisterIntEnumDuplicateName(t *testing.T) {
+	type color uint8
+	defer func() {
+		msg, _ := recover().(string)
+		if !strings.Contains(msg, `name "red"`) {
+			t.Errorf("expected panic for duplicate name, got %q", msg)
+		}
+	}()
+	RegisterIntEnum(map[color]string{0: "red", 1: "red"})
+}
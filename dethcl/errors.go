@@ -0,0 +1,102 @@
+package dethcl
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/genelet/horizon/utils"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// DethclError is returned when HCL data fails to parse. It keeps the
+// diagnostics of the parser, with their severities and byte ranges, and the
+// source they refer to, so that callers such as a CLI or a language server
+// can point at the exact spot:
+//
+//	var derr *dethcl.DethclError
+//	if errors.As(err, &derr) {
+//	    wr := hcl.NewDiagnosticTextWriter(os.Stderr, map[string]*hcl.File{
+//	        derr.Diagnostics[0].Subject.Filename: {Bytes: derr.Source},
+//	    }, 80, true)
+//	    wr.WriteDiagnostics(derr.Diagnostics)
+//	}
+//
+// Its message is the same as that of the plain error returned before.
+type DethclError struct {
+	Diagnostics hcl.Diagnostics
+	Source      []byte
+
+	summary string // e.g. "failed to parse HCL"
+	cause   error  // shown in the message, if not all the diagnostics
+}
+
+// newDethclError returns a DethclError for the diagnostics of parsing src.
+func newDethclError(summary string, diags hcl.Diagnostics, src []byte) *DethclError {
+	return &DethclError{Diagnostics: diags, Source: src, summary: summary}
+}
+
+func (e *DethclError) Error() string {
+	if e.cause != nil {
+		return e.summary + ": " + e.cause.Error()
+	}
+	return e.summary + ": " + e.Diagnostics.Error()
+}
+
+// Unwrap returns the diagnostics, so that errors.As also finds them as
+// hcl.Diagnostics.
+func (e *DethclError) Unwrap() error {
+	return e.Diagnostics
+}
+
+// FieldError is returned when the value of an attribute cannot be evaluated
+// or converted to the type of its field, when a block or its labels cannot
+// be decoded, or when Validate fails. Path is the dot-joined HCL names
+// from the root, with block labels, e.g. service.api.port, so that callers
+// can point at the field however deep it is:
+//
+//	var ferr *dethcl.FieldError
+//	if errors.As(err, &ferr) {
+//	    fmt.Printf("invalid value for %s\n", ferr.Path)
+//	}
+//
+// Its message starts with the path.
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+// newFieldError returns a FieldError for the attribute name of the block at
+// node.
+func newFieldError(node *utils.Tree, name string, err error) *FieldError {
+	var path []string
+	if node != nil {
+		path = node.Path()
+	}
+	return &FieldError{Path: strings.Join(append(path, name), "."), Err: err}
+}
+
+// blockFieldError returns err, the failure of the block at node, as a
+// FieldError with the path of the block, e.g. service.api, unless err holds a
+// FieldError already, which names the field inside the block that failed.
+// The keys, such as the index of an unlabeled block in a list, are appended
+// to the path.
+func blockFieldError(node *utils.Tree, err error, keys ...string) error {
+	var ferr *FieldError
+	if errors.As(err, &ferr) {
+		return err
+	}
+	var path []string
+	if node != nil {
+		path = node.Path()
+	}
+	return &FieldError{Path: strings.Join(append(path, keys...), "."), Err: err}
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the error of the field.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
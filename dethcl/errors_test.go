@@ -0,0 +1,91 @@
+package dethcl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+func TestDethclError(t *testing.T) {
+	type config struct {
+		Name string `hcl:"name"`
+		Port int    `hcl:"port"`
+	}
+	data := []byte("name = \"api\"\nport = = 80\n")
+
+	err := Unmarshal(data, new(config))
+	var derr *DethclError
+	if !errors.As(err, &derr) {
+		t.Fatalf("expected a DethclError, got %T %v", err, err)
+	}
+	if !strings.HasPrefix(err.Error(), "failed to parse HCL: ") {
+		t.Errorf("got message %q", err.Error())
+	}
+	if string(derr.Source) != string(data) || len(derr.Diagnostics) == 0 {
+		t.Fatalf("got %#v", derr)
+	}
+	diag := derr.Diagnostics[0]
+	if diag.Severity != hcl.DiagError || diag.Subject == nil || diag.Subject.Start.Line != 2 {
+		t.Errorf("got %#v", diag)
+	}
+	if got := string(data[diag.Subject.Start.Byte:diag.Subject.End.Byte]); got != "=" {
+		t.Errorf("subject %q", got)
+	}
+
+	var diags hcl.Diagnostics
+	if !errors.As(err, &diags) || len(diags) != len(derr.Diagnostics) {
+		t.Errorf("expected hcl.Diagnostics from %v", err)
+	}
+
+	// a map names only the first error, but keeps all the diagnostics
+	var m map[string]any
+	data = []byte("name = \"\\q\"\nport = \"\\z\"\n")
+	err = Unmarshal(data, &m)
+	if !errors.As(err, &derr) || len(derr.Diagnostics) < 2 {
+		t.Fatalf("got %T %v", err, err)
+	}
+	if want := "failed to parse map HCL: " + derr.Diagnostics.Errs()[0].Error(); err.Error() != want {
+		t.Errorf("got message %q, want %q", err.Error(), want)
+	}
+}
+
+func TestFieldError(t *testing.T) {
+	type listener struct {
+		Port int `hcl:"port"`
+	}
+	type service struct {
+		Name     string    `hcl:"name,label"`
+		Listener *listener `hcl:"listener,block"`
+	}
+	type config struct {
+		Region   string              `hcl:"region,optional"`
+		Services map[string]*service `hcl:"service,block"`
+		Ports    map[int]*listener   `hcl:"port,block,optional"`
+	}
+
+	tests := []struct {
+		data string
+		path string
+		want string
+	}{
+		{"service \"api\" {\n  listener {\n    port = \"http\"\n  }\n}\n", "service.api.listener.port", "number value is required"},
+		{"service \"api\" {\n  listener {\n    port = 80 + \"a\"\n  }\n}\n", "service.api.listener.port", "failed to evaluate expression"},
+		{"region = [1]\n", "region", "string value is required"},
+		{"port \"http\" {\n  port = 80\n}\n", "port.http", "not a valid int key"},
+	}
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.data), new(config))
+		var ferr *FieldError
+		if !errors.As(err, &ferr) {
+			t.Fatalf("expected a FieldError, got %v", err)
+		}
+		if ferr.Path != tt.path || !strings.Contains(ferr.Error(), tt.want) {
+			t.Errorf("got %q, %v", ferr.Path, ferr)
+		}
+		if !strings.HasPrefix(err.Error(), tt.path+": ") {
+			t.Errorf("path missing from %v", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldInfo is what getFields needs to know about a struct field before
+// looking at its value. It holds no reflect.Value, so it can be shared by
+// all values of the struct type.
+type fieldInfo struct {
+	index    int
+	field    reflect.StructField
+	tagName  string
+	modifier string
+	// embedded is an anonymous field without tag name, whose fields are
+	// inlined
+	embedded bool
+	// omitEmpty is tagged omitempty
+	omitEmpty bool
+	// optional is tagged optional or omitempty, or has no tag name
+	optional bool
+	// repeated is tagged repeated
+	repeated bool
+	// collectionPtr is a pointer to a slice or a map, e.g. *map[string]*Example
+	collectionPtr bool
+	// text is a time or a text type, written as a string
+	text bool
+	// attrTag, blockTag and omittedTag are the tags given to the field as
+	// an attribute or block, when it has no tag name, and as an omitted
+	// optional field
+	attrTag, blockTag, omittedTag reflect.StructTag
+}
+
+// fieldCacheKey identifies the []fieldInfo of a struct type, which differ
+// with MarshalOptions.UseJSONTagFallback.
+type fieldCacheKey struct {
+	structType reflect.Type
+	jsonTags   bool
+}
+
+// fieldInfos maps the fieldCacheKey of each struct marshaled so far to its
+// []fieldInfo.
+var fieldInfos sync.Map
+
+// cachedFields returns the fieldInfo of the exported fields of structType
+// that are marshaled, i.e. neither ignored nor comment fields, in order.
+// With jsonTags, fields without hcl tag are named by their json tag, see
+// jsonTagName.
+func cachedFields(structType reflect.Type, jsonTags bool) []fieldInfo {
+	key := fieldCacheKey{structType, jsonTags}
+	if infos, ok := fieldInfos.Load(key); ok {
+		return infos.([]fieldInfo)
+	}
+	infos := make([]fieldInfo, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tagParts := parseHCLTag(field.Tag)
+		tagName, modifier := tagParts[0], tagParts[1]
+		if tagName == tagIgnore || (len(tagName) >= 2 && tagName[len(tagName)-2:] == tagIgnoreSuffix) {
+			continue
+		}
+		if isCommentField(field) {
+			continue
+		}
+		fieldType := field.Type
+		name, _ := HCLName(field)
+		embedded := field.Anonymous && tagName == ""
+		attrModifier := tagModifierOptional
+		omitEmpty := hasTagOption(modifier, tagModifierOmitEmpty)
+		if jsonTags && tagName == "" {
+			jsonName, jsonOmitEmpty, ok := jsonTagName(field)
+			if !ok {
+				continue
+			}
+			if jsonName != "" {
+				// a named embedded struct is a field of its own, as in JSON
+				name, embedded = jsonName, false
+			}
+			if jsonOmitEmpty {
+				attrModifier, omitEmpty = tagModifierOmitEmpty, true
+			}
+		}
+		info := fieldInfo{
+			index:      i,
+			field:      field,
+			tagName:    tagName,
+			modifier:   modifier,
+			embedded:   embedded,
+			omitEmpty:  omitEmpty,
+			optional:   tagName == "" || optionalTag(modifier),
+			repeated:   hasTagOption(modifier, tagModifierRepeated),
+			omittedTag: reflect.StructTag(fmt.Sprintf(`hcl:"%s,%s"`, name, tagModifierOptional)),
+		}
+		info.collectionPtr = fieldType.Kind() == reflect.Pointer && (fieldType.Elem().Kind() == reflect.Slice || fieldType.Elem().Kind() == reflect.Map)
+		if info.collectionPtr {
+			fieldType = fieldType.Elem()
+		}
+		info.text = isTimeType(fieldType) || isTextType(fieldType)
+		if tagName == "" {
+			// keep other tags, e.g. hclprefix, after the generated one
+			info.attrTag = reflect.StructTag(strings.TrimSpace(fmt.Sprintf(`hcl:"%s,%s" %s`, name, attrModifier, field.Tag)))
+			info.blockTag = reflect.StructTag(strings.TrimSpace(fmt.Sprintf(`hcl:"%s,%s" %s`, name, tagModifierBlock, field.Tag)))
+		}
+		infos = append(infos, info)
+	}
+	actual, _ := fieldInfos.LoadOrStore(key, infos)
+	return actual.([]fieldInfo)
+}
+
+// jsonTagName returns the name and the omitempty option of the json tag of
+// field, e.g. ("port", true) for `json:"port,omitempty"`. The name is empty
+// without json tag or with an empty name, as in `json:",omitempty"`. It
+// returns false for a field left out of JSON by `json:"-"`.
+func jsonTagName(field reflect.StructField) (name string, omitEmpty, ok bool) {
+	tag, found := field.Tag.Lookup("json")
+	if !found {
+		return "", false, true
+	}
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	return name, hasTagOption(options, tagModifierOmitEmpty), true
+}
@@ -0,0 +1,70 @@
+package dethcl
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type cachedInner struct {
+	Port int `hcl:"port"`
+}
+
+type cachedOuter struct {
+	Name    string
+	Skipped string                   `hcl:"-"`
+	Tags    []string                 `hcl:"tags,optional"`
+	Items   *map[string]*cachedInner `hcl:"item,block"`
+	Inner   *cachedInner             `hcl:"inner,block"`
+	Extra   map[string]string        `hcl:"extra,omitempty"`
+	hidden  int
+}
+
+func TestCachedFields(t *testing.T) {
+	typ := reflect.TypeOf(cachedOuter{})
+	infos := cachedFields(typ, false)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.field.Name)
+	}
+	if strings.Join(names, ",") != "Name,Tags,Items,Inner,Extra" {
+		t.Errorf("got %v", names)
+	}
+	if !infos[0].optional || infos[1].text || !infos[2].collectionPtr || !infos[4].omitEmpty {
+		t.Errorf("got %#v", infos)
+	}
+	if infos[0].attrTag != `hcl:"name,optional"` || infos[0].blockTag != `hcl:"name,block"` {
+		t.Errorf("got tags %q and %q", infos[0].attrTag, infos[0].blockTag)
+	}
+	if again := cachedFields(typ, false); &again[0] != &infos[0] {
+		t.Errorf("fields of %v are not cached", typ)
+	}
+
+	// values of the same type share the cache
+	items := map[string]*cachedInner{"x": {Port: 9}}
+	var wg sync.WaitGroup
+	outputs := make([]string, 8)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bs, err := Marshal(&cachedOuter{Name: "web", Tags: []string{"a"}, Items: &items, Inner: &cachedInner{Port: i + 1}})
+			if err != nil {
+				t.Error(err)
+			}
+			outputs[i] = string(bs)
+		}(i)
+	}
+	wg.Wait()
+	for i, out := range outputs {
+		for _, want := range []string{`name = "web"`, `tags = ["a"]`, "inner {", `item "x" {`, "port = " + string(rune('1'+i))} {
+			if !strings.Contains(out, want) {
+				t.Errorf("%d: missing %q in\n%s", i, want, out)
+			}
+		}
+		if strings.Contains(out, "extra") {
+			t.Errorf("%d: empty omitempty map written\n%s", i, out)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package dethcl
+
+// Format rewrites HCL data in a canonical form, e.g. to detect drift between
+// configurations that differ only in layout. The data is decoded into a
+// map[string]any and written back as MarshalBody does: attributes come
+// first and blocks after them, each in key order, labeled blocks in the
+// order of their labels, with the spacing of hclwrite. Comments are dropped.
+//
+// Format is idempotent: Format of its own output returns the same bytes. The
+// first pass may change the shape of the data, e.g. a block holding a single
+// block, nested { inner { x = 1 } }, is written with a label as
+// nested "inner" { x = 1 }, and repeated unlabeled blocks as a list of
+// objects, as Marshal writes any map[string]any. Empty data gives nil.
+func Format(hclData []byte) ([]byte, error) {
+	m := make(map[string]any)
+	if err := Unmarshal(hclData, &m); err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return MarshalBody(m)
+}
@@ -0,0 +1,149 @@
+package dethcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	data := `
+zeta = 1
+alpha   =  "a"   # a comment
+obj = { b = 1, a = 2 }
+service "web" {
+  port = 80
+  name = "w"
+}
+service "api" {
+  port = 81
+}
+resource "aws_instance" "x" {
+  b = 2
+  a = 1
+}
+step {
+  a = 1
+}
+step {
+  a = 2
+}
+`
+	want := `alpha = "a"
+step = [
+  {
+    a = 1
+  },
+  {
+    a = 2
+  }
+]
+zeta = 1
+obj {
+  a = 2
+  b = 1
+}
+resource "aws_instance" "x" {
+  a = 1
+  b = 2
+}
+service "api" {
+  port = 81
+}
+service "web" {
+  name = "w"
+  port = 80
+}
+`
+	got, err := Format([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	again, err := Format(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(got) {
+		t.Errorf("not idempotent:\n%s\nthen\n%s", got, again)
+	}
+
+	// the same configuration laid out differently gives the same bytes
+	reordered := `
+service "web" {
+  name = "w"
+  port = 80
+}
+obj = { a = 2, b = 1 }
+resource "aws_instance" "x" {
+  a = 1
+  b = 2
+}
+step {
+  a = 1
+}
+step {
+  a = 2
+}
+service "api" { port = 81 }
+alpha = "a"
+zeta  = 1
+`
+	other, err := Format([]byte(reordered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(other) != string(got) {
+		t.Errorf("got\n%s\nwant\n%s", other, got)
+	}
+}
+
+func TestFormatEscapedStrings(t *testing.T) {
+	data := `
+newline = "a\nb"
+quote   = "say \"hi\""
+tmpl    = "$${x} and %%{y}"
+tab     = "a\tb\\c"
+nested = {
+  s = "x\ny"
+}
+list = ["\"", "$${z}"]
+`
+	want := map[string]any{
+		"newline": "a\nb",
+		"quote":   `say "hi"`,
+		"tmpl":    "${x} and %{y}",
+		"tab":     "a\tb\\c",
+		"nested":  map[string]any{"s": "x\ny"},
+		"list":    []any{`"`, "${z}"},
+	}
+	bs, err := Format([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Format(bs)
+	if err != nil {
+		t.Fatalf("%v in\n%s", err, bs)
+	}
+	if string(again) != string(bs) {
+		t.Errorf("not idempotent, got:\n%s\nthen:\n%s", bs, again)
+	}
+	got := make(map[string]any)
+	if err := Unmarshal(bs, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v from\n%s", got, bs)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	if got, err := Format(nil); err != nil || got != nil {
+		t.Errorf("empty: got %q, %v", got, err)
+	}
+	if _, err := Format([]byte("name = ")); err == nil {
+		t.Error("expected a parse error")
+	}
+}
@@ -8,13 +8,14 @@ import (
 
 // hclFieldInfo contains parsed information about a struct field's HCL tags.
 type hclFieldInfo struct {
-	Field    reflect.StructField
-	Value    reflect.Value
-	TagName  string // e.g., "name"
-	Modifier string // e.g., "label", "block", "optional"
-	IsLabel  bool
-	IsBlock  bool
-	IsIgnore bool
+	Field       reflect.StructField
+	Value       reflect.Value
+	TagName     string // e.g., "name"
+	Modifier    string // e.g., "label", "block", "optional"
+	IsLabel     bool
+	IsBlock     bool
+	IsIgnore    bool
+	IsSensitive bool
 }
 
 // parseFieldInfo extracts HCL tag information from a struct field.
@@ -35,13 +36,14 @@ func parseFieldInfo(field reflect.StructField, value reflect.Value) *hclFieldInf
 	}
 
 	info := &hclFieldInfo{
-		Field:    field,
-		Value:    value,
-		TagName:  tagName,
-		Modifier: modifier,
-		IsLabel:  strings.ToLower(modifier) == tagModifierLabel,
-		IsBlock:  strings.Contains(strings.ToLower(modifier), tagModifierBlock),
-		IsIgnore: false,
+		Field:       field,
+		Value:       value,
+		TagName:     tagName,
+		Modifier:    modifier,
+		IsLabel:     strings.ToLower(modifier) == tagModifierLabel,
+		IsBlock:     strings.Contains(strings.ToLower(modifier), tagModifierBlock),
+		IsIgnore:    false,
+		IsSensitive: hasTagOption(modifier, tagModifierSensitive),
 	}
 
 	return info
@@ -86,9 +88,7 @@ func getStructFields(t reflect.Type, oriValue reflect.Value) ([]*hclFieldInfo, e
 		}
 
 		// Auto-generate tag name from field name if not specified
-		if info.TagName == "" {
-			info.TagName = strings.ToLower(field.Name)
-		}
+		info.TagName, _ = HCLName(field)
 
 		fields = append(fields, info)
 	}
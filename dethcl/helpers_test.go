@@ -377,3 +377,53 @@ func TestCategorizeFields(t *testing.T) {
 		}
 	}
 }
+
+func TestHCLName(t *testing.T) {
+	type sample struct {
+		Port    int `hcl:"port,optional"`
+		Timeout int
+		Secret  string `hcl:"-"`
+		Kind    string `hcl:",label"`
+	}
+	typ := reflect.TypeOf(sample{})
+	tests := []struct {
+		field    string
+		name     string
+		modifier string
+	}{
+		{"Port", "port", "optional"},
+		{"Timeout", "timeout", ""},
+		{"Secret", "-", ""},
+		{"Kind", "kind", "label"},
+	}
+	for _, tt := range tests {
+		field, _ := typ.FieldByName(tt.field)
+		name, modifier := HCLName(field)
+		if name != tt.name || modifier != tt.modifier {
+			t.Errorf("HCLName(%s) = %q, %q, want %q, %q", tt.field, name, modifier, tt.name, tt.modifier)
+		}
+	}
+}
+
+func TestUntaggedRoundTrip(t *testing.T) {
+	type inner struct {
+		Size int
+	}
+	type outer struct {
+		Name    string
+		Retries int
+		Inner   *inner
+	}
+	o := &outer{Name: "api", Retries: 3, Inner: &inner{Size: 5}}
+	bs, err := Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(outer)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o, got) {
+		t.Errorf("round trip: got %#v from\n%s", got, bs)
+	}
+}
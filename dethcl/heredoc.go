@@ -0,0 +1,124 @@
+package dethcl
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// heredocDelimiter is the marker of the heredocs written by Marshal, followed
+// by a number when a line of the string already reads EOT.
+const heredocDelimiter = "EOT"
+
+// isHeredocString reports whether s is written as a heredoc. A heredoc ends
+// its string with a newline, so only multi-line strings ending with one are,
+// e.g. a script read from a file; others stay quoted with \n escapes.
+// Carriage returns would not survive the lines of a heredoc.
+func isHeredocString(s string) bool {
+	return len(s) > 1 && strings.HasSuffix(s, "\n") && strings.Contains(s[:len(s)-1], "\n") && !strings.Contains(s, "\r")
+}
+
+// heredocTokens returns s, as accepted by isHeredocString, as a heredoc:
+//
+//	<<EOT
+//	SELECT *
+//	FROM users
+//	EOT
+//
+// Template sequences in s, ${ and %{, are escaped as $${ and %%{.
+func heredocTokens(s string) hclwrite.Tokens {
+	delimiter := heredocDelimiter
+	for n := 1; heredocCollides(s, delimiter); n++ {
+		delimiter = heredocDelimiter + strconv.Itoa(n)
+	}
+	s = strings.ReplaceAll(s, "${", "$${")
+	s = strings.ReplaceAll(s, "%{", "%%{")
+	return hclwrite.Tokens{
+		{Type: hclsyntax.TokenOHeredoc, Bytes: []byte("<<" + delimiter + "\n"), SpacesBefore: 1},
+		{Type: hclsyntax.TokenStringLit, Bytes: []byte(s)},
+		{Type: hclsyntax.TokenCHeredoc, Bytes: []byte(delimiter)},
+	}
+}
+
+// heredocCollides reports whether a line of s would close a heredoc marked
+// by delimiter.
+func heredocCollides(s, delimiter string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) == delimiter {
+			return true
+		}
+	}
+	return false
+}
+
+// heredocLines returns the lines of src, counted from 0, that hold the body
+// or the closing marker of a heredoc and must not be indented, or nil if
+// there are none. Closing markers stay in the first column, where hclwrite
+// leaves them.
+func heredocLines(src []byte) map[int]bool {
+	if !bytes.Contains(src, []byte("<<")) {
+		return nil
+	}
+	tokens, _ := hclsyntax.LexConfig(src, "", hcl.InitialPos)
+	var lines map[int]bool
+	for i, tok := range tokens {
+		if tok.Type != hclsyntax.TokenOHeredoc {
+			continue
+		}
+		// the opening marker ends with its newline, the closing one starts
+		// its own line
+		for _, closing := range tokens[i+1:] {
+			if closing.Type != hclsyntax.TokenCHeredoc {
+				continue
+			}
+			for line := tok.Range.End.Line; line <= closing.Range.Start.Line; line++ {
+				if lines == nil {
+					lines = make(map[int]bool)
+				}
+				lines[line-1] = true
+			}
+			break
+		}
+	}
+	return lines
+}
+
+// indentLines prefixes every line of s with indentation, except the lines
+// of heredocs, whose content would change, see heredocLines.
+func indentLines(s, indentation string) string {
+	inside := heredocLines([]byte(s))
+	if inside == nil {
+		return indentation + strings.ReplaceAll(s, "\n", "\n"+indentation)
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if !inside[i] {
+			lines[i] = indentation + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// endsWithHeredoc reports whether the last token of s closes a heredoc, e.g.
+// the EOT of script = <<EOT ... EOT, which must be followed by a newline for
+// the heredoc to be terminated.
+func endsWithHeredoc(s string) bool {
+	if !strings.Contains(s, "<<") {
+		return false
+	}
+	tokens, _ := hclsyntax.LexConfig([]byte(s+"\n"), "", hcl.InitialPos)
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch tokens[i].Type {
+		case hclsyntax.TokenEOF, hclsyntax.TokenNewline:
+		case hclsyntax.TokenCHeredoc:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+)
+
+type script struct {
+	Name  string  `hcl:"name"`
+	Body  string  `hcl:"body"`
+	Query *string `hcl:"query,optional"`
+	Note  string  `hcl:"note,optional"`
+}
+
+type job struct {
+	Script *script `hcl:"script,block"`
+}
+
+func (s *script) HCLComment(field string) string {
+	if field == "Body" {
+		return "runs first"
+	}
+	return ""
+}
+
+func TestMarshalHeredoc(t *testing.T) {
+	query := "SELECT *\nFROM users\nWHERE name = '${name}'\n"
+	j := &job{Script: &script{
+		Name:  "setup",
+		Body:  "#!/bin/sh\n  echo \"EOT\"\nEOT\n\n%{ not a directive }\n",
+		Query: &query,
+		Note:  "no final newline\nstays quoted",
+	}}
+
+	for _, opts := range []MarshalOptions{{}, {IndentString: "\t"}} {
+		bs, err := MarshalWithOptions(j, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(bs)
+		for _, want := range []string{"body = <<EOT1\n#!/bin/sh\n  echo \"EOT\"\nEOT\n\n%%{ not a directive }\nEOT1\n", "query = <<EOT\nSELECT *\n", "WHERE name = '$${name}'\n", `note = "no final newline\nstays quoted"`, "# runs first\n"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("missing %q in\n%s", want, out)
+			}
+		}
+
+		got := new(job)
+		if err := Unmarshal(bs, got); err != nil {
+			t.Fatalf("%v in\n%s", err, bs)
+		}
+		if got.Script == nil || got.Script.Body != j.Script.Body || got.Script.Query == nil || *got.Script.Query != query || got.Script.Note != j.Script.Note {
+			t.Errorf("got %#v from\n%s", got.Script, bs)
+		}
+	}
+}
+
+type lastHeredoc struct {
+	Name   string `hcl:"name"`
+	Script string `hcl:"script"`
+}
+
+// rawHeredoc writes its own body, ending with a heredoc
+type rawHeredoc struct{}
+
+func (*rawHeredoc) MarshalHCLLevel(level int) ([]byte, error) {
+	return []byte("script = <<EOT\nx\nEOT\n\n"), nil
+}
+
+func TestMarshalHeredocLast(t *testing.T) {
+	for _, script := range []string{"a\n${x}\n", "x\n\n", "  indented\n\tx\n"} {
+		for _, opts := range []MarshalOptions{{}, {IndentString: "\t"}} {
+			bs, err := MarshalWithOptions(&lastHeredoc{Name: "n", Script: script}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := new(lastHeredoc)
+			if err := Unmarshal(bs, got); err != nil {
+				t.Fatalf("%v in\n%s", err, bs)
+			}
+			if got.Script != script {
+				t.Errorf("got %q from\n%s", got.Script, bs)
+			}
+		}
+	}
+
+	bs, err := Marshal(&rawHeredoc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]any)
+	if err := Unmarshal(bs, &m); err != nil {
+		t.Fatalf("%v in\n%s", err, bs)
+	}
+	if m["script"] != "x\n" {
+		t.Errorf("got %q from\n%s", m["script"], bs)
+	}
+}
+
+func TestIsHeredocString(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"", false},
+		{"\n", false},
+		{"one line\n", false},
+		{"two\nlines", false},
+		{"two\nlines\n", true},
+		{"\n\n", true},
+		{"crlf\r\nlines\r\n", false},
+	}
+	for _, tt := range tests {
+		if got := isHeredocString(tt.s); got != tt.want {
+			t.Errorf("isHeredocString(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var (
+	marshalerType      = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	levelMarshalerType = reflect.TypeOf((*LevelMarshaler)(nil)).Elem()
+)
+
+// isMarshalerType reports whether typ or *typ implements Marshaler or
+// LevelMarshaler, and so encodes itself.
+func isMarshalerType(typ reflect.Type) bool {
+	ptr := reflect.PointerTo(typ)
+	return typ.Implements(marshalerType) || ptr.Implements(marshalerType) || typ.Implements(levelMarshalerType) || ptr.Implements(levelMarshalerType)
+}
+
+// CanMarshal reports whether the type of v can be encoded by Marshal. If not,
+// reasons lists the problems found, one per field, e.g.
+//
+//	Config.Handler: func is not supported
+//
+// Marshal skips or fails on such fields at run time; CanMarshal finds them by
+// walking the type instead of a value, so it also checks empty maps, slices
+// and nil pointers. Interface fields are accepted, since their content is only
+// known at run time. Types implementing Marshaler or LevelMarshaler are
+// accepted as they are.
+func CanMarshal(v any) (bool, []string) {
+	if v == nil {
+		return false, []string{"nil value"}
+	}
+	c := &marshalCheck{visited: make(map[reflect.Type]bool)}
+	typ := reflect.TypeOf(v)
+	root := typ
+	for root.Kind() == reflect.Pointer {
+		root = root.Elem()
+	}
+	path := root.Name()
+	if path == "" {
+		path = root.String()
+	}
+	c.checkType(typ, path)
+	return len(c.reasons) == 0, c.reasons
+}
+
+// marshalCheck collects the problems found by CanMarshal.
+type marshalCheck struct {
+	visited map[reflect.Type]bool // struct types already checked
+	reasons []string
+}
+
+func (c *marshalCheck) add(path, format string, args ...any) {
+	c.reasons = append(c.reasons, path+": "+fmt.Sprintf(format, args...))
+}
+
+// checkType checks typ found at path.
+func (c *marshalCheck) checkType(typ reflect.Type, path string) {
+	if isMarshalerType(typ) || typ == timeType || isTextType(typ) {
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Interface:
+	case reflect.Pointer:
+		if typ.Elem().Kind() == reflect.Pointer {
+			c.add(path, "pointer to pointer is not supported")
+			return
+		}
+		c.checkType(typ.Elem(), path)
+	case reflect.Struct:
+		c.checkStruct(typ, path)
+	case reflect.Slice, reflect.Array:
+		c.checkType(typ.Elem(), path+"[]")
+	case reflect.Map:
+		key := typ.Key()
+		// integer keys are labels of struct blocks, e.g. map[int]*Service
+		elem := typ.Elem()
+		if elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		intKey := isMapKeyKind(key.Kind()) && (elem.Kind() == reflect.Struct || isStructSlice(typ.Elem()))
+		if key.Kind() != reflect.String && !intKey && !(key.Kind() == reflect.Array && key.Len() == 2 && key.Elem().Kind() == reflect.String) {
+			c.add(path, "map key %v is not supported, use string or [2]string, or an integer for structs", key)
+			return
+		}
+		c.checkType(typ.Elem(), path+"[]")
+	default:
+		c.add(path, "%v is not supported", typ.Kind())
+	}
+}
+
+// checkStruct checks the exported fields of a struct type, following the same
+// rules as getFields.
+func (c *marshalCheck) checkStruct(typ reflect.Type, path string) {
+	if c.visited[typ] {
+		return
+	}
+	c.visited[typ] = true
+
+	exported := 0
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !unicode.IsUpper([]rune(field.Name)[0]) {
+			continue
+		}
+		exported++
+		tagParts := parseHCLTag(field.Tag)
+		tagName := tagParts[0]
+		if tagName == tagIgnore || strings.HasSuffix(tagName, tagIgnoreSuffix) {
+			continue
+		}
+		fieldPath := path + "." + field.Name
+
+		if field.Anonymous && tagName == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				c.checkStruct(fieldType, path)
+			}
+			continue
+		}
+
+		if isCommentField(field) {
+			if field.Type != commentsType {
+				c.add(fieldPath, "comment field must be map[string]string, got %v", field.Type)
+			}
+			continue
+		}
+		if strings.ToLower(tagParts[1]) == tagModifierLabel {
+			if !isMapKeyKind(field.Type.Kind()) {
+				c.add(fieldPath, "label field must be a string or an integer, got %v", field.Type)
+			}
+			continue
+		}
+		c.checkType(field.Type, fieldPath)
+	}
+	if exported == 0 && typ.NumField() > 0 {
+		c.add(path, "struct has no exported fields")
+	}
+}
@@ -0,0 +1,54 @@
+package dethcl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanMarshal(t *testing.T) {
+	type listener struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type good struct {
+		Name      string               `hcl:"name"`
+		Tags      []string             `hcl:"tags,optional"`
+		Listeners map[string]*listener `hcl:"listener,block"`
+		Extra     any                  `hcl:"extra,optional"`
+		Ignored   chan int             `hcl:"-"`
+		internal  func()
+	}
+	if ok, reasons := CanMarshal(&good{}); !ok {
+		t.Errorf("expected marshalable, got %v", reasons)
+	}
+
+	type hidden struct {
+		secret string
+	}
+	type bad struct {
+		Handler func()            `hcl:"handler"`
+		Events  chan string       `hcl:"events"`
+		Phase   complex128        `hcl:"phase"`
+		ByID    map[int]string    `hcl:"by_id"`
+		Hidden  hidden            `hcl:"hidden,block"`
+		Nested  []map[string]bool `hcl:"nested"`
+	}
+	ok, reasons := CanMarshal(bad{})
+	if ok {
+		t.Fatal("expected not marshalable")
+	}
+	expected := []string{
+		"bad.Handler: func is not supported",
+		"bad.Events: chan is not supported",
+		"bad.Phase: complex128 is not supported",
+		"bad.ByID: map key int is not supported, use string or [2]string, or an integer for structs",
+		"bad.Hidden: struct has no exported fields",
+	}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("got %q", reasons)
+	}
+
+	if ok, _ := CanMarshal(nil); ok {
+		t.Error("expected nil not marshalable")
+	}
+}
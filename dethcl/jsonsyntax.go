@@ -0,0 +1,46 @@
+package dethcl
+
+import (
+	"encoding/json"
+)
+
+// MarshalJSONSyntax encodes a Go value into the JSON variant of HCL, as read
+// by tools that consume files such as .tf.json.
+//
+// The value is encoded like Marshal, then written as JSON following the HCL
+// JSON conventions: a block becomes an object under its type, each label adds
+// one level of nesting, and repeated blocks without labels become an array.
+//
+// Example:
+//
+//	type Service struct {
+//	    Name string `hcl:"name,label"`
+//	    Port int    `hcl:"port"`
+//	}
+//	type Config struct {
+//	    Services []*Service `hcl:"service,block"`
+//	}
+//
+//	bs, err := MarshalJSONSyntax(&Config{Services: []*Service{{Name: "api", Port: 80}}})
+//	// Output:
+//	// {
+//	//   "service": {
+//	//     "api": {
+//	//       "port": 80
+//	//     }
+//	//   }
+//	// }
+func MarshalJSONSyntax(current any) ([]byte, error) {
+	if current == nil {
+		return nil, nil
+	}
+	bs, err := Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	object := make(map[string]any)
+	if err := Unmarshal(bs, &object); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(object, "", "  ")
+}
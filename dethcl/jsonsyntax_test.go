@@ -0,0 +1,68 @@
+package dethcl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	hcljson "github.com/hashicorp/hcl/v2/json"
+)
+
+func TestMarshalJSONSyntax(t *testing.T) {
+	type listener struct {
+		Protocol string `hcl:"protocol,label"`
+		Port     int    `hcl:"port"`
+	}
+	type service struct {
+		Name      string      `hcl:"name,label"`
+		Tags      []string    `hcl:"tags,optional"`
+		Listeners []*listener `hcl:"listener,block"`
+	}
+	type logging struct {
+		Level string `hcl:"level"`
+	}
+	type config struct {
+		Version  int        `hcl:"version"`
+		Logging  *logging   `hcl:"logging,block"`
+		Services []*service `hcl:"service,block"`
+	}
+	cfg := &config{
+		Version: 2,
+		Logging: &logging{Level: "info"},
+		Services: []*service{
+			{Name: "api", Tags: []string{"public"}, Listeners: []*listener{{Protocol: "http", Port: 80}, {Protocol: "https", Port: 443}}},
+			{Name: "db", Listeners: []*listener{{Protocol: "tcp", Port: 5432}}},
+		},
+	}
+
+	bs, err := MarshalJSONSyntax(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the JSON variant decodes with the HCL JSON parser to the same value
+	// as the native syntax
+	file, diags := hcljson.Parse(bs, "config.tf.json")
+	if diags.HasErrors() {
+		t.Fatalf("%s\n%s", diags, bs)
+	}
+	fromJSON := new(config)
+	if diags := gohcl.DecodeBody(file.Body, nil, fromJSON); diags.HasErrors() {
+		t.Fatalf("%s\n%s", diags, bs)
+	}
+
+	native, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromNative := new(config)
+	if err := Unmarshal(native, fromNative); err != nil {
+		t.Fatal(err)
+	}
+
+	// JSON objects are written with sorted keys, so labeled blocks come
+	// back sorted by label; the test data already is
+	if !reflect.DeepEqual(fromJSON, fromNative) || !reflect.DeepEqual(fromJSON, cfg) {
+		t.Errorf("json:   %#v\nnative: %#v\n%s", fromJSON, fromNative, bs)
+	}
+}
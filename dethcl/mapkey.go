@@ -0,0 +1,91 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// isMapKeyKind reports whether maps with keys of kind k can be written as
+// labeled blocks: strings, and integers written in decimal, e.g. for
+// map[int]*Service keyed by port.
+func isMapKeyKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// mapKeyString returns the label of the map key k.
+func mapKeyString(k reflect.Value) (string, error) {
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("map key must be string or integer, got %v", k.Kind())
+	}
+}
+
+// mapKeyValue parses label into a map key of type typ, the reverse of
+// mapKeyString.
+func mapKeyValue(label string, typ reflect.Type) (reflect.Value, error) {
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(label).Convert(typ), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(label, 10, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("label %q is not a valid %v key", label, typ)
+		}
+		return reflect.ValueOf(n).Convert(typ), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(label, 10, typ.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("label %q is not a valid %v key", label, typ)
+		}
+		return reflect.ValueOf(n).Convert(typ), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("map key must be string or integer, got %v", typ.Kind())
+	}
+}
+
+// labelString returns the label written for the label field v: the name of
+// a registered enum, or v as a map key, e.g. "443" for an int.
+func labelString(v reflect.Value) (string, error) {
+	if name, ok := enumName(v); ok {
+		return name, nil
+	}
+	if !isMapKeyKind(v.Kind()) {
+		return "", fmt.Errorf("label field must be a string or an integer, got %v", v.Type())
+	}
+	return mapKeyString(v)
+}
+
+// setLabel sets the label field f to label, the reverse of labelString: a
+// registered enum is given by its name or number, other types as map keys.
+func setLabel(f reflect.Value, label string) error {
+	typ := f.Type()
+	if !isMapKeyKind(typ.Kind()) {
+		return fmt.Errorf("label field must be a string or an integer, got %v", typ)
+	}
+	if v, done, err := enumValue(cty.StringVal(label), typ); done && err == nil {
+		f.Set(reflect.ValueOf(v))
+		return nil
+	}
+	v, err := mapKeyValue(label, typ)
+	if err != nil {
+		return fmt.Errorf("label %q is not a valid %v", label, typ)
+	}
+	f.Set(v)
+	return nil
+}
@@ -44,56 +44,50 @@ func decodeMap(ref map[string]any, node *utils.Tree, hclBytes []byte) (map[strin
 	}
 	file, diags := hclsyntax.ParseConfig(hclBytes, generateTempHCLFileName(), hcl.Pos{Line: 1, Column: 1})
 	if diags.HasErrors() {
-		return nil, fmt.Errorf("failed to parse map HCL: %w", diags.Errs()[0])
+		// the message names the first error only, as it always did
+		derr := newDethclError("failed to parse map HCL", diags, hclBytes)
+		derr.cause = diags.Errs()[0]
+		return nil, derr
 	}
 
 	return decodeBody(ref, node, file, file.Body.(*hclsyntax.Body))
 }
 
 func decodeBody(ref map[string]any, node *utils.Tree, file *hcl.File, body *hclsyntax.Body) (map[string]any, error) {
+	state := getDecodeState(ref)
+	if err := state.checkDepth(node); err != nil {
+		return nil, err
+	}
 	object := make(map[string]any)
 	for key, item := range body.Attributes {
 		value, err := expressionToNative(ref, node, file, key, item.Expr, item)
 		if err != nil {
 			return nil, err
 		}
-		object[key] = value
+		object[state.intern(key)] = value
+	}
+
+	maxLabels := 0
+	if state != nil {
+		maxLabels = state.maxLabels
 	}
 
 	var sliceBodies map[string][]*hclsyntax.Body
 	counts := make(map[string]int)
-	var mapBodies map[string]map[string]*hclsyntax.Body
-	var map2Bodies map[string]map[string]map[string]*hclsyntax.Body
+	var labeledBlocks []*hclsyntax.Block
 	for _, item := range body.Blocks {
-		switch len(item.Labels) {
-		case 0:
+		if len(item.Labels) == 0 {
 			if sliceBodies == nil {
 				sliceBodies = make(map[string][]*hclsyntax.Body)
 			}
 			sliceBodies[item.Type] = append(sliceBodies[item.Type], item.Body)
 			counts[item.Type]++
-		case 1:
-			if mapBodies == nil {
-				mapBodies = make(map[string]map[string]*hclsyntax.Body)
-			}
-			if mapBodies[item.Type] == nil {
-				mapBodies[item.Type] = make(map[string]*hclsyntax.Body)
-			}
-			mapBodies[item.Type][item.Labels[0]] = item.Body
-		case 2:
-			if map2Bodies == nil {
-				map2Bodies = make(map[string]map[string]map[string]*hclsyntax.Body)
-			}
-			if map2Bodies[item.Type] == nil {
-				map2Bodies[item.Type] = make(map[string]map[string]*hclsyntax.Body)
-			}
-			if map2Bodies[item.Type][item.Labels[0]] == nil {
-				map2Bodies[item.Type][item.Labels[0]] = make(map[string]*hclsyntax.Body)
-			}
-			map2Bodies[item.Type][item.Labels[0]][item.Labels[1]] = item.Body
-		default:
-			return nil, fmt.Errorf("unsupported number of labels (%d) for block type %q: expected 0, 1, or 2", len(item.Labels), item.Type)
+			continue
 		}
+		if maxLabels > 0 && len(item.Labels) > maxLabels {
+			return nil, fmt.Errorf("too many labels (%d) for block type %q: at most %d allowed", len(item.Labels), item.Type, maxLabels)
+		}
+		labeledBlocks = append(labeledBlocks, item)
 	}
 
 	for key, bodies := range sliceBodies {
@@ -108,43 +102,46 @@ func decodeBody(ref map[string]any, node *utils.Tree, file *hcl.File, body *hcls
 			values = append(values, decoded)
 		}
 		if counts[key] > 1 {
-			object[key] = values
+			object[state.intern(key)] = values
 		} else {
-			object[key] = values[0]
+			object[state.intern(key)] = values[0]
 		}
 	}
 
-	for key, bodies := range mapBodies {
-		valueMap := make(map[string]any)
-		keyNode := node.AddNode(key)
-		for mapKey, body := range bodies {
-			subNode := keyNode.AddNode(mapKey)
-			decoded, err := decodeBody(ref, subNode, file, body)
-			if err != nil {
-				return nil, err
-			}
-			valueMap[mapKey] = decoded
+	// labeled blocks nest one map per label, e.g. rule "a" "b" "c" {} becomes
+	// object["rule"]["a"]["b"]["c"]
+	labeledMaps := make(map[string]map[string]any)
+	depths := make(map[string]int)
+	for _, item := range labeledBlocks {
+		if depth, ok := depths[item.Type]; ok && depth != len(item.Labels) {
+			return nil, fmt.Errorf("block type %q is used with both %d and %d labels", item.Type, depth, len(item.Labels))
 		}
-		object[key] = valueMap
-	}
+		depths[item.Type] = len(item.Labels)
 
-	for key, bodies := range map2Bodies {
-		outerMap := make(map[string]any)
-		keyNode := node.AddNode(key)
-		for firstKey, bodies2 := range bodies {
-			innerMap := make(map[string]any)
-			keyNode2 := keyNode.AddNode(firstKey)
-			for secondKey, body := range bodies2 {
-				subNode := keyNode2.AddNode(secondKey)
-				decoded, err := decodeBody(ref, subNode, file, body)
-				if err != nil {
-					return nil, err
-				}
-				innerMap[secondKey] = decoded
+		subNode := node.AddNodes(item.Type, item.Labels...)
+		decoded, err := decodeBody(ref, subNode, file, item.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		current, ok := labeledMaps[item.Type]
+		if !ok {
+			current = make(map[string]any)
+			labeledMaps[item.Type] = current
+		}
+		last := len(item.Labels) - 1
+		for _, label := range item.Labels[:last] {
+			inner, ok := current[label].(map[string]any)
+			if !ok {
+				inner = make(map[string]any)
+				current[state.intern(label)] = inner
 			}
-			outerMap[firstKey] = innerMap
+			current = inner
 		}
-		object[key] = outerMap
+		current[state.intern(item.Labels[last])] = decoded
+	}
+	for key, outer := range labeledMaps {
+		object[state.intern(key)] = outer
 	}
 
 	return object, nil
@@ -175,7 +172,10 @@ func decodeObjectConsExpr(ref map[string]any, node *utils.Tree, hclBytes []byte)
 }
 
 func decodeTuple(ref map[string]any, node *utils.Tree, file *hcl.File, tuple *hclsyntax.TupleConsExpr) ([]any, error) {
-	var object []any
+	if err := getDecodeState(ref).checkDepth(node); err != nil {
+		return nil, err
+	}
+	object := make([]any, 0, len(tuple.Exprs))
 	for index, item := range tuple.Exprs {
 		value, err := expressionToNative(ref, node, file, index, item)
 		if err != nil {
@@ -187,6 +187,9 @@ func decodeTuple(ref map[string]any, node *utils.Tree, file *hcl.File, tuple *hc
 }
 
 func decodeObject(ref map[string]any, node *utils.Tree, file *hcl.File, exprs *hclsyntax.ObjectConsExpr) (map[string]any, error) {
+	if err := getDecodeState(ref).checkDepth(node); err != nil {
+		return nil, err
+	}
 	object := make(map[string]any)
 	for _, item := range exprs.Items {
 		keyExpr, ok := item.KeyExpr.(*hclsyntax.ObjectConsKeyExpr)
@@ -230,5 +233,5 @@ func expressionToNative(ref map[string]any, node *utils.Tree, file *hcl.File, ke
 
 	node.AddItem(fmt.Sprintf("%v", key), ctyValue)
 
-	return utils.CtyToNative(ctyValue)
+	return utils.CtyToNativeMode(ctyValue, getDecodeState(ref).numberMode())
 }
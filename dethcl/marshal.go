@@ -2,13 +2,19 @@
 package dethcl
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
-	"unicode"
+	"time"
 
+	"github.com/genelet/horizon/utils"
 	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // Marshaler is the interface implemented by types that can marshal themselves into HCL.
@@ -17,10 +23,32 @@ type Marshaler interface {
 	MarshalHCL() ([]byte, error)
 }
 
+// LevelMarshaler is the interface implemented by types that marshal
+// themselves into HCL at a given nesting level, e.g. to lay out several
+// nested blocks. MarshalHCLLevel returns the body of the receiver, without
+// braces, with each line already indented for level: by level+1 steps of two
+// spaces, as Marshal indents the fields of a struct at that level. Its
+// output is used unchanged, so heredocs and hand-aligned text are kept, and
+// MarshalOptions.IndentString does not apply to it. Marshal prefers it to
+// Marshaler when a type implements both.
+type LevelMarshaler interface {
+	MarshalHCLLevel(level int) ([]byte, error)
+}
+
+// Commenter is the interface implemented by types that give comments for
+// their fields. HCLComment receives the Go name of a field and returns the
+// comment Marshal writes with it, or "" for none. A single line follows the
+// field, as in port = 8080 # seconds; text of several lines is written above
+// the field, one # line each.
+type Commenter interface {
+	HCLComment(fieldName string) string
+}
+
 // Marshal encodes a Go value into HCL format.
 //
 // The value can be a struct, map, slice, or any Go type with hcl struct tags.
-// If the value implements Marshaler, its MarshalHCL method is called.
+// If the value implements LevelMarshaler or Marshaler, its MarshalHCLLevel
+// or MarshalHCL method is called.
 // Otherwise, Marshal uses reflection to encode the value.
 //
 // Supported types:
@@ -87,7 +115,34 @@ func Marshal(current any) ([]byte, error) {
 //
 // Returns the indented HCL encoding or an error if marshaling fails.
 func MarshalLevel(current any, level int) ([]byte, error) {
-	return marshalLevel(current, false, level)
+	return marshalLevel(nil, current, false, level)
+}
+
+// MarshalBody encodes a struct or a map into brace-less HCL body content,
+// with the root indentation removed and a trailing newline, so that it can be
+// spliced into a hand-written block or a template:
+//
+//	body, err := MarshalBody(&Service{Port: 8080})
+//	// body: port = 8080
+//
+// Returns nil for a zero value, and an error for other kinds such as slices,
+// which have no body form.
+func MarshalBody(current any) ([]byte, error) {
+	if current == nil {
+		return nil, nil
+	}
+	typ := reflect.TypeOf(current)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || (typ.Kind() != reflect.Struct && typ.Kind() != reflect.Map) {
+		return nil, fmt.Errorf("MarshalBody needs a struct or a map, got %T", current)
+	}
+	bs, err := MarshalLevel(current, 0)
+	if err != nil || isBlank(bs) {
+		return nil, err
+	}
+	return append(bytes.TrimSpace(hclwrite.Format(bs)), '\n'), nil
 }
 
 // marshalLevel is the internal routing function for marshaling with control over indentation and formatting.
@@ -100,7 +155,10 @@ func MarshalLevel(current any, level int) ([]byte, error) {
 //   - keyname: optional label values for blocks
 //
 // Returns nil for zero values, otherwise delegates to appropriate encoding function.
-func marshalLevel(current any, equal bool, level int, keyname ...string) ([]byte, error) {
+func marshalLevel(opts *MarshalOptions, current any, equal bool, level int, keyname ...string) ([]byte, error) {
+	if limit := opts.maxDepth(); level > limit {
+		return nil, fmt.Errorf("maximum nesting depth %d exceeded", limit)
+	}
 	reflectValue := reflect.ValueOf(current)
 	if reflectValue.IsValid() && reflectValue.IsZero() {
 		// If we are in a slice (indicated by markerNoBrackets), we must encode zero values
@@ -112,11 +170,14 @@ func marshalLevel(current any, equal bool, level int, keyname ...string) ([]byte
 
 	switch reflectValue.Kind() {
 	case reflect.Pointer, reflect.Struct:
-		return marshal(current, level, keyname...)
+		// big numbers in a slice, e.g. []any{big.NewInt(1)}, are literals
+		if len(keyname) == 0 || keyname[0] != markerNoBrackets || !isBigNumber(reflectValue) {
+			return marshal(opts, current, level, keyname...)
+		}
 	default:
 	}
 
-	return encoding(current, equal, level, keyname...)
+	return encoding(opts, current, equal, level, keyname...)
 }
 
 // marshal encodes a struct or pointer into HCL format with proper indentation and block structure.
@@ -138,12 +199,31 @@ func marshalLevel(current any, equal bool, level int, keyname ...string) ([]byte
 //   - keyname: optional label values from parent context
 //
 // Returns formatted HCL bytes with proper indentation and block structure.
-func marshal(current any, level int, keyname ...string) ([]byte, error) {
+func marshal(opts *MarshalOptions, current any, level int, keyname ...string) ([]byte, error) {
 	if current == nil {
 		return nil, nil
 	}
-	indentation := indent(level + 1)
-	parentIndent := indent(level)
+	indentation := opts.indent(level + 1)
+	parentIndent := opts.indent(level)
+
+	if marshaler, ok := current.(LevelMarshaler); ok {
+		encoded, err := marshaler.MarshalHCLLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		result := strings.TrimRight(string(encoded), " \t\n\r")
+		if level == 0 && endsWithHeredoc(result) {
+			result += "\n"
+		}
+		if level > 0 {
+			if result == "" {
+				result = "{\n" + parentIndent + "}"
+			} else {
+				result = "{\n" + strings.TrimLeft(result, "\n") + "\n" + parentIndent + "}"
+			}
+		}
+		return []byte(result), nil
+	}
 
 	if marshaler, ok := current.(Marshaler); ok {
 		encoded, err := marshaler.MarshalHCL()
@@ -186,6 +266,9 @@ func marshal(current any, level int, keyname ...string) ([]byte, error) {
 		}
 		return nil, nil
 	case reflect.String:
+		if structValue.IsValid() && isHeredocString(structValue.String()) {
+			return append([]byte("="), heredocTokens(structValue.String()).Bytes()...), nil
+		}
 		if structValue.IsValid() {
 			return []byte(fmt.Sprintf("= %q", structValue.String())), nil
 		}
@@ -194,19 +277,49 @@ func marshal(current any, level int, keyname ...string) ([]byte, error) {
 		if structValue.IsNil() {
 			return nil, nil
 		}
-		return marshal(structValue.Elem().Interface(), level, keyname...)
+		return marshal(opts, structValue.Elem().Interface(), level, keyname...)
 	default:
 	}
 
-	categorizedFields, err := getFields(structType, structValue)
+	allFields, err := getFields(opts, structType, structValue)
 	if err != nil {
 		return nil, err
 	}
+	categorizedFields := make([]*marshalField, 0, len(allFields))
+	var omittedFields []*marshalField
+	for _, f := range allFields {
+		if f.omitted {
+			omittedFields = append(omittedFields, f)
+		} else {
+			categorizedFields = append(categorizedFields, f)
+		}
+	}
+	if opts != nil && opts.SortFields != nil {
+		sortAttributeFields(categorizedFields, opts.SortFields)
+	}
+
+	commenter, _ := current.(Commenter)
+	if commenter == nil && structValue.CanAddr() {
+		commenter, _ = structValue.Addr().Interface().(Commenter)
+	}
+	if commenter == nil {
+		commenter = commentsOf(structValue)
+	}
+	var comments map[*marshalOut]fieldComment
 
-	var simpleFields []reflect.StructField
+	simpleFields := make([]reflect.StructField, 0, len(categorizedFields))
 	for _, marshalField := range categorizedFields {
 		if !marshalField.out {
-			simpleFields = append(simpleFields, marshalField.field)
+			field := encoderTag(marshalField.field)
+			// a registered enum is written as its name, a time in its layout,
+			// a text type by MarshalText, a redacted field as a placeholder string
+			if isBigType(field.Type) && !opts.redact(marshalField.field) {
+				// a big number is written as a number literal
+				field.Type = reflect.TypeOf(cty.Value{})
+			} else if _, ok := enumName(marshalField.value); ok || isTimeType(field.Type) || isTextType(field.Type) || opts.redact(marshalField.field) {
+				field.Type = reflect.TypeOf("")
+			}
+			simpleFields = append(simpleFields, field)
 		}
 	}
 	simpleType := reflect.StructOf(simpleFields)
@@ -215,26 +328,66 @@ func marshal(current any, level int, keyname ...string) ([]byte, error) {
 	var labels []string
 
 	fieldIndex := 0
+	labelIndex := 0
 	for _, marshalField := range categorizedFields {
 		field := marshalField.field
 		fieldValue := marshalField.value
 		if marshalField.out {
-			complexField, err := getOutlier(field, fieldValue, level)
+			complexField, err := getOutlier(opts, field, fieldValue, level)
 			if err != nil {
 				return nil, err
 			}
+			// the comment goes with the first block of the field
+			if c, ok := commentFor(commenter, field); ok && len(complexField) > 0 {
+				if comments == nil {
+					comments = make(map[*marshalOut]fieldComment)
+				}
+				comments[complexField[0]] = c
+			}
 			complexFields = append(complexFields, complexField...)
 		} else {
 			fieldTag := field.Tag
 			tagParts := parseHCLTag(fieldTag)
 			if tagParts[1] == tagModifierLabel {
-				label := fieldValue.Interface().(string)
-				if keyname == nil || keyname[0] != label {
+				label, err := labelString(fieldValue)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.Name, err)
+				}
+				// a label already supplied by the parent map key at the same
+				// position, e.g. map[[2]string]*Example, is not repeated
+				if label != "" && (labelIndex >= len(keyname) || keyname[labelIndex] != label) {
 					labels = append(labels, label)
 				}
+				labelIndex++
+				fieldIndex++
+				continue
+			}
+			if opts.redact(field) {
+				simpleStruct.Field(fieldIndex).Set(reflect.ValueOf(redactedValue))
 				fieldIndex++
 				continue
 			}
+			if prefix, suffix := stringAffixes(field); prefix != "" || suffix != "" {
+				fieldValue = reflect.ValueOf(prefix + fieldValue.String() + suffix).Convert(field.Type)
+			}
+			if name, ok := enumName(fieldValue); ok {
+				fieldValue = reflect.ValueOf(name)
+			}
+			if isTimeType(field.Type) {
+				fieldValue = reflect.ValueOf(fieldValue.Interface().(time.Time).Format(timeLayout(field)))
+			} else if isBigType(field.Type) {
+				number, err := utils.NativeToCty(bigNumber(fieldValue))
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.Name, err)
+				}
+				fieldValue = reflect.ValueOf(number)
+			} else if isTextType(field.Type) {
+				text, err := marshalText(fieldValue)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.Name, err)
+				}
+				fieldValue = reflect.ValueOf(text)
+			}
 			simpleStruct.Field(fieldIndex).Set(fieldValue)
 			fieldIndex++
 		}
@@ -242,30 +395,75 @@ func marshal(current any, level int, keyname ...string) ([]byte, error) {
 
 	hclFile := hclwrite.NewEmptyFile()
 	gohcl.EncodeIntoBody(simpleStruct.Addr().Interface(), hclFile.Body())
-	encoded := hclFile.Bytes()
+	// multi-line strings are written as heredocs, see isHeredocString
+	for i, field := range simpleFields {
+		if v := reflect.Indirect(simpleStruct.Field(i)); v.Kind() == reflect.String && isHeredocString(v.String()) {
+			hclFile.Body().SetAttributeRaw(parseHCLTag(field.Tag)[0], heredocTokens(v.String()))
+		}
+	}
+	if commenter != nil {
+		commentAttributes(commenter, hclFile.Body(), categorizedFields)
+	}
+	encoded := opts.reindent(hclFile.Bytes())
 
 	result := string(encoded)
-	result = indentation + strings.ReplaceAll(result, "\n", "\n"+indentation)
+	result = indentLines(result, indentation)
+
+	if opts != nil && opts.SortFields != nil {
+		sort.SliceStable(complexFields, func(i, j int) bool {
+			return opts.SortFields(string(complexFields[i].b0), string(complexFields[j].b0))
+		})
+	}
 
-	var lines []string
+	lines := make([]string, 0, len(complexFields))
+	if opts != nil && opts.ShowOmitted {
+		if opts.SortFields != nil {
+			sortAttributeFields(omittedFields, opts.SortFields)
+		}
+		for _, f := range omittedFields {
+			line, err := omittedComment(f)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+	}
+	var line strings.Builder
 	for _, item := range complexFields {
-		line := string(item.b0) + " "
+		line.Reset()
+		line.Grow(len(item.b0) + len(item.b2) + 16)
+		line.Write(item.b0)
+		line.WriteByte(' ')
 		if item.encode {
-			line += "= "
+			line.WriteString("= ")
 		}
-		if len(item.b1) > 0 {
-			line += `"` + strings.Join(item.b1, `" "`) + `" `
+		for _, label := range item.b1 {
+			line.WriteByte('"')
+			line.WriteString(label)
+			line.WriteString(`" `)
 		}
-		line += string(item.b2)
-		lines = append(lines, line)
+		line.Write(item.b2)
+		text := line.String()
+		if c, ok := comments[item]; ok {
+			text = c.apply(text, indentation)
+		}
+		lines = append(lines, text)
 	}
 	if len(lines) > 0 {
 		result += strings.Join(lines, "\n"+indentation)
 	}
 
 	result = strings.TrimRight(result, " \t\n\r")
+	if level == 0 && endsWithHeredoc(result) {
+		// the closing marker of a heredoc ends with a newline
+		result += "\n"
+	}
 	if level > 0 { // not root
-		result = fmt.Sprintf("{\n%s\n%s}", result, parentIndent)
+		if result == "" { // empty block, e.g. from a pointer to a zero struct
+			result = "{\n" + parentIndent + "}"
+		} else {
+			result = "{\n" + result + "\n" + parentIndent + "}"
+		}
 		if labels != nil {
 			result = "\"" + strings.Join(labels, "\" \"") + "\" " + result
 		}
@@ -278,9 +476,10 @@ func marshal(current any, level int, keyname ...string) ([]byte, error) {
 // Fields are categorized into simple fields (encoded by gohcl) and
 // complex fields (encoded recursively with special handling).
 type marshalField struct {
-	field reflect.StructField // The struct field metadata
-	value reflect.Value       // The field's actual value
-	out   bool                // true if complex field requiring special marshaling
+	field   reflect.StructField // The struct field metadata
+	value   reflect.Value       // The field's actual value
+	out     bool                // true if complex field requiring special marshaling
+	omitted bool                // true if optional simple field left out for its zero value
 }
 
 // getFields categorizes struct fields into simple and complex fields for marshaling.
@@ -298,35 +497,39 @@ type marshalField struct {
 //   - Tag-based field filtering (ignores unexported and tagged fields)
 //   - Auto-tagging of untagged fields with appropriate modifiers
 //
+// The static part of this analysis is done once per struct type, see
+// cachedFields.
+//
 // Returns a slice of categorized marshalField instances.
-func getFields(structType reflect.Type, structValue reflect.Value) ([]*marshalField, error) {
-	var categorizedFields []*marshalField
-	for i := 0; i < structType.NumField(); i++ {
-		field := structType.Field(i)
+func getFields(opts *MarshalOptions, structType reflect.Type, structValue reflect.Value) ([]*marshalField, error) {
+	infos := cachedFields(structType, opts.jsonTags())
+	categorizedFields := make([]*marshalField, 0, len(infos))
+	for _, info := range infos {
+		field := info.field
 		fieldType := field.Type
-		if !unicode.IsUpper([]rune(field.Name)[0]) {
-			continue
-		}
-		fieldValue := structValue.Field(i)
-		tagParts := parseHCLTag(field.Tag)
-		tagName := tagParts[0]
-		if tagName == tagIgnore || (len(tagName) >= 2 && tagName[len(tagName)-2:] == tagIgnoreSuffix) {
-			continue
+		fieldValue := structValue.Field(info.index)
+		if info.omitEmpty && isEmptyValue(fieldValue) {
+			switch fieldType.Kind() {
+			case reflect.Array, reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice:
+				continue
+			default:
+				// empty scalars stay, to be listed by MarshalOptions.ShowOmitted
+			}
 		}
 
-		if field.Anonymous && tagName == "" {
+		if info.embedded {
 			switch fieldType.Kind() {
 			case reflect.Ptr:
 				if fieldValue.IsNil() {
 					continue
 				}
-				embeddedFields, err := getFields(fieldType.Elem(), fieldValue.Elem())
+				embeddedFields, err := getFields(opts, fieldType.Elem(), fieldValue.Elem())
 				if err != nil {
 					return nil, err
 				}
 				categorizedFields = append(categorizedFields, embeddedFields...)
 			case reflect.Struct:
-				embeddedFields, err := getFields(fieldType, fieldValue)
+				embeddedFields, err := getFields(opts, fieldType, fieldValue)
 				if err != nil {
 					return nil, err
 				}
@@ -337,22 +540,49 @@ func getFields(structType reflect.Type, structValue reflect.Value) ([]*marshalFi
 		}
 
 		// treat field of type pointer e.g. *map[string]*Example, the same as map[string]*Example
-		if fieldType.Kind() == reflect.Pointer && (fieldType.Elem().Kind() == reflect.Slice || fieldType.Elem().Kind() == reflect.Map) {
+		if info.collectionPtr {
 			if fieldValue.IsNil() {
 				continue
 			}
 			fieldType = fieldType.Elem()
 			fieldValue = fieldValue.Elem()
+			// an attribute such as *map[string]string is encoded as its map
+			field.Type = fieldType
 			if !fieldValue.IsValid() {
 				continue
 			}
 		}
+		// a big number held by an interface, e.g. a field of type any
+		// decoded from a 40-digit integer, is written as a number like a
+		// field of its type
+		text := info.text
+		if fieldType.Kind() == reflect.Interface && !fieldValue.IsNil() && isBigNumber(fieldValue.Elem()) {
+			fieldValue = fieldValue.Elem()
+			fieldType = fieldValue.Type()
+			field.Type = fieldType
+			text = true
+		}
+		// a time or a text type is a simple field written as a string, see
+		// timeLayout and marshalText
+		kind := fieldType.Kind()
+		if text {
+			kind = reflect.String
+			if fieldType.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					continue
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.IsZero() && info.optional {
+				continue
+			}
+		}
 		needsSpecialMarshaling := false
-		switch fieldType.Kind() {
+		switch kind {
 		case reflect.Interface, reflect.Pointer, reflect.Struct:
 			needsSpecialMarshaling = true
 		case reflect.Slice:
-			if fieldValue.Len() == 0 {
+			if fieldValue.Len() == 0 || info.repeated {
 				needsSpecialMarshaling = true
 				break
 			}
@@ -366,28 +596,134 @@ func getFields(structType reflect.Type, structValue reflect.Value) ([]*marshalFi
 				needsSpecialMarshaling = true
 				break
 			}
-			switch fieldValue.MapIndex(fieldValue.MapKeys()[0]).Kind() {
+			switch firstMapValue(fieldValue).Kind() {
 			case reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice, reflect.Struct:
 				needsSpecialMarshaling = true
 			default:
 			}
 		default:
 			if fieldValue.IsValid() && fieldValue.IsZero() {
+				// optional fields are kept, for MarshalOptions.ShowOmitted
+				if info.optional {
+					field.Tag = info.omittedTag
+					categorizedFields = append(categorizedFields, &marshalField{field: field, value: fieldValue, omitted: true})
+				}
 				continue
 			}
 		}
-		if tagName == "" {
+		if info.tagName == "" {
+			field.Tag = info.attrTag
 			if needsSpecialMarshaling {
-				field.Tag = reflect.StructTag(fmt.Sprintf(`hcl:"%s,%s"`, strings.ToLower(field.Name), tagModifierBlock))
-			} else {
-				field.Tag = reflect.StructTag(fmt.Sprintf(`hcl:"%s,%s"`, strings.ToLower(field.Name), tagModifierOptional))
+				field.Tag = info.blockTag
 			}
 		}
-		categorizedFields = append(categorizedFields, &marshalField{field, fieldValue, needsSpecialMarshaling})
+		categorizedFields = append(categorizedFields, &marshalField{field: field, value: fieldValue, out: needsSpecialMarshaling})
 	}
 	return categorizedFields, nil
 }
 
+// sortAttributeFields stably sorts the simple, non-label fields in place with
+// less, leaving label and complex fields where they are.
+func sortAttributeFields(fields []*marshalField, less func(a, b string) bool) {
+	var positions []int
+	var attrs []*marshalField
+	for i, f := range fields {
+		if !f.out && parseHCLTag(f.field.Tag)[1] != tagModifierLabel {
+			positions = append(positions, i)
+			attrs = append(attrs, f)
+		}
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return less(string(extractHCLTagName(attrs[i].field.Tag)), string(extractHCLTagName(attrs[j].field.Tag)))
+	})
+	for k, i := range positions {
+		fields[i] = attrs[k]
+	}
+}
+
+// omittedComment returns the comment that MarshalOptions.ShowOmitted writes
+// for an omitted optional field, e.g. "# port = 0  (optional, omitted)".
+func omittedComment(f *marshalField) (string, error) {
+	value, err := utils.NativeToCty(f.value.Interface())
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", f.field.Name, err)
+	}
+	if name, ok := enumName(f.value); ok {
+		value = cty.StringVal(name)
+	}
+	name := string(extractHCLTagName(f.field.Tag))
+	return fmt.Sprintf("# %s = %s  (optional, omitted)", name, hclwrite.TokensForValue(value).Bytes()), nil
+}
+
+// fieldComment is the comment of a Commenter for one field, either trailing
+// its first line or, for several lines, above it.
+type fieldComment struct {
+	trailing string
+	above    []string
+}
+
+// commentFor returns the comment of commenter for field, if any.
+func commentFor(commenter Commenter, field reflect.StructField) (fieldComment, bool) {
+	if commenter == nil {
+		return fieldComment{}, false
+	}
+	text := strings.TrimRight(commenter.HCLComment(field.Name), " \t\r\n")
+	if text == "" {
+		return fieldComment{}, false
+	}
+	// comments read from the source stay above their field
+	if _, above := commenter.(sourceComments); !above && !strings.Contains(text, "\n") {
+		return fieldComment{trailing: "# " + text}, true
+	}
+	return fieldComment{above: strings.Split(strings.TrimSuffix(string(headerComment(text)), "\n"), "\n")}, true
+}
+
+// apply adds the comment to text, the output of a field, whose following
+// lines are indented by indentation.
+func (c fieldComment) apply(text, indentation string) string {
+	if c.trailing != "" {
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			return text[:i] + " " + c.trailing + text[i:]
+		}
+		return text + " " + c.trailing
+	}
+	return strings.Join(c.above, "\n"+indentation) + "\n" + indentation + text
+}
+
+// commentAttributes adds the comments of commenter to the attributes of body,
+// written by gohcl in the order of fields.
+func commentAttributes(commenter Commenter, body *hclwrite.Body, fields []*marshalField) {
+	var tokens hclwrite.Tokens
+	changed := false
+	for _, f := range fields {
+		if f.out {
+			continue
+		}
+		attr := body.GetAttribute(string(extractHCLTagName(f.field.Tag)))
+		if attr == nil {
+			continue
+		}
+		attrTokens := attr.BuildTokens(nil)
+		if c, ok := commentFor(commenter, f.field); ok {
+			changed = true
+			if n := len(attrTokens); c.trailing != "" && n > 1 && attrTokens[n-2].Type == hclsyntax.TokenCHeredoc {
+				// nothing may follow the closing marker of a heredoc
+				c.above = append(c.above, c.trailing)
+			} else if c.trailing != "" && n > 0 && attrTokens[n-1].Type == hclsyntax.TokenNewline {
+				attrTokens[n-1] = &hclwrite.Token{Type: hclsyntax.TokenComment, Bytes: []byte(c.trailing + "\n"), SpacesBefore: 1}
+			}
+			for _, line := range c.above {
+				tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenComment, Bytes: []byte(line + "\n")})
+			}
+		}
+		tokens = append(tokens, attrTokens...)
+	}
+	if changed {
+		body.Clear()
+		body.AppendUnstructuredTokens(tokens)
+	}
+}
+
 // marshalOut represents the marshaled output components for a complex field.
 // Complex fields are formatted as: b0 [= ] ["b1" "b1" ...] b2
 // For example: "service \"api\" \"web\" { port = 8080 }"
@@ -398,8 +734,15 @@ type marshalOut struct {
 	encode bool     // true if should add "=" between b0 and b2 (for attribute-style encoding)
 }
 
+// indentSpaces holds the indentation of the levels met in practice, so that
+// indent can slice it instead of allocating.
+const indentSpaces = "                                                                "
+
 // indent returns the indentation string for a given level (2 spaces per level).
 func indent(level int) string {
+	if 2*level <= len(indentSpaces) {
+		return indentSpaces[:2*level]
+	}
 	return strings.Repeat("  ", level)
 }
 
@@ -426,7 +769,8 @@ func needsLoopMarshaling(value reflect.Value) bool {
 //   - Slices: Each element marshaled separately if contains structs/interfaces, otherwise as array
 //   - Maps: Each value marshaled as labeled block if contains structs/interfaces, otherwise as map
 //
-// Empty slices are encoded as "[]", empty maps as "{\n}".
+// Empty slices are encoded as "[]", empty maps as "{}" or, for maps of
+// blocks, "{\n}".
 // Blank (whitespace-only) outputs are skipped.
 //
 // Parameters:
@@ -435,12 +779,18 @@ func needsLoopMarshaling(value reflect.Value) bool {
 //   - level: current indentation level
 //
 // Returns a slice of marshalOut components for formatting into HCL output.
-func getOutlier(field reflect.StructField, oriField reflect.Value, level int) ([]*marshalOut, error) {
+func getOutlier(opts *MarshalOptions, field reflect.StructField, oriField reflect.Value, level int) ([]*marshalOut, error) {
 	var empty []*marshalOut
 	fieldTag := field.Tag
 	typ := field.Type
 	newlevel := level + 1
 
+	// a sensitive pointer, interface, block, list or map is replaced as a
+	// whole, like a simple field; nil and empty values are written as usual
+	if opts.redact(field) && !isEmptyValue(oriField) {
+		return []*marshalOut{{extractHCLTagName(fieldTag), nil, []byte(fmt.Sprintf("%q", redactedValue)), true}}, nil
+	}
+
 	// treat ptr the same as the underlying type e.g. *Example, Example
 	if typ.Kind() == reflect.Ptr && (typ.Elem().Kind() == reflect.Map || typ.Elem().Kind() == reflect.Slice) {
 		typ = typ.Elem()
@@ -448,15 +798,24 @@ func getOutlier(field reflect.StructField, oriField reflect.Value, level int) ([
 
 	switch typ.Kind() {
 	case reflect.Interface, reflect.Pointer:
+		// a nil pointer is omitted, unless the block may not be left out
+		if typ.Kind() == reflect.Pointer && oriField.IsNil() && hasTagOption(parseHCLTag(fieldTag)[1], tagModifierRequired) {
+			return nil, fmt.Errorf("required field %s of type %v is nil", extractHCLTagName(fieldTag), typ)
+		}
 		newCurrent := oriField.Interface()
-		bs, err := MarshalLevel(newCurrent, newlevel)
+		bs, err := marshalLevel(opts, newCurrent, false, newlevel)
 		if err != nil {
 			return nil, err
 		}
 		if isBlank(bs) {
+			// distinguish an explicitly unset pointer or interface from an
+			// absent one
+			if oriField.IsNil() && (opts.explicitNulls() || hasTagOption(parseHCLTag(fieldTag)[1], tagModifierExplicitNull)) {
+				return []*marshalOut{{extractHCLTagName(fieldTag), nil, []byte("null"), true}}, nil
+			}
 			return nil, nil
 		}
-		// Check if the interface contains a primitive value.
+		// Check if the interface contains a primitive value or a list.
 		// If so, render as attribute (encode=true) instead of block label.
 		encode := false
 		if typ.Kind() == reflect.Interface {
@@ -466,8 +825,12 @@ func getOutlier(field reflect.StructField, oriField reflect.Value, level int) ([
 				case reflect.String, reflect.Bool,
 					reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
-					reflect.Float32, reflect.Float64:
+					reflect.Float32, reflect.Float64,
+					reflect.Slice, reflect.Array:
 					encode = true
+				case reflect.Map:
+					encode = opts.mapAsAttribute(field)
+				default:
 				}
 			}
 		}
@@ -479,7 +842,7 @@ func getOutlier(field reflect.StructField, oriField reflect.Value, level int) ([
 		} else {
 			newCurrent = oriField.Interface()
 		}
-		bs, err := MarshalLevel(newCurrent, newlevel)
+		bs, err := marshalLevel(opts, newCurrent, false, newlevel)
 		if err != nil {
 			return nil, err
 		}
@@ -488,13 +851,16 @@ func getOutlier(field reflect.StructField, oriField reflect.Value, level int) ([
 		}
 		empty = append(empty, &marshalOut{extractHCLTagName(fieldTag), nil, bs, false})
 	case reflect.Slice:
-		results, err := handleSlice(field, oriField, newlevel)
+		if hasTagOption(parseHCLTag(fieldTag)[1], tagModifierRepeated) {
+			return repeatedBlocks(opts, field, oriField, level)
+		}
+		results, err := handleSlice(opts, field, oriField, newlevel)
 		if err != nil {
 			return nil, err
 		}
 		empty = append(empty, results...)
 	case reflect.Map:
-		results, err := handleMap(field, oriField, level, newlevel)
+		results, err := handleMap(opts, field, oriField, level, newlevel)
 		if err != nil {
 			return nil, err
 		}
@@ -504,7 +870,30 @@ func getOutlier(field reflect.StructField, oriField reflect.Value, level int) ([
 	return empty, nil
 }
 
-func handleSlice(field reflect.StructField, oriField reflect.Value, level int) ([]*marshalOut, error) {
+// repeatedBlocks writes the elements of a slice field tagged repeated as one
+// block each, e.g. tag { value = "a" }, for the struct at level.
+func repeatedBlocks(opts *MarshalOptions, field reflect.StructField, oriField reflect.Value, level int) ([]*marshalOut, error) {
+	tagName := extractHCLTagName(field.Tag)
+	results := make([]*marshalOut, 0, oriField.Len())
+	for i := 0; i < oriField.Len(); i++ {
+		item := oriField.Index(i)
+		value, err := utils.NativeToCty(item.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("field %s[%d]: %w", field.Name, i, err)
+		}
+		if name, ok := enumName(item); ok {
+			value = cty.StringVal(name)
+		}
+		if !value.Type().IsPrimitiveType() {
+			return nil, fmt.Errorf("field %s: repeated needs a slice of primitives, got %v", field.Name, field.Type)
+		}
+		body := "{\n" + opts.indent(level+2) + repeatedValueAttr + " = " + string(hclwrite.TokensForValue(value).Bytes()) + "\n" + opts.indent(level+1) + "}"
+		results = append(results, &marshalOut{tagName, nil, []byte(body), false})
+	}
+	return results, nil
+}
+
+func handleSlice(opts *MarshalOptions, field reflect.StructField, oriField reflect.Value, level int) ([]*marshalOut, error) {
 	if oriField.IsNil() {
 		return nil, nil
 	}
@@ -520,19 +909,21 @@ func handleSlice(field reflect.StructField, oriField reflect.Value, level int) (
 
 	var results []*marshalOut
 	if isLoop {
+		tagName := extractHCLTagName(fieldTag)
+		results = make([]*marshalOut, 0, n)
 		for i := 0; i < n; i++ {
 			item := oriField.Index(i)
-			bs, err := MarshalLevel(item.Interface(), level)
+			bs, err := marshalLevel(opts, item.Interface(), false, level)
 			if err != nil {
 				return nil, err
 			}
 			if isBlank(bs) {
 				continue
 			}
-			results = append(results, &marshalOut{extractHCLTagName(fieldTag), nil, bs, false})
+			results = append(results, &marshalOut{tagName, nil, bs, false})
 		}
 	} else {
-		bs, err := MarshalLevel(oriField.Interface(), level)
+		bs, err := marshalLevel(opts, oriField.Interface(), false, level)
 		if err != nil {
 			return nil, err
 		}
@@ -544,7 +935,7 @@ func handleSlice(field reflect.StructField, oriField reflect.Value, level int) (
 	return results, nil
 }
 
-func handleMap(field reflect.StructField, oriField reflect.Value, currentLevel, level int) ([]*marshalOut, error) {
+func handleMap(opts *MarshalOptions, field reflect.StructField, oriField reflect.Value, currentLevel, level int) ([]*marshalOut, error) {
 	if oriField.IsNil() {
 		return nil, nil
 	}
@@ -552,26 +943,55 @@ func handleMap(field reflect.StructField, oriField reflect.Value, currentLevel,
 	n := oriField.Len()
 	fieldTag := field.Tag
 	if n < 1 {
-		leading := indent(currentLevel + 1)
+		if opts.mapAsAttribute(field) || !mapHoldsBlocks(opts, field.Type) {
+			return []*marshalOut{{extractHCLTagName(fieldTag), nil, []byte("{}"), true}}, nil
+		}
+		leading := opts.indent(currentLevel + 1)
 		return []*marshalOut{{extractHCLTagName(fieldTag), nil, []byte("{\n" + leading + "}"), false}}, nil
 	}
 
-	first := oriField.MapIndex(oriField.MapKeys()[0])
-	isLoop := needsLoopMarshaling(first)
+	if hasTagOption(parseHCLTag(fieldTag)[1], tagModifierCollapse) {
+		return collapseMap(opts, field, oriField, currentLevel)
+	}
+
+	first := firstMapValue(oriField)
 	typ := field.Type
+	// a sensitive pointer, interface, block, list or map is replaced as a
+	// whole, like a simple field; nil and empty values are written as usual
+	if opts.redact(field) && !isEmptyValue(oriField) {
+		return []*marshalOut{{extractHCLTagName(fieldTag), nil, []byte(fmt.Sprintf("%q", redactedValue)), true}}, nil
+	}
+
 	// treat ptr the same as the underlying type e.g. *Example, Example
 	if typ.Kind() == reflect.Ptr && (typ.Elem().Kind() == reflect.Map || typ.Elem().Kind() == reflect.Slice) {
 		typ = typ.Elem()
 	}
+	// a map of slices of structs, e.g. map[string][]*Rule, repeats the
+	// labeled block for every element
+	isSliceLoop := isStructSlice(typ.Elem())
+	isLoop := isSliceLoop || needsLoopMarshaling(first)
+	// in Terraform mode, a block of maps, e.g. provider "aws" { ... }, has the
+	// map keys as labels
+	if opts.terraform() && !opts.mapAsAttribute(field) && typ.Elem().Kind() == reflect.Map {
+		isLoop = true
+	}
 
 	var results []*marshalOut
 	if isLoop {
+		tagName := extractHCLTagName(fieldTag)
+		results = make([]*marshalOut, 0, n)
+		type mapEntry struct {
+			labels []string
+			value  reflect.Value
+		}
+		entries := make([]mapEntry, 0, n)
 		iter := oriField.MapRange()
 		for iter.Next() {
 			k := iter.Key()
 			var arr []string
 			switch k.Kind() {
 			case reflect.Array, reflect.Slice:
+				arr = make([]string, 0, k.Len())
 				for i := 0; i < k.Len(); i++ {
 					item := k.Index(i)
 					if !item.IsZero() {
@@ -579,23 +999,51 @@ func handleMap(field reflect.StructField, oriField reflect.Value, currentLevel,
 					}
 				}
 			default:
-				arr = append(arr, k.String())
+				// the empty key is the default entry, written without label
+				key, err := mapKeyString(k)
+				if err != nil {
+					return nil, err
+				}
+				if key != "" {
+					arr = []string{key}
+				}
 			}
 
-			v := iter.Value()
-			var bs []byte
-			var err error
-			bs, err = marshal(v.Interface(), level, arr...)
-			if err != nil {
-				return nil, err
+			entries = append(entries, mapEntry{arr, iter.Value()})
+		}
+		if opts.sortMapKeys() {
+			slices.SortFunc(entries, func(a, b mapEntry) int {
+				return slices.Compare(a.labels, b.labels)
+			})
+		}
+		for _, entry := range entries {
+			arr := entry.labels
+			values := []reflect.Value{entry.value}
+			if isSliceLoop {
+				values = values[:0]
+				for i := 0; i < entry.value.Len(); i++ {
+					values = append(values, entry.value.Index(i))
+				}
 			}
-			if isBlank(bs) {
-				continue
+			for _, v := range values {
+				var bs []byte
+				var err error
+				if v.Kind() == reflect.Map {
+					bs, err = marshalLevel(opts, v.Interface(), false, level)
+				} else {
+					bs, err = marshal(opts, v.Interface(), level, arr...)
+				}
+				if err != nil {
+					return nil, err
+				}
+				if isBlank(bs) {
+					continue
+				}
+				results = append(results, &marshalOut{tagName, arr, bs, false})
 			}
-			results = append(results, &marshalOut{extractHCLTagName(fieldTag), arr, bs, false})
 		}
 	} else {
-		bs, err := MarshalLevel(oriField.Interface(), level)
+		bs, err := marshalLevel(opts, oriField.Interface(), false, level)
 		if err != nil {
 			return nil, err
 		}
@@ -604,13 +1052,131 @@ func handleMap(field reflect.StructField, oriField reflect.Value, currentLevel,
 		}
 		equal := true
 		if typ.Elem().Kind() == reflect.Interface {
-			equal = false
+			equal = opts.mapAsAttribute(field)
 		}
 		results = append(results, &marshalOut{extractHCLTagName(fieldTag), nil, bs, equal})
 	}
 	return results, nil
 }
 
+// collapseMap writes the non-empty map v of a field tagged collapse as one
+// object attribute keyed by the map keys, e.g.
+//
+//	service = {
+//	  api = {
+//	    port = 8080
+//	  }
+//	}
+//
+// instead of a block per entry. The entries are given as by ToMap.
+func collapseMap(opts *MarshalOptions, field reflect.StructField, v reflect.Value, level int) ([]*marshalOut, error) {
+	m, err := mapToGeneric(v)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.Name, err)
+	}
+	value, err := utils.NativeToCty(m)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.Name, err)
+	}
+	text := string(opts.reindent(hclwrite.Format(collapseTokens(value).Bytes())))
+	text = strings.ReplaceAll(text, "\n", "\n"+opts.indent(level+1))
+	return []*marshalOut{{extractHCLTagName(field.Tag), nil, []byte(text), true}}, nil
+}
+
+// collapseTokens returns the tokens of v for collapseMap. Unlike
+// TokensForValue, it puts each object of a list on lines of its own, so
+// that hclwrite.Format can indent them, e.g.
+//
+//	listener = [
+//	  {
+//	    port = 80
+//	  },
+//	  {
+//	    port = 443
+//	  }
+//	]
+func collapseTokens(v cty.Value) hclwrite.Tokens {
+	typ := v.Type()
+	if v.IsNull() || !v.IsKnown() || !typ.IsCollectionType() && !typ.IsObjectType() && !typ.IsTupleType() || v.LengthInt() == 0 {
+		return hclwrite.TokensForValue(v)
+	}
+	switch {
+	case typ.IsObjectType() || typ.IsMapType():
+		var items []hclwrite.ObjectAttrTokens
+		for it := v.ElementIterator(); it.Next(); {
+			k, elem := it.Element()
+			name := hclwrite.TokensForValue(k)
+			if hclsyntax.ValidIdentifier(k.AsString()) {
+				name = hclwrite.TokensForIdentifier(k.AsString())
+			}
+			items = append(items, hclwrite.ObjectAttrTokens{Name: name, Value: collapseTokens(elem)})
+		}
+		return hclwrite.TokensForObject(items)
+	case (typ.IsTupleType() || typ.IsListType()) && holdsObjects(v):
+		newline := &hclwrite.Token{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")}
+		tokens := hclwrite.Tokens{{Type: hclsyntax.TokenOBrack, Bytes: []byte("[")}, newline}
+		for i, elem := range v.AsValueSlice() {
+			if i > 0 {
+				tokens = append(tokens, &hclwrite.Token{Type: hclsyntax.TokenComma, Bytes: []byte(",")}, newline)
+			}
+			tokens = append(tokens, collapseTokens(elem)...)
+		}
+		return append(tokens, newline, &hclwrite.Token{Type: hclsyntax.TokenCBrack, Bytes: []byte("]")})
+	default:
+		return hclwrite.TokensForValue(v)
+	}
+}
+
+// holdsObjects reports whether the list v has an object or map element.
+func holdsObjects(v cty.Value) bool {
+	for it := v.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+		if typ := elem.Type(); typ.IsObjectType() || typ.IsMapType() {
+			return true
+		}
+	}
+	return false
+}
+
+// mapHoldsBlocks reports whether the entries of a map of type typ, or of a
+// pointer to it, are written as blocks rather than in an object attribute,
+// as decided by the type of its values, so that an empty map[string]string
+// gives tags = {} like a full one.
+func mapHoldsBlocks(opts *MarshalOptions, typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch elem := typ.Elem(); elem.Kind() {
+	case reflect.Pointer, reflect.Struct, reflect.Interface:
+		return true
+	case reflect.Map:
+		return opts.terraform()
+	default:
+		return isStructSlice(elem)
+	}
+}
+
+// firstMapValue returns a value of the non-empty map v. Unlike MapKeys, it
+// does not copy every key, which matters for large maps.
+func firstMapValue(v reflect.Value) reflect.Value {
+	iter := v.MapRange()
+	iter.Next()
+	return iter.Value()
+}
+
+// isStructSlice reports whether typ is a slice of structs or of pointers to
+// structs, e.g. []*Rule.
+func isStructSlice(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Slice {
+		return false
+	}
+	elem := typ.Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	return elem.Kind() == reflect.Struct
+}
+
 // isBlank checks if a byte slice contains only whitespace characters.
 // Used to skip empty marshaled output (e.g., empty structs or nil values).
 // Returns true if the slice contains only spaces, tabs, newlines, or carriage returns.
@@ -0,0 +1,89 @@
+package dethcl
+
+import (
+	"strconv"
+	"testing"
+)
+
+type benchService struct {
+	Name    string            `hcl:"name,label"`
+	Image   string            `hcl:"image"`
+	Port    int               `hcl:"port"`
+	Enabled bool              `hcl:"enabled,optional"`
+	Tags    []string          `hcl:"tags,optional"`
+	Env     map[string]string `hcl:"env,optional"`
+}
+
+type benchConfig struct {
+	Services map[string]*benchService `hcl:"service,block"`
+}
+
+func newBenchConfig(n int) *benchConfig {
+	cfg := &benchConfig{Services: make(map[string]*benchService, n)}
+	for i := 0; i < n; i++ {
+		name := "svc" + strconv.Itoa(i)
+		cfg.Services[name] = &benchService{
+			Name:    name,
+			Image:   "registry/" + name + ":latest",
+			Port:    8000 + i,
+			Enabled: i%2 == 0,
+			Tags:    []string{"web", "v1"},
+			Env:     map[string]string{"MODE": "prod"},
+		}
+	}
+	return cfg
+}
+
+// Benchmark marshaling a map of 10k struct values, each written as a
+// labeled block
+func BenchmarkMarshalLargeMap(b *testing.B) {
+	cfg := newBenchConfig(10000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Benchmark marshaling a generic map[string]any of 10k nested maps, the
+// shape produced by decoding a large document
+func BenchmarkMarshalLargeGenericMap(b *testing.B) {
+	m := make(map[string]any, 10000)
+	for i := 0; i < 10000; i++ {
+		m["svc"+strconv.Itoa(i)] = map[string]any{"image": "registry/app", "port": 8000 + i}
+	}
+	data := map[string]any{"service": m}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type benchList struct {
+	Services []*benchService `hcl:"service,block"`
+}
+
+// Benchmark marshaling a slice of 10k values of one struct type, where the
+// field metadata of benchService is looked up once and then reused
+func BenchmarkMarshalLargeSlice(b *testing.B) {
+	cfg := newBenchConfig(10000)
+	list := &benchList{Services: make([]*benchService, 0, len(cfg.Services))}
+	for i := 0; i < len(cfg.Services); i++ {
+		list.Services = append(list.Services, cfg.Services["svc"+strconv.Itoa(i)])
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Marshal(list); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,8 +2,13 @@ package dethcl
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/OpenUdon/schema"
 )
@@ -791,3 +796,859 @@ func TestResponseUnmarshal(t *testing.T) {
 		t.Errorf("%#v\n%#v", r, r1)
 	}
 }
+
+func TestMarshalExplicitNull(t *testing.T) {
+	type patch struct {
+		Name    *string `hcl:"name,optional,explicitnull"`
+		Port    *int    `hcl:"port,optional,explicitnull"`
+		Timeout *int    `hcl:"timeout,optional"`
+	}
+	port := 8080
+	p := &patch{Port: &port}
+	bs, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "name = null") || strings.Contains(string(bs), "timeout") {
+		t.Errorf("unexpected output: %s", bs)
+	}
+
+	name := "old"
+	got := &patch{Name: &name}
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != nil || got.Port == nil || *got.Port != 8080 || got.Timeout != nil {
+		t.Errorf("round trip: %#v", got)
+	}
+}
+
+func TestMarshalNilPointerBlock(t *testing.T) {
+	type sub struct {
+		Host string `hcl:"host"`
+	}
+	type config struct {
+		Name     string `hcl:"name"`
+		Cache    *sub   `hcl:"cache,block,optional"`
+		Database *sub   `hcl:"database,block,required"`
+	}
+
+	// an optional nil pointer is left out
+	bs, err := Marshal(&config{Name: "app", Database: &sub{Host: "db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "cache") || !strings.Contains(string(bs), "database {") {
+		t.Errorf("unexpected output: %s", bs)
+	}
+	got := new(config)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Cache != nil || got.Database == nil || got.Database.Host != "db" {
+		t.Errorf("round trip: %#v", got)
+	}
+
+	// a required nil pointer is an error
+	_, err = Marshal(&config{Name: "app"})
+	if err == nil || !strings.Contains(err.Error(), "required field database") {
+		t.Errorf("expected a required field error, got %v", err)
+	}
+}
+
+func TestMarshalNestedSlices(t *testing.T) {
+	type grid struct {
+		Cells  [][]int     `hcl:"cells"`
+		Names  [][]string  `hcl:"names,optional"`
+		Points [][]float64 `hcl:"points,optional"`
+	}
+	g := &grid{
+		Cells:  [][]int{{1, 2}, {3, 4}},
+		Names:  [][]string{{"a"}, {"b", "c"}},
+		Points: [][]float64{{0.5, 1.5}, {}},
+	}
+	bs, err := Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "cells = [[1, 2], [3, 4]]") || !strings.Contains(string(bs), `names = [["a"], ["b", "c"]]`) {
+		t.Errorf("unexpected output: %s", bs)
+	}
+
+	got := new(grid)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, got) {
+		t.Errorf("round trip: got %#v from\n%s", got, bs)
+	}
+}
+
+func TestMarshalInterfacePrimitive(t *testing.T) {
+	type holder struct {
+		Data  any `hcl:"data"`
+		Label any `hcl:"label,optional"`
+		List  any `hcl:"list,optional"`
+	}
+	h := &holder{Data: 5, Label: "x", List: []int{1, 2}}
+	bs, err := Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"data = 5", `label = "x"`, "list = ["} {
+		if !strings.Contains(string(bs), want) {
+			t.Errorf("missing %q in:\n%s", want, bs)
+		}
+	}
+
+	got := new(holder)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Data != 5 || got.Label != "x" || !reflect.DeepEqual(got.List, []any{1, 2}) {
+		t.Errorf("round trip: %#v", got)
+	}
+}
+
+func TestMarshalMapKeyLabel(t *testing.T) {
+	type service struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type config struct {
+		Services map[string]*service `hcl:"service,block"`
+	}
+
+	tests := []struct {
+		name   string
+		label  string
+		header string
+	}{
+		{"matching", "web", `service "web" {`},
+		{"differing", "frontend", `service "web" "frontend" {`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{Services: map[string]*service{"web": {Name: tt.label, Port: 80}}}
+			bs, err := Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(bs), tt.header) {
+				t.Errorf("expected %s in:\n%s", tt.header, bs)
+			}
+
+			var back config
+			if err := Unmarshal(bs, &back); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, *cfg) {
+				t.Errorf("got %#v", back.Services["web"])
+			}
+		})
+	}
+}
+
+func TestMarshalMapOfSlices(t *testing.T) {
+	type rule struct {
+		Direction string `hcl:"direction,label"`
+		Port      int    `hcl:"port"`
+	}
+	type firewall struct {
+		Rules map[string][]*rule `hcl:"rule,block"`
+		Plain map[string][]rule  `hcl:"plain,block"`
+	}
+	fw := &firewall{
+		Rules: map[string][]*rule{
+			"in":  {{Direction: "in", Port: 80}, {Direction: "in", Port: 443}},
+			"out": {{Direction: "out", Port: 53}},
+		},
+		Plain: map[string][]rule{"in": {{Direction: "in", Port: 22}}},
+	}
+	bs, err := Marshal(fw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(bs), `rule "in" {`); n != 2 {
+		t.Errorf("expected 2 rule \"in\" blocks, got %d:\n%s", n, bs)
+	}
+	if strings.Contains(string(bs), "[") {
+		t.Errorf("unexpected list:\n%s", bs)
+	}
+
+	var back firewall
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&back, fw) {
+		t.Errorf("got %#v\n%s", back, bs)
+	}
+}
+
+type namedcircle struct {
+	Name   string  `hcl:"name,label"`
+	Radius float32 `hcl:"radius"`
+}
+
+func (c *namedcircle) Area() float32 {
+	return 3.14159 * c.Radius
+}
+
+func TestMarshalLabeledInterfaceSlice(t *testing.T) {
+	type canvas struct {
+		Shapes []inter `hcl:"shapes,block"`
+	}
+	c := &canvas{Shapes: []inter{
+		&namedcircle{Name: "dot", Radius: 1},
+		&moresquare{Morename1: "box", Morename2: "small", SX: 1, SY: 2},
+	}}
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != `  shapes "dot" {
+    radius = 1
+  }
+  shapes "box" "small" {
+    sx = 1
+    sy = 2
+  }` {
+		t.Errorf("'%s'", bs)
+	}
+
+	spec, err := schema.NewStruct(
+		"canvas", map[string]any{
+			"Shapes": []string{"namedcircle", "moresquare"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]any{"namedcircle": new(namedcircle), "moresquare": new(moresquare)}
+	back := &canvas{}
+	if err := UnmarshalSpec(bs, back, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, c) {
+		t.Errorf("got %#v", back.Shapes)
+	}
+}
+
+type commentedSub struct {
+	Host string `hcl:"host"`
+}
+
+type commented struct {
+	Name    string        `hcl:"name"`
+	Timeout int           `hcl:"timeout"`
+	Tags    []string      `hcl:"tags"`
+	Sub     *commentedSub `hcl:"sub,block"`
+}
+
+func (c *commented) HCLComment(fieldName string) string {
+	switch fieldName {
+	case "Timeout":
+		return "seconds, default 30"
+	case "Tags":
+		return "sent with\nevery request"
+	case "Sub":
+		return "upstream"
+	}
+	return ""
+}
+
+func TestMarshalCommenter(t *testing.T) {
+	c := &commented{Name: "api", Timeout: 10, Tags: []string{"a"}, Sub: &commentedSub{Host: "h"}}
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `  name    = "api"
+  timeout = 10 # seconds, default 30
+  # sent with
+  # every request
+  tags = ["a"]
+  sub { # upstream
+    host = "h"
+  }`
+	if string(bs) != expected {
+		t.Errorf("got\n%s\nwant\n%s", bs, expected)
+	}
+
+	back := new(commented)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, c) {
+		t.Errorf("round trip: %#v", back)
+	}
+}
+
+func TestMarshalRepeated(t *testing.T) {
+	type rule struct {
+		Hosts []string `hcl:"host,repeated"`
+	}
+	type config struct {
+		Name  string   `hcl:"name"`
+		Tags  []string `hcl:"tag,repeated"`
+		Ports []int    `hcl:"port,optional,repeated"`
+		Rule  *rule    `hcl:"rule,block"`
+	}
+	c := &config{Name: "a", Tags: []string{"x", "y"}, Rule: &rule{Hosts: []string{"h"}}}
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `  name = "a"
+  tag {
+    value = "x"
+  }
+  tag {
+    value = "y"
+  }
+  rule {
+    host {
+      value = "h"
+    }
+  }`
+	if string(bs) != expected {
+		t.Errorf("got\n%s\nwant\n%s", bs, expected)
+	}
+
+	back := new(config)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, c) {
+		t.Errorf("round trip: %#v", back)
+	}
+
+	// the list form is still accepted, but not together with blocks
+	if err := Unmarshal([]byte("name = \"a\"\ntag = [\"x\"]\nport { value = 80 }\n"), back); err != nil || back.Tags[0] != "x" || back.Ports[0] != 80 {
+		t.Errorf("got %#v, %v", back, err)
+	}
+	if err := Unmarshal([]byte("name = \"a\"\ntag = [\"x\"]\ntag { value = \"y\" }\n"), back); err == nil {
+		t.Error("expected an error for both forms")
+	}
+}
+
+func TestMarshalTime(t *testing.T) {
+	type event struct {
+		Name    string     `hcl:"name"`
+		At      time.Time  `hcl:"at"`
+		Day     time.Time  `hcl:"day" hcltime:"2006-01-02"`
+		Updated *time.Time `hcl:"updated,optional"`
+		Deleted *time.Time `hcl:"deleted,optional"`
+		Expires time.Time  `hcl:"expires,optional"`
+	}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := &event{Name: "launch", At: at, Day: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), Updated: &at}
+	bs, err := Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `  name    = "launch"
+  at      = "2024-01-02T03:04:05Z"
+  day     = "2024-05-06"
+  updated = "2024-01-02T03:04:05Z"`
+	if string(bs) != expected {
+		t.Errorf("got\n%s\nwant\n%s", bs, expected)
+	}
+
+	back := new(event)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, e) {
+		t.Errorf("round trip: %#v", back)
+	}
+
+	err = Unmarshal([]byte(`name = "x"
+at = "2024-01-02T03:04:05Z"
+day = "2024-05-06T00:00:00Z"`), back)
+	if err == nil || !strings.Contains(err.Error(), `layout "2006-01-02"`) {
+		t.Errorf("expected a layout error, got %v", err)
+	}
+	if ok, reasons := CanMarshal(e); !ok {
+		t.Errorf("CanMarshal: %v", reasons)
+	}
+}
+
+func TestMarshalMixedMap(t *testing.T) {
+	data := map[string]any{
+		"name":  "web",
+		"port":  8080,
+		"tags":  []any{"a", "b"},
+		"owner": nil,
+		"db": map[string]any{
+			"host": "localhost",
+			"pool": map[string]any{"size": 5},
+		},
+		"env": map[string]any{
+			"prod": map[string]any{"replicas": 3},
+		},
+		"empty": map[string]any{},
+		"none":  []any{},
+	}
+	expected := `
+  name = "web"
+  none = [
+    
+  ]
+  owner = null
+  port = 8080
+  tags = [
+    "a",
+    "b"
+  ]
+  db {
+    host = "localhost"
+    pool {
+      size = 5
+    }
+  }
+  empty {
+    
+  }
+  env "prod" {
+    replicas = 3
+  }
+`
+	for i := 0; i < 5; i++ {
+		bs, err := Marshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(bs) != expected {
+			t.Fatalf("got\n%q\nwant\n%q", bs, expected)
+		}
+	}
+
+	bs, _ := Marshal(data)
+	var back map[string]any
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, data) {
+		t.Errorf("round trip got\n%#v\nwant\n%#v", back, data)
+	}
+}
+
+func TestMarshalQuotedMapKeys(t *testing.T) {
+	// keys which are no identifiers make the map an object value
+	data := map[string]any{
+		"m":    map[string]any{"$hcl": "1 + 1", "a.b": 2, "c": "x"},
+		"list": []any{map[string]any{"$hcl": 3}},
+	}
+	bs, err := Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), `m = {`) || !strings.Contains(string(bs), `"$hcl" = "1 + 1"`) {
+		t.Errorf("got\n%s", bs)
+	}
+	var back map[string]any
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatalf("%v\n%s", err, bs)
+	}
+	if !reflect.DeepEqual(back, data) {
+		t.Errorf("round trip got\n%#v\nwant\n%#v", back, data)
+	}
+}
+
+func TestMarshalIntegerMapKeys(t *testing.T) {
+	type listener struct {
+		Name  string `hcl:"name,optional"`
+		Proto string `hcl:"proto"`
+	}
+	type server struct {
+		Ports      map[int]*listener  `hcl:"port,block"`
+		Priorities map[uint8]listener `hcl:"priority,block"`
+	}
+
+	s := &server{
+		Ports:      map[int]*listener{443: {Proto: "https"}},
+		Priorities: map[uint8]listener{0: {Proto: "tcp"}},
+	}
+	bs, err := Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{`port "443" {`, `priority "0" {`} {
+		if !strings.Contains(string(bs), header) {
+			t.Errorf("missing %q in\n%s", header, bs)
+		}
+	}
+
+	s.Ports[-80] = &listener{Proto: "http"}
+	bs, err = Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := new(server)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Ports) != 2 || back.Ports[443].Proto != "https" || back.Ports[-80].Proto != "http" || back.Priorities[0].Proto != "tcp" {
+		t.Errorf("got %#v", back)
+	}
+
+	for _, bad := range []string{
+		"port \"http\" {\n  proto = \"x\"\n}",
+		"priority \"300\" {\n  proto = \"x\"\n}",
+	} {
+		if err := Unmarshal([]byte(bad), new(server)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	type server struct {
+		Name string `hcl:"name"`
+	}
+	type config struct {
+		Name    string            `hcl:"name,omitempty"`
+		Tags    []string          `hcl:"tags,omitempty"`
+		Labels  map[string]string `hcl:"labels,omitempty"`
+		Extra   any               `hcl:"extra,omitempty"`
+		Servers []*server         `hcl:"server,block,omitempty"`
+		Kept    []string          `hcl:"kept,optional"`
+	}
+
+	c := &config{Tags: []string{}, Labels: map[string]string{}, Extra: []string{}, Kept: []string{}}
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(bs)); got != "kept = []" {
+		t.Errorf("got\n%s", bs)
+	}
+
+	c = &config{Name: "web", Tags: []string{"a"}, Labels: map[string]string{"env": "prod"}, Servers: []*server{{Name: "s1"}}}
+	bs, err = Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`name = "web"`, `tags = ["a"]`, `env = "prod"`, `server {`} {
+		if !strings.Contains(string(bs), want) {
+			t.Errorf("missing %q in\n%s", want, bs)
+		}
+	}
+
+	back := new(config)
+	if err := Unmarshal([]byte(`kept = []`), back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Name != "" || back.Tags != nil || back.Labels != nil || back.Servers != nil {
+		t.Errorf("got %#v", back)
+	}
+}
+
+func TestMarshalBody(t *testing.T) {
+	type service struct {
+		Image string `hcl:"image"`
+		Port  int    `hcl:"port"`
+	}
+	type config struct {
+		Name     string              `hcl:"name"`
+		Services map[string]*service `hcl:"service,block"`
+	}
+
+	bs, err := MarshalBody(&config{Name: "app", Services: map[string]*service{"api": {Image: "nginx", Port: 80}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "name = \"app\"\nservice \"api\" {\n  image = \"nginx\"\n  port  = 80\n}\n"
+	if string(bs) != expected {
+		t.Errorf("got\n%q\nwant\n%q", bs, expected)
+	}
+
+	bs, err = MarshalBody(map[string]any{"a": 1, "b": map[string]any{"c": 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "a = 1\nb {\n  c = 2\n}\n"
+	if string(bs) != expected {
+		t.Errorf("got\n%q\nwant\n%q", bs, expected)
+	}
+
+	// the body splices into a hand-written block
+	spliced := "resource \"app\" {\n" + string(bs) + "}\n"
+	var back map[string]any
+	if err := Unmarshal([]byte(spliced), &back); err != nil {
+		t.Fatal(err)
+	}
+
+	if bs, err := MarshalBody(&config{}); err != nil || bs != nil {
+		t.Errorf("zero value: got %q, %v", bs, err)
+	}
+	for _, bad := range []any{[]int{1}, "str", 3} {
+		if _, err := MarshalBody(bad); err == nil {
+			t.Errorf("%T: expected an error", bad)
+		}
+	}
+}
+
+// matcher lays out its nested blocks itself
+type matcher struct {
+	Paths []string
+}
+
+func (m *matcher) MarshalHCLLevel(level int) ([]byte, error) {
+	pad := strings.Repeat("  ", level+1)
+	var b strings.Builder
+	for _, path := range m.Paths {
+		fmt.Fprintf(&b, "%spath {\n%s  prefix = %q\n%s}\n", pad, pad, path, pad)
+	}
+	return []byte(b.String()), nil
+}
+
+// MarshalHCL is ignored in favour of MarshalHCLLevel
+func (m *matcher) MarshalHCL() ([]byte, error) {
+	return []byte("ignored = true"), nil
+}
+
+func TestMarshalLevelMarshaler(t *testing.T) {
+	type route struct {
+		Name  string   `hcl:"name"`
+		Match *matcher `hcl:"match,block"`
+		Empty *matcher `hcl:"empty,block"`
+	}
+	type router struct {
+		Route *route `hcl:"route,block"`
+	}
+	r := &router{Route: &route{Name: "api", Match: &matcher{Paths: []string{"/v1", "/v2"}}, Empty: &matcher{}}}
+	bs, err := Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `  route {
+    name = "api"
+    match {
+      path {
+        prefix = "/v1"
+      }
+      path {
+        prefix = "/v2"
+      }
+    }
+    empty {
+    }
+  }`
+	if string(bs) != want {
+		t.Errorf("got\n%s\nwant\n%s", bs, want)
+	}
+	back := make(map[string]any)
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	paths := back["route"].(map[string]any)["match"].(map[string]any)["path"]
+	if list, ok := paths.([]any); !ok || len(list) != 2 {
+		t.Errorf("round trip: got %#v", back)
+	}
+
+	bs, err = Marshal(&matcher{Paths: []string{"/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "  path {\n    prefix = \"/\"\n  }"; string(bs) != want {
+		t.Errorf("root: got\n%s\nwant\n%s", bs, want)
+	}
+}
+
+type (
+	namedTags    map[string]string
+	namedPorts   []int
+	namedSquares map[string]*square
+	namedShapes  []*square
+	namedDoc     map[string]any
+)
+
+func TestMarshalNamedCollections(t *testing.T) {
+	type anonymous struct {
+		Tags    map[string]string            `hcl:"tags"`
+		Ports   []int                        `hcl:"ports"`
+		ByEnv   map[string]map[string]string `hcl:"by_env"`
+		Lists   map[string][]int             `hcl:"lists"`
+		TagsPtr *map[string]string           `hcl:"tags_ptr"`
+		Squares map[string]*square           `hcl:"sq,block"`
+		Shapes  []*square                    `hcl:"shape,block"`
+	}
+	type named struct {
+		Tags    namedTags             `hcl:"tags"`
+		Ports   namedPorts            `hcl:"ports"`
+		ByEnv   map[string]namedTags  `hcl:"by_env"`
+		Lists   map[string]namedPorts `hcl:"lists"`
+		TagsPtr *namedTags            `hcl:"tags_ptr"`
+		Squares namedSquares          `hcl:"sq,block"`
+		Shapes  namedShapes           `hcl:"shape,block"`
+	}
+	a := &anonymous{
+		Tags:    map[string]string{"a": "1"},
+		Ports:   []int{80, 443},
+		ByEnv:   map[string]map[string]string{"dev": {"host": "localhost"}},
+		Lists:   map[string][]int{"web": {8080}},
+		TagsPtr: &map[string]string{"b": "2"},
+		Squares: map[string]*square{"k": {SX: 1, SY: 2}},
+		Shapes:  []*square{{SX: 3, SY: 4}},
+	}
+	n := &named{
+		Tags:    namedTags{"a": "1"},
+		Ports:   namedPorts{80, 443},
+		ByEnv:   map[string]namedTags{"dev": {"host": "localhost"}},
+		Lists:   map[string]namedPorts{"web": {8080}},
+		TagsPtr: &namedTags{"b": "2"},
+		Squares: namedSquares{"k": {SX: 1, SY: 2}},
+		Shapes:  namedShapes{{SX: 3, SY: 4}},
+	}
+	want, err := Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	backA := new(anonymous)
+	if err := Unmarshal(want, backA); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backA, a) {
+		t.Errorf("anonymous: got %#v", backA)
+	}
+	backN := new(named)
+	if err := Unmarshal(got, backN); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backN, n) {
+		t.Errorf("named: got %#v", backN)
+	}
+
+	// a named generic map, as the document and as a value
+	doc := namedDoc{"a": 1, "svc": namedDoc{"api": map[string]any{"port": 80}}}
+	bs, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(strings.Fields(string(bs)), " "); s != `a = 1 svc "api" { port = 80 }` {
+		t.Errorf("got %s", bs)
+	}
+	backDoc := namedDoc{}
+	if err := Unmarshal(bs, &backDoc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backDoc, namedDoc{"a": 1, "svc": map[string]any{"api": map[string]any{"port": 80}}}) {
+		t.Errorf("got %#v", backDoc)
+	}
+}
+
+func TestMarshalBigNumbers(t *testing.T) {
+	input := `
+  big = 1234567890123456789012345678901234567890
+  max = 18446744073709551615
+  list = [18446744073709551616, 1]`
+	m := map[string]any{}
+	if err := Unmarshal([]byte(input), &m); err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := m["big"].(*big.Int); !ok || x.String() != "1234567890123456789012345678901234567890" {
+		t.Errorf("big = %v (type %T)", m["big"], m["big"])
+	}
+	if m["max"] != uint64(math.MaxUint64) {
+		t.Errorf("max = %v (type %T)", m["max"], m["max"])
+	}
+
+	bs, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"big = 1234567890123456789012345678901234567890", "max = 18446744073709551615", "18446744073709551616,"} {
+		if !strings.Contains(string(bs), line) {
+			t.Errorf("missing %q in:\n%s", line, bs)
+		}
+	}
+	back := map[string]any{}
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, m) {
+		t.Errorf("got %v, want %v", back, m)
+	}
+
+	// a field of type any keeps the number
+	type anyConfig struct {
+		C any `hcl:"c"`
+	}
+	a := new(anyConfig)
+	if err := Unmarshal([]byte("c = 1234567890123456789012345678901234567890"), a); err != nil {
+		t.Fatal(err)
+	}
+	bs, err = Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(bs)); got != "c = 1234567890123456789012345678901234567890" {
+		t.Errorf("got %q", got)
+	}
+
+	// typed fields take numbers, or strings as written by MarshalText
+	type typedConfig struct {
+		N *big.Int   `hcl:"n"`
+		V big.Int    `hcl:"v"`
+		F *big.Float `hcl:"f,optional"`
+	}
+	typed := new(typedConfig)
+	if err := Unmarshal([]byte("n = 1234567890123456789012345678901234567890\nv = \"7\"\nf = 1.5"), typed); err != nil {
+		t.Fatal(err)
+	}
+	if typed.N.String() != "1234567890123456789012345678901234567890" || typed.V.Int64() != 7 || typed.F.String() != "1.5" {
+		t.Errorf("got %v, %v, %v", typed.N, &typed.V, typed.F)
+	}
+	bs, err = Marshal(typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"n = 1234567890123456789012345678901234567890", "v = 7", "f = 1.5"} {
+		if !strings.Contains(string(bs), line) {
+			t.Errorf("missing %q in:\n%s", line, bs)
+		}
+	}
+}
+
+func TestMarshalObjectMaps(t *testing.T) {
+	type config struct {
+		Meta   map[string]string            `hcl:"meta"`
+		Nested map[string]map[string]string `hcl:"nested"`
+		Empty  map[string]string            `hcl:"empty"`
+		Lists  *map[string][]string         `hcl:"lists,optional"`
+	}
+	lists := map[string][]string{"q": {"}", "{"}}
+	c := &config{
+		Meta: map[string]string{"a": "{b}"},
+		Nested: map[string]map[string]string{
+			"x": {"k": "v}", "j": "{"},
+			"y": {},
+		},
+		Empty: map[string]string{},
+		Lists: &lists,
+	}
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"meta = {", "nested = {", "empty = {}", "lists = {"} {
+		if !strings.Contains(string(bs), line) {
+			t.Errorf("missing %q in:\n%s", line, bs)
+		}
+	}
+	got := new(config)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatalf("%v\n%s", err, bs)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("got %#v from:\n%s", got, bs)
+	}
+}
@@ -0,0 +1,261 @@
+package dethcl
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// MergeOptions controls how MergeWithOptions combines two documents.
+type MergeOptions struct {
+	// AppendSlices appends the list values of the override, and its
+	// repeated unlabeled blocks, to those of the base instead of replacing
+	// them.
+	AppendSlices bool
+}
+
+// Merge combines the HCL documents base and override, e.g. a shared
+// configuration and the settings of one environment. The documents are
+// merged as written, without being decoded, so attributes stay attributes
+// and expressions such as var.region or upper(name) are kept as they are:
+//
+//   - an attribute of override replaces that of base, except that two object
+//     values, tags = { ... }, are merged key by key;
+//   - blocks of the same type and labels, such as service "api" { ... }, are
+//     merged recursively, and so is a single unlabeled block of a type in
+//     each document;
+//   - repeated unlabeled blocks of a type, rule { ... } rule { ... }, are a
+//     list, and those of override replace those of base.
+//
+// Attributes and blocks of base come first, in their order, followed by those
+// only found in override. The result is formatted by hclwrite; comments are
+// dropped. See MergeWithOptions to append lists instead.
+func Merge(base, override []byte) ([]byte, error) {
+	return MergeWithOptions(base, override, MergeOptions{})
+}
+
+// MergeWithOptions combines base and override like Merge, as set by opts.
+func MergeWithOptions(base, override []byte, opts MergeOptions) ([]byte, error) {
+	_, baseBody, err := parseHCLFile(base)
+	if err != nil {
+		return nil, err
+	}
+	_, overrideBody, err := parseHCLFile(override)
+	if err != nil {
+		return nil, err
+	}
+	f := hclwrite.NewEmptyFile()
+	mergeBodies(f.Body(), mergeSide{baseBody, base}, mergeSide{overrideBody, override}, &opts)
+	out := hclwrite.Format(f.Bytes())
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// mergeSide is a body of one of the merged documents, with its source.
+type mergeSide struct {
+	body *hclsyntax.Body
+	src  []byte
+}
+
+// raw returns the source of rng as tokens.
+func (s mergeSide) raw(rng hcl.Range) hclwrite.Tokens {
+	return hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: rng.SliceBytes(s.src)}}
+}
+
+// mergeBodies writes into dst the body of override merged into that of base.
+// Either side may have a nil body.
+func mergeBodies(dst *hclwrite.Body, base, override mergeSide, opts *MergeOptions) {
+	var baseAttrs, overrideAttrs hclsyntax.Attributes
+	var baseBlocks, overrideBlocks hclsyntax.Blocks
+	if base.body != nil {
+		baseAttrs, baseBlocks = base.body.Attributes, base.body.Blocks
+	}
+	if override.body != nil {
+		overrideAttrs, overrideBlocks = override.body.Attributes, override.body.Blocks
+	}
+
+	// an attribute and blocks of the same name: the form of override wins
+	overrideTypes := make(map[string]bool)
+	for _, block := range overrideBlocks {
+		overrideTypes[block.Type] = true
+	}
+	for _, attr := range sortedAttributes(baseAttrs) {
+		if overrideTypes[attr.Name] {
+			continue
+		}
+		tokens := base.raw(attr.Expr.Range())
+		if other, ok := overrideAttrs[attr.Name]; ok {
+			tokens = mergeExpressions(base, attr.Expr, override, other.Expr, opts)
+		}
+		dst.SetAttributeRaw(attr.Name, tokens)
+	}
+	for _, attr := range sortedAttributes(overrideAttrs) {
+		if _, ok := baseAttrs[attr.Name]; !ok {
+			dst.SetAttributeRaw(attr.Name, override.raw(attr.Expr.Range()))
+		}
+	}
+
+	var types []string
+	byType := make(map[string][2]hclsyntax.Blocks)
+	for i, blocks := range []hclsyntax.Blocks{baseBlocks, overrideBlocks} {
+		for _, block := range blocks {
+			if i == 0 {
+				if _, ok := overrideAttrs[block.Type]; ok {
+					continue
+				}
+			}
+			pair, ok := byType[block.Type]
+			if !ok {
+				types = append(types, block.Type)
+			}
+			pair[i] = append(pair[i], block)
+			byType[block.Type] = pair
+		}
+	}
+	for _, typ := range types {
+		pair := byType[typ]
+		mergeBlocks(dst, base, pair[0], override, pair[1], opts)
+	}
+}
+
+// mergeBlocks writes into dst the blocks of one type of base and override.
+func mergeBlocks(dst *hclwrite.Body, base mergeSide, baseBlocks hclsyntax.Blocks, override mergeSide, overrideBlocks hclsyntax.Blocks, opts *MergeOptions) {
+	var baseList, overrideList hclsyntax.Blocks
+	for _, block := range baseBlocks {
+		if len(block.Labels) == 0 {
+			baseList = append(baseList, block)
+		}
+	}
+	for _, block := range overrideBlocks {
+		if len(block.Labels) == 0 {
+			overrideList = append(overrideList, block)
+		}
+	}
+	if len(baseList) == 1 && len(overrideList) == 1 {
+		writeMergedBlock(dst, baseList[0], mergeSide{baseList[0].Body, base.src}, mergeSide{overrideList[0].Body, override.src}, opts)
+		baseList, overrideList = nil, nil
+	} else if len(overrideList) > 0 && !opts.AppendSlices {
+		baseList = nil
+	}
+
+	labeled := make(map[string]*hclsyntax.Block)
+	for _, block := range overrideBlocks {
+		if key := strings.Join(block.Labels, "\x00"); len(block.Labels) > 0 && labeled[key] == nil {
+			labeled[key] = block
+		}
+	}
+	for _, block := range baseBlocks {
+		if len(block.Labels) == 0 {
+			continue
+		}
+		other := labeled[strings.Join(block.Labels, "\x00")]
+		if other == nil {
+			writeMergedBlock(dst, block, mergeSide{block.Body, base.src}, mergeSide{}, opts)
+			continue
+		}
+		writeMergedBlock(dst, block, mergeSide{block.Body, base.src}, mergeSide{other.Body, override.src}, opts)
+		delete(labeled, strings.Join(block.Labels, "\x00"))
+	}
+	for _, block := range baseList {
+		writeMergedBlock(dst, block, mergeSide{block.Body, base.src}, mergeSide{}, opts)
+	}
+	for _, block := range overrideBlocks {
+		key := strings.Join(block.Labels, "\x00")
+		if len(block.Labels) > 0 && labeled[key] != block {
+			continue
+		}
+		if len(block.Labels) == 0 && len(overrideList) == 0 {
+			continue
+		}
+		writeMergedBlock(dst, block, mergeSide{}, mergeSide{block.Body, override.src}, opts)
+	}
+}
+
+// writeMergedBlock appends to dst a block of the type and labels of block,
+// with the body of override merged into that of base.
+func writeMergedBlock(dst *hclwrite.Body, block *hclsyntax.Block, base, override mergeSide, opts *MergeOptions) {
+	mergeBodies(dst.AppendNewBlock(block.Type, block.Labels).Body(), base, override, opts)
+}
+
+// mergeExpressions returns the tokens of the expression of override merged
+// into that of base: the keys of two objects are merged, the elements of two
+// lists are appended with MergeOptions.AppendSlices, and otherwise override
+// wins.
+func mergeExpressions(base mergeSide, baseExpr hclsyntax.Expression, override mergeSide, overrideExpr hclsyntax.Expression, opts *MergeOptions) hclwrite.Tokens {
+	if baseObject, ok := baseExpr.(*hclsyntax.ObjectConsExpr); ok {
+		if overrideObject, ok := overrideExpr.(*hclsyntax.ObjectConsExpr); ok {
+			return mergeObjects(base, baseObject, override, overrideObject, opts)
+		}
+	}
+	if baseTuple, ok := baseExpr.(*hclsyntax.TupleConsExpr); ok && opts.AppendSlices {
+		if overrideTuple, ok := overrideExpr.(*hclsyntax.TupleConsExpr); ok {
+			elements := make([]hclwrite.Tokens, 0, len(baseTuple.Exprs)+len(overrideTuple.Exprs))
+			for _, expr := range baseTuple.Exprs {
+				elements = append(elements, base.raw(expr.Range()))
+			}
+			for _, expr := range overrideTuple.Exprs {
+				elements = append(elements, override.raw(expr.Range()))
+			}
+			return hclwrite.TokensForTuple(elements)
+		}
+	}
+	return override.raw(overrideExpr.Range())
+}
+
+// mergeObjects returns the tokens of the object override merged into base.
+func mergeObjects(base mergeSide, baseObject *hclsyntax.ObjectConsExpr, override mergeSide, overrideObject *hclsyntax.ObjectConsExpr, opts *MergeOptions) hclwrite.Tokens {
+	overrideItems := make(map[string]hclsyntax.ObjectConsItem)
+	for _, item := range overrideObject.Items {
+		overrideItems[objectKeyName(override, item.KeyExpr)] = item
+	}
+	var items []hclwrite.ObjectAttrTokens
+	seen := make(map[string]bool)
+	for _, item := range baseObject.Items {
+		name := objectKeyName(base, item.KeyExpr)
+		seen[name] = true
+		value := base.raw(item.ValueExpr.Range())
+		if other, ok := overrideItems[name]; ok {
+			value = mergeExpressions(base, item.ValueExpr, override, other.ValueExpr, opts)
+		}
+		items = append(items, hclwrite.ObjectAttrTokens{Name: base.raw(item.KeyExpr.Range()), Value: value})
+	}
+	for _, item := range overrideObject.Items {
+		if !seen[objectKeyName(override, item.KeyExpr)] {
+			items = append(items, hclwrite.ObjectAttrTokens{Name: override.raw(item.KeyExpr.Range()), Value: override.raw(item.ValueExpr.Range())})
+		}
+	}
+	return hclwrite.TokensForObject(items)
+}
+
+// objectKeyName returns the name of the object key expr, so that a = 1 and
+// "a" = 2 set the same key, or its source if it is not a constant.
+func objectKeyName(side mergeSide, expr hclsyntax.Expression) string {
+	if key, ok := expr.(*hclsyntax.ObjectConsKeyExpr); ok {
+		if name := hcl.ExprAsKeyword(key.Wrapped); name != "" {
+			return name
+		}
+		if v, diags := key.Wrapped.Value(nil); !diags.HasErrors() && v.Type() == cty.String && v.IsKnown() && !v.IsNull() {
+			return v.AsString()
+		}
+	}
+	return string(expr.Range().SliceBytes(side.src))
+}
+
+// sortedAttributes returns attrs in the order of the source.
+func sortedAttributes(attrs hclsyntax.Attributes) []*hclsyntax.Attribute {
+	list := make([]*hclsyntax.Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		list = append(list, attr)
+	}
+	slices.SortFunc(list, func(a, b *hclsyntax.Attribute) int {
+		return a.SrcRange.Start.Byte - b.SrcRange.Start.Byte
+	})
+	return list
+}
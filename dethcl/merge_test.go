@@ -0,0 +1,154 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := `
+name    = "app"
+replicas = 1
+ports   = [80]
+service "api" {
+  port = 8080
+  host = "localhost"
+}
+service "db" {
+  port = 5432
+}
+rule {
+  allow = "a"
+}`
+	override := `
+replicas = 3
+ports    = [443]
+service "api" {
+  host = "api.example.com"
+}
+service "cache" {
+  port = 6379
+}
+rule {
+  allow = "b"
+}
+rule {
+  allow = "c"
+}`
+	bs, err := Merge([]byte(base), []byte(override))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]any)
+	if err := Unmarshal(bs, &m); err != nil {
+		t.Fatalf("%v\n%s", err, bs)
+	}
+	if m["name"] != "app" || m["replicas"] != 3 {
+		t.Errorf("got name %v, replicas %v", m["name"], m["replicas"])
+	}
+	if ports := m["ports"].([]any); len(ports) != 1 || ports[0] != 443 {
+		t.Errorf("got ports %v", ports)
+	}
+	services := m["service"].(map[string]any)
+	api := services["api"].(map[string]any)
+	if api["port"] != 8080 || api["host"] != "api.example.com" {
+		t.Errorf("got api %v", api)
+	}
+	if len(services) != 3 || services["db"] == nil || services["cache"] == nil {
+		t.Errorf("got services %v", services)
+	}
+	if rules := m["rule"].([]any); len(rules) != 2 {
+		t.Errorf("got rules %v", rules)
+	}
+
+	bs, err = MergeWithOptions([]byte(base), []byte(override), MergeOptions{AppendSlices: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = make(map[string]any)
+	if err := Unmarshal(bs, &m); err != nil {
+		t.Fatalf("%v\n%s", err, bs)
+	}
+	if ports := m["ports"].([]any); len(ports) != 2 || ports[0] != 80 || ports[1] != 443 {
+		t.Errorf("got ports %v", ports)
+	}
+	rules := m["rule"].([]any)
+	var allowed []string
+	for _, rule := range rules {
+		allowed = append(allowed, rule.(map[string]any)["allow"].(string))
+	}
+	if strings.Join(allowed, ",") != "a,b,c" {
+		t.Errorf("got rules %v", rules)
+	}
+
+	if _, err := Merge([]byte("a = "), []byte("b = 1")); err == nil {
+		t.Error("expected an error for invalid base")
+	}
+}
+
+func TestMergeKeepsAttributes(t *testing.T) {
+	base := `
+tags = {
+  a    = 1
+  "b"  = { x = 1, y = 2 }
+}
+name   = upper(var.name)
+note   = "say \"hi\"\n"
+labels = ["a"]
+`
+	override := `
+tags = { b = { y = 3 }, c = "${var.env}-c" }
+labels = ["b"]
+`
+	bs, err := MergeWithOptions([]byte(base), []byte(override), MergeOptions{AppendSlices: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"tags = {",
+		"name   = upper(var.name)",
+		`note   = "say \"hi\"\n"`,
+		`labels = ["a", "b"]`,
+		`c = "${var.env}-c"`,
+	} {
+		if !strings.Contains(string(bs), want) {
+			t.Errorf("missing %q in\n%s", want, bs)
+		}
+	}
+	if strings.Contains(string(bs), "tags {") {
+		t.Errorf("tags became a block:\n%s", bs)
+	}
+
+	bs, err = Merge([]byte(base), []byte(override))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]any)
+	if err := UnmarshalWithNativeVars(bs, &m, map[string]any{"name": "app", "env": "dev"}); err != nil {
+		t.Fatalf("%v\n%s", err, bs)
+	}
+	tags := m["tags"].(map[string]any)
+	b := tags["b"].(map[string]any)
+	if tags["a"] != 1 || b["x"] != 1 || b["y"] != 3 || tags["c"] != "dev-c" {
+		t.Errorf("got tags %v", tags)
+	}
+	if m["name"] != "APP" || m["note"] != "say \"hi\"\n" {
+		t.Errorf("got name %q, note %q", m["name"], m["note"])
+	}
+	if labels := m["labels"].([]any); len(labels) != 1 || labels[0] != "b" {
+		t.Errorf("got labels %v", labels)
+	}
+
+	// the form of the override wins
+	bs, err = Merge([]byte("tags = { a = 1 }\n"), []byte("tags {\n  b = 2\n}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bs) != "tags {\n  b = 2\n}\n" {
+		t.Errorf("got\n%s", bs)
+	}
+
+	if _, err := Merge([]byte("a = 1"), []byte("b = ")); err == nil {
+		t.Error("expected an error for invalid override")
+	}
+}
@@ -0,0 +1,432 @@
+package dethcl
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/genelet/horizon/utils"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// MarshalOptions controls optional behaviour of MarshalWithOptions.
+// The zero value gives the same output as Marshal.
+type MarshalOptions struct {
+	// ReservedWords lists keys that are written quoted, e.g. "type" = "web",
+	// for consumers whose HCL dialect reserves extra words. HCL only allows
+	// quoted keys inside object values such as labels = { "type" = "web" };
+	// attribute and block names in a body are written unchanged.
+	ReservedWords []string
+
+	// SortFields, if set, reorders the output of every struct: attributes are
+	// sorted among themselves, then blocks among themselves, comparing their
+	// HCL names. The sort is stable, so repeated blocks keep their order, and
+	// labels always stay with their block.
+	SortFields func(a, b string) bool
+
+	// Header, if not empty, is written as a comment above the output, one
+	// "# " line per line of Header, e.g. "Code generated by foo. DO NOT EDIT.",
+	// followed by a blank line. Unmarshal ignores it like any other comment.
+	Header string
+
+	// ShowOmitted writes optional fields left out for their zero value as
+	// comments, e.g. # port = 0  (optional, omitted), so that the output shows
+	// every setting a reader may add. They follow the attributes of a struct.
+	ShowOmitted bool
+
+	// Terraform follows the conventions of Terraform configuration files.
+	// Maps of fields not tagged block are written as object attributes,
+	// tags = { Name = "web" }, as are nested maps inside generic maps, and a
+	// field tagged block holding maps, e.g. map[string]map[string]any, gives
+	// one labeled block per key, provider "aws" { ... }. At the top of a
+	// generic document, the block types of Terraform take their labels from
+	// the map keys, e.g. resource "aws_instance" "web" { ... } from
+	// {"resource": {"aws_instance": {"web": {...}}}}. The output is laid out
+	// as terraform fmt does, without the indentation of the root level and
+	// ending with a newline, ready to be saved as a .tf file.
+	Terraform bool
+
+	// IndentString is the indentation of one nesting level, e.g. "\t" or four
+	// spaces. It defaults to two spaces. Terraform output always uses the two
+	// spaces of terraform fmt.
+	IndentString string
+
+	// RedactSensitive writes fields tagged sensitive as "(sensitive)" instead
+	// of their values, e.g. for logging a configuration. A pointer, list, map
+	// or block field is replaced as a whole by the attribute
+	// name = "(sensitive)". The output no longer decodes to the original value.
+	RedactSensitive bool
+
+	// InlinePrimitiveSlices writes lists of strings, numbers and booleans in
+	// generic values, e.g. a []any in a map[string]any, on one line as
+	// tags = ["a", "b", "c"] when they fit in inlineSliceWidth columns,
+	// instead of one element per line. Lists holding maps, structs or other
+	// lists keep their layout. Fields of type []string or []int are always
+	// written on one line.
+	InlinePrimitiveSlices bool
+
+	// SortMapKeys writes the labeled blocks of a map field, e.g.
+	// map[string]*Service or map[[2]string]*Service, in the order of their
+	// labels instead of the random order of map iteration, so that the same
+	// value always gives the same bytes. Generic maps are always sorted.
+	SortMapKeys bool
+
+	// UseJSONTagFallback names the fields without hcl tag by their json tag,
+	// for structs shared with encoding/json, instead of the lowercased field
+	// name. A field tagged json:"port,omitempty" is written as port and left
+	// out when empty, as with hcl:"port,omitempty", and one tagged json:"-"
+	// is left out. An hcl tag always wins. Decode the output with
+	// UnmarshalOptions.UseJSONTagFallback, as Unmarshal reads the lowercased
+	// field names.
+	UseJSONTagFallback bool
+
+	// ExplicitNulls writes every nil pointer or interface field as
+	// name = null, as the explicitnull modifier does for one field, instead
+	// of leaving it out. Unmarshal sets a field assigned null to nil, while
+	// an absent field keeps its value, so that a layered configuration can
+	// tell "cleared" from "not mentioned". Fields tagged omitempty are still
+	// left out.
+	ExplicitNulls bool
+
+	// MaxDepth limits how deep values may nest, so that a cyclic or
+	// pathologically deep value fails with an error instead of exhausting
+	// the stack. Zero means 1000 levels.
+	MaxDepth int
+}
+
+// indent returns the indentation of level. It is safe to call on a nil o.
+func (o *MarshalOptions) indent(level int) string {
+	if o == nil || o.IndentString == "" {
+		return indent(level)
+	}
+	return strings.Repeat(o.IndentString, level)
+}
+
+// redact reports whether the value of field is replaced by redactedValue. It
+// is safe to call on a nil o.
+func (o *MarshalOptions) redact(field reflect.StructField) bool {
+	return o != nil && o.RedactSensitive && hasTagOption(parseHCLTag(field.Tag)[1], tagModifierSensitive)
+}
+
+// inlineSlices reports whether short lists of primitives are written on one
+// line. It is safe to call on a nil o.
+func (o *MarshalOptions) inlineSlices() bool {
+	return o != nil && o.InlinePrimitiveSlices
+}
+
+// sortMapKeys reports whether the blocks of a map field are sorted by their
+// labels. It is safe to call on a nil o.
+func (o *MarshalOptions) sortMapKeys() bool {
+	return o != nil && o.SortMapKeys
+}
+
+// jsonTags reports whether fields without hcl tag are named by their json
+// tag. It is safe to call on a nil o.
+func (o *MarshalOptions) jsonTags() bool {
+	return o != nil && o.UseJSONTagFallback
+}
+
+// explicitNulls reports whether nil pointer and interface fields are written
+// as null. It is safe to call on a nil o.
+func (o *MarshalOptions) explicitNulls() bool {
+	return o != nil && o.ExplicitNulls
+}
+
+// maxDepth returns the nesting limit of o. It is safe to call on a nil o.
+func (o *MarshalOptions) maxDepth() int {
+	if o == nil || o.MaxDepth <= 0 {
+		return defaultMaxDepth
+	}
+	return o.MaxDepth
+}
+
+// reindent replaces the two-space indentation of hclwrite output, e.g. of
+// a multi-line object attribute, with o.IndentString. Quoted strings never
+// span lines in such output, so every leading space outside of heredocs is
+// indentation.
+func (o *MarshalOptions) reindent(bs []byte) []byte {
+	if o == nil || o.IndentString == "" || o.IndentString == "  " {
+		return bs
+	}
+	inside := heredocLines(bs)
+	lines := bytes.Split(bs, []byte("\n"))
+	for i, line := range lines {
+		n := len(line) - len(bytes.TrimLeft(line, " "))
+		if n >= 2 && !inside[i] {
+			lines[i] = append([]byte(strings.Repeat(o.IndentString, n/2)+strings.Repeat(" ", n%2)), line[n:]...)
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// MarshalWithOptions encodes a Go value into HCL format like Marshal, applying opts.
+//
+// Example:
+//
+//	type Config struct {
+//	    Labels map[string]string `hcl:"labels"`
+//	}
+//
+//	cfg := &Config{Labels: map[string]string{"type": "web"}}
+//	hcl, err := MarshalWithOptions(cfg, MarshalOptions{ReservedWords: []string{"type"}})
+//	// Output:
+//	// labels = {
+//	//   "type" = "web"
+//	// }
+func MarshalWithOptions(current any, opts MarshalOptions) ([]byte, error) {
+	if current == nil {
+		return nil, nil
+	}
+	bs, err := marshalLevel(&opts, current, false, 0)
+	if err != nil || bs == nil {
+		return bs, err
+	}
+	if len(opts.ReservedWords) > 0 {
+		bs, err = quoteReservedKeys(bs, opts.ReservedWords)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Terraform {
+		bs = append(hclwrite.Format(bytes.TrimLeft(bs, "\n")), '\n')
+	}
+	if opts.Header != "" {
+		header := append(headerComment(opts.Header), '\n')
+		bs = append(header, bs...)
+	}
+	return bs, nil
+}
+
+// headerComment turns text into a block of "#" comment lines, each ending
+// with a newline. Blank lines of text become a bare "#".
+func headerComment(text string) []byte {
+	var out []byte
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			out = append(out, "#\n"...)
+		} else {
+			out = append(out, "# "+line+"\n"...)
+		}
+	}
+	return out
+}
+
+// quoteReservedKeys quotes object keys of the HCL source that are in reserved.
+// It walks the tokens keeping a stack of open brackets, so that only keys of
+// object constructors are changed and block bodies are left alone.
+func quoteReservedKeys(src []byte, reserved []string) ([]byte, error) {
+	tokens, diags := hclsyntax.LexConfig(src, generateTempHCLFileName(), hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to lex HCL: %w", diags)
+	}
+
+	// previous returns the type of the closest token before i, skipping
+	// newlines if asked
+	previous := func(i int, skipNewline bool) hclsyntax.TokenType {
+		for j := i - 1; j >= 0; j-- {
+			switch tokens[j].Type {
+			case hclsyntax.TokenComment:
+				continue
+			case hclsyntax.TokenNewline:
+				if skipNewline {
+					continue
+				}
+			default:
+			}
+			return tokens[j].Type
+		}
+		return hclsyntax.TokenNil
+	}
+
+	var keys []hcl.Range
+	var objects []bool // true for each open object constructor
+	for i, token := range tokens {
+		switch token.Type {
+		case hclsyntax.TokenOBrace:
+			switch previous(i, true) {
+			case hclsyntax.TokenEqual, hclsyntax.TokenComma, hclsyntax.TokenColon,
+				hclsyntax.TokenOBrack, hclsyntax.TokenOParen, hclsyntax.TokenFatArrow:
+				objects = append(objects, true)
+			default: // block body
+				objects = append(objects, false)
+			}
+		case hclsyntax.TokenOBrack, hclsyntax.TokenOParen,
+			hclsyntax.TokenTemplateInterp, hclsyntax.TokenTemplateControl:
+			objects = append(objects, false)
+		case hclsyntax.TokenCBrace, hclsyntax.TokenCBrack, hclsyntax.TokenCParen,
+			hclsyntax.TokenTemplateSeqEnd:
+			if len(objects) > 0 {
+				objects = objects[:len(objects)-1]
+			}
+		case hclsyntax.TokenIdent:
+			if len(objects) == 0 || !objects[len(objects)-1] || !slices.Contains(reserved, string(token.Bytes)) {
+				continue
+			}
+			switch previous(i, false) {
+			case hclsyntax.TokenOBrace, hclsyntax.TokenComma, hclsyntax.TokenNewline:
+			default:
+				continue
+			}
+			if i+1 < len(tokens) && (tokens[i+1].Type == hclsyntax.TokenEqual || tokens[i+1].Type == hclsyntax.TokenColon) {
+				keys = append(keys, token.Range)
+			}
+		default:
+		}
+	}
+
+	if len(keys) == 0 {
+		return src, nil
+	}
+	var out []byte
+	start := 0
+	for _, rng := range keys {
+		out = append(out, src[start:rng.Start.Byte]...)
+		out = append(out, fmt.Sprintf("%q", string(src[rng.Start.Byte:rng.End.Byte]))...)
+		start = rng.End.Byte
+	}
+	out = append(out, src[start:]...)
+	return out, nil
+}
+
+// UnmarshalOptions controls optional behaviour of UnmarshalWithOptions.
+// The zero value decodes like Unmarshal.
+type UnmarshalOptions struct {
+	// MaxLabels limits the number of labels of blocks decoded into generic
+	// maps, where each label adds one level of nesting. Blocks with more
+	// labels give an error. Zero means no limit.
+	MaxLabels int
+
+	// NumberMode selects the Go types of numbers decoded into interface
+	// values, such as the entries of a map[string]any or a field of type any.
+	// The default utils.NumberAuto picks int, int64, uint64, float32 or
+	// float64 by value, or *big.Int and *big.Float for numbers beyond them.
+	// utils.NumberFloat64 and utils.NumberJSON give one stable type instead.
+	// utils.NumberInt64 gives int64 for integers and float64 for other
+	// numbers, except *big.Int for integers beyond the int64 range, so check
+	// the type of the value rather than asserting int64.
+	NumberMode utils.NumberMode
+
+	// LabelCaseFold lower-cases the block labels used as keys of struct maps
+	// such as map[string]*Service, using strings.ToLower, so that "Prod" and
+	// "prod" give the same key. Labels of one field that differ only in case
+	// give an error rather than overwrite each other. Label fields of the
+	// decoded structs keep the label as written.
+	LabelCaseFold bool
+
+	// ContinueOnError decodes all blocks even if some fail, leaving out the
+	// failed entries, and returns the errors of all of them joined with
+	// errors.Join, each a *FieldError with its path. By default decoding
+	// stops at the first failing block.
+	ContinueOnError bool
+
+	// RootBlock, if not empty, expects the document to be a single block of
+	// that type, e.g. service "api" { port = 8080 } for "service", and decodes
+	// its body into the target, whose label fields take the labels of the
+	// block. The labels passed to UnmarshalWithOptions are then ignored.
+	RootBlock string
+
+	// DuplicateAttr selects how an attribute set more than once in the same
+	// body is handled. The default DuplicateError rejects the document like
+	// Unmarshal; DuplicateFirst and DuplicateLast keep one of the values, and
+	// DuplicateCollect decodes them all as a list.
+	DuplicateAttr DuplicateAttr
+
+	// RejectDuplicateKeys fails on an object attribute giving the same key
+	// twice, e.g. env = { a = 1, a = 2 }, where HCL silently keeps the last
+	// value, with the position of the second key. Attributes of a body set
+	// twice are covered by DuplicateAttr instead.
+	RejectDuplicateKeys bool
+
+	// InternStrings makes equal block labels and map keys share one string,
+	// in label fields, in the keys of struct maps and in generic maps. It
+	// saves memory on large documents repeating the same labels, at the cost
+	// of a lookup per label.
+	InternStrings bool
+
+	// UseJSONTagFallback reads the fields without hcl tag by their json tag,
+	// as MarshalOptions.UseJSONTagFallback writes them, instead of the
+	// lowercased field name. Fields tagged json:"-" are not decoded.
+	UseJSONTagFallback bool
+
+	// MaxDepth limits how deep the document may nest, counting blocks, their
+	// labels, and list and object values, so that a pathologically deep
+	// document fails with an error instead of exhausting the stack. Zero
+	// means 1000 levels.
+	MaxDepth int
+}
+
+// UnmarshalWithOptions decodes HCL data into a Go value like Unmarshal, applying opts.
+//
+// Example:
+//
+//	hcl := []byte(`rule "a" "b" "c" {
+//	    action = "allow"
+//	}`)
+//	var m map[string]any
+//	err := UnmarshalWithOptions(hcl, &m, UnmarshalOptions{MaxLabels: 3})
+//	// m["rule"]["a"]["b"]["c"]["action"] == "allow"
+func UnmarshalWithOptions(hclData []byte, current any, opts UnmarshalOptions, labels ...string) error {
+	if current == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(current)
+	if rv.Kind() != reflect.Pointer {
+		return fmt.Errorf("non-pointer or nil data")
+	}
+	if rv.IsNil() {
+		return nil
+	}
+	if opts.MaxLabels < 0 {
+		return fmt.Errorf("negative MaxLabels %d", opts.MaxLabels)
+	}
+	if opts.MaxDepth < 0 {
+		return fmt.Errorf("negative MaxDepth %d", opts.MaxDepth)
+	}
+	hclData, err := resolveDuplicates(hclData, opts.DuplicateAttr)
+	if err != nil {
+		return err
+	}
+	if opts.RejectDuplicateKeys {
+		if err := checkDuplicateKeys(hclData); err != nil {
+			return err
+		}
+	}
+	if opts.RootBlock != "" {
+		hclData, labels, err = rootBlock(hclData, opts.RootBlock)
+		if err != nil {
+			return err
+		}
+	}
+	if ok, err := unmarshalCustom(nil, hclData, current, labels...); ok {
+		return err
+	}
+	state := newDecodeState()
+	state.maxLabels = opts.MaxLabels
+	state.numbers = opts.NumberMode
+	state.foldCase = opts.LabelCaseFold
+	state.keepGoing = opts.ContinueOnError
+	state.maxDepth = opts.MaxDepth
+	state.jsonNames = opts.UseJSONTagFallback
+	if opts.InternStrings {
+		state.strs = make(map[string]string)
+	}
+	return unmarshalSpec(hclData, current, nil, nil, state, labels...)
+}
+
+// rootBlock returns the body and labels of the only block of hclData, which
+// must be of type blockType and stand alone in the document.
+func rootBlock(hclData []byte, blockType string) ([]byte, []string, error) {
+	file, body, err := parseHCLFile(hclData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(body.Attributes) > 0 || len(body.Blocks) != 1 || body.Blocks[0].Type != blockType {
+		return nil, nil, fmt.Errorf("expected a single %s block at the root", blockType)
+	}
+	return getBlockBytes(body.Blocks[0], file)
+}
@@ -0,0 +1,834 @@
+package dethcl
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/genelet/horizon/utils"
+)
+
+func TestMarshalReservedWords(t *testing.T) {
+	type service struct {
+		Type   string            `hcl:"type"`
+		Labels map[string]string `hcl:"labels"`
+		Extra  map[string]any    `hcl:"extra,block"`
+	}
+	s := &service{
+		Type:   "web",
+		Labels: map[string]string{"type": "frontend", "tier": "1"},
+		Extra:  map[string]any{"nested": map[string]any{"type": "x"}},
+	}
+	bs, err := MarshalWithOptions(s, MarshalOptions{ReservedWords: []string{"type"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := string(bs)
+	if !strings.Contains(output, `"type" = "frontend"`) {
+		t.Errorf("object key not quoted: %s", output)
+	}
+	if !strings.Contains(output, `  type = "web"`) || strings.Contains(output, `tier"`) {
+		t.Errorf("unexpected quoting: %s", output)
+	}
+
+	got := new(service)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatalf("%v\n%s", err, bs)
+	}
+	if got.Type != "web" || !reflect.DeepEqual(got.Labels, s.Labels) {
+		t.Errorf("round trip: %#v", got)
+	}
+
+	plain, err := MarshalWithOptions(s, MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(plain), `"type"`) {
+		t.Errorf("zero options should not quote: %s", plain)
+	}
+}
+
+func TestMarshalSortFields(t *testing.T) {
+	type item struct {
+		Kind  string `hcl:"kind,label"`
+		Name  string `hcl:"name,label"`
+		Zeta  int    `hcl:"zeta"`
+		Alpha int    `hcl:"alpha"`
+	}
+	type doc struct {
+		Zone  string  `hcl:"zone"`
+		Items []*item `hcl:"items,block"`
+		Beta  *item   `hcl:"beta,block"`
+		App   string  `hcl:"app"`
+	}
+	d := &doc{
+		Zone:  "z",
+		Items: []*item{{Kind: "k", Name: "second", Zeta: 1, Alpha: 2}, {Kind: "k", Name: "first", Zeta: 3, Alpha: 4}},
+		Beta:  &item{Kind: "b", Name: "n", Zeta: 5, Alpha: 6},
+		App:   "a",
+	}
+	bs, err := MarshalWithOptions(d, MarshalOptions{SortFields: func(a, b string) bool { return a < b }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `  app  = "a"
+  zone = "z"
+  beta "b" "n" {
+    alpha = 6
+    zeta  = 5
+  }
+  items "k" "second" {
+    alpha = 2
+    zeta  = 1
+  }
+  items "k" "first" {
+    alpha = 4
+    zeta  = 3
+  }`
+	if string(bs) != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", bs, expected)
+	}
+}
+
+func TestUnmarshalMaxLabels(t *testing.T) {
+	data := []byte(`
+rule "a" "b" "c" {
+  action = "allow"
+}
+
+rule "a" "b" "d" {
+  action = "deny"
+}`)
+
+	var m map[string]any
+	if err := Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"rule": map[string]any{
+			"a": map[string]any{
+				"b": map[string]any{
+					"c": map[string]any{"action": "allow"},
+					"d": map[string]any{"action": "deny"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("got %#v", m)
+	}
+
+	m = nil
+	if err := UnmarshalWithOptions(data, &m, UnmarshalOptions{MaxLabels: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("got %#v", m)
+	}
+
+	m = nil
+	err := UnmarshalWithOptions(data, &m, UnmarshalOptions{MaxLabels: 2})
+	if err == nil || !strings.Contains(err.Error(), "too many labels") {
+		t.Errorf("expected label limit error, got %v", err)
+	}
+
+	m = nil
+	err = Unmarshal([]byte(`
+rule "a" {}
+rule "a" "b" {}`), &m)
+	if err == nil {
+		t.Errorf("expected error for mixed label counts")
+	}
+}
+
+func TestMarshalHeader(t *testing.T) {
+	type config struct {
+		Name string `hcl:"name"`
+		Port int    `hcl:"port"`
+	}
+	cfg := &config{Name: "api", Port: 8080}
+	bs, err := MarshalWithOptions(cfg, MarshalOptions{Header: "Code generated by foo. DO NOT EDIT.\n\nSource: api.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := "# Code generated by foo. DO NOT EDIT.\n#\n# Source: api.yaml\n\n"
+	if !strings.HasPrefix(string(bs), prefix) {
+		t.Errorf("unexpected header:\n%s", bs)
+	}
+
+	var back config
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != *cfg {
+		t.Errorf("got %#v", back)
+	}
+}
+
+func TestUnmarshalNumberMode(t *testing.T) {
+	data := []byte(`
+small = 1
+large = 5000000000
+ratio = 1.5
+list  = [2, 3]`)
+
+	var m map[string]any
+	if err := UnmarshalWithOptions(data, &m, UnmarshalOptions{NumberMode: utils.NumberInt64}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"small": int64(1),
+		"large": int64(5000000000),
+		"ratio": 1.5,
+		"list":  []any{int64(2), int64(3)},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("got %#v", m)
+	}
+
+	// beyond int64, NumberInt64 gives *big.Int
+	m = nil
+	if err := UnmarshalWithOptions([]byte("huge = 123456789012345678901234567890"), &m, UnmarshalOptions{NumberMode: utils.NumberInt64}); err != nil {
+		t.Fatal(err)
+	}
+	if huge, ok := m["huge"].(*big.Int); !ok || huge.String() != "123456789012345678901234567890" {
+		t.Errorf("got %#v", m["huge"])
+	}
+
+	type config struct {
+		Small any `hcl:"small"`
+		Large any `hcl:"large"`
+		Ratio any `hcl:"ratio"`
+		List  any `hcl:"list"`
+	}
+	var cfg config
+	if err := UnmarshalWithOptions(data, &cfg, UnmarshalOptions{NumberMode: utils.NumberJSON}); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Small: json.Number("1"),
+		Large: json.Number("5000000000"),
+		Ratio: json.Number("1.5"),
+		List:  []any{json.Number("2"), json.Number("3")},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %#v", cfg)
+	}
+}
+
+func TestUnmarshalNumberModeNested(t *testing.T) {
+	type inner struct {
+		Value any `hcl:"value"`
+	}
+	type config struct {
+		Rows   []map[string]any          `hcl:"rows"`
+		Lists  map[string][]any          `hcl:"lists"`
+		Nested map[string]map[string]any `hcl:"nested"`
+		Items  map[string]*inner         `hcl:"items,block"`
+	}
+	data := []byte(`
+rows   = [{ n = 1 }, { n = 2 }]
+lists  = { a = [3, "x"] }
+nested = { a = { b = 4, c = [5] } }
+items "one" {
+  value = 6
+}`)
+
+	var cfg config
+	if err := UnmarshalWithOptions(data, &cfg, UnmarshalOptions{NumberMode: utils.NumberInt64}); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Rows:   []map[string]any{{"n": int64(1)}, {"n": int64(2)}},
+		Lists:  map[string][]any{"a": {int64(3), "x"}},
+		Nested: map[string]map[string]any{"a": {"b": int64(4), "c": []any{int64(5)}}},
+		Items:  map[string]*inner{"one": {Value: int64(6)}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %#v", cfg)
+	}
+}
+
+func TestMarshalShowOmitted(t *testing.T) {
+	type listener struct {
+		Port int    `hcl:"port"`
+		Host string `hcl:"host,optional"`
+	}
+	type config struct {
+		Name     string    `hcl:"name"`
+		Debug    bool      `hcl:"debug,optional"`
+		Timeout  int       `hcl:"timeout,optional"`
+		Listener *listener `hcl:"listener,block"`
+	}
+	cfg := &config{Name: "api", Listener: &listener{Port: 80}}
+
+	bs, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "#") {
+		t.Errorf("unexpected comment:\n%s", bs)
+	}
+
+	bs, err = MarshalWithOptions(cfg, MarshalOptions{ShowOmitted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`# debug = false  (optional, omitted)`,
+		`# timeout = 0  (optional, omitted)`,
+		`# host = ""  (optional, omitted)`,
+	} {
+		if !strings.Contains(string(bs), line) {
+			t.Errorf("expected %s in:\n%s", line, bs)
+		}
+	}
+
+	var back config
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&back, cfg) {
+		t.Errorf("got %#v", back)
+	}
+}
+
+func TestUnmarshalLabelCaseFold(t *testing.T) {
+	type env struct {
+		Name    string `hcl:"name,label"`
+		Replica int    `hcl:"replica"`
+	}
+	type config struct {
+		Envs map[string]*env `hcl:"env,block"`
+	}
+	data := []byte(`
+env "Prod" {
+  replica = 3
+}
+env "dev" {
+  replica = 1
+}`)
+
+	var cfg config
+	if err := UnmarshalWithOptions(data, &cfg, UnmarshalOptions{LabelCaseFold: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]*env{
+		"prod": {Name: "Prod", Replica: 3},
+		"dev":  {Name: "dev", Replica: 1},
+	}
+	if !reflect.DeepEqual(cfg.Envs, expected) {
+		t.Errorf("got %#v", cfg.Envs)
+	}
+
+	// without the option the label is the key as written
+	cfg = config{}
+	if err := Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Envs["Prod"]; !ok {
+		t.Errorf("got %#v", cfg.Envs)
+	}
+
+	data = append(data, "\nenv \"PROD\" {\n  replica = 5\n}"...)
+	cfg = config{}
+	err := UnmarshalWithOptions(data, &cfg, UnmarshalOptions{LabelCaseFold: true})
+	if err == nil || !strings.Contains(err.Error(), `"Prod" and "PROD"`) {
+		t.Errorf("expected case collision error, got %v", err)
+	}
+}
+
+type indentedRaw struct{}
+
+func (indentedRaw) MarshalHCL() ([]byte, error) {
+	return []byte("a = 1\nb = 2"), nil
+}
+
+func TestMarshalIndentString(t *testing.T) {
+	type sub struct {
+		Host   string            `hcl:"host"`
+		Labels map[string]string `hcl:"labels"`
+	}
+	type config struct {
+		Name string          `hcl:"name"`
+		Sub  *sub            `hcl:"sub,block"`
+		Item map[string]*sub `hcl:"item,block"`
+		Raw  indentedRaw     `hcl:"raw,block"`
+	}
+	cfg := &config{
+		Name: "a",
+		Sub:  &sub{Host: "h", Labels: map[string]string{"k": "v"}},
+		Item: map[string]*sub{"i": {Host: "j"}},
+	}
+
+	bs, err := MarshalWithOptions(cfg, MarshalOptions{IndentString: "\t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\tname = \"a\"\n" +
+		"\tsub {\n" +
+		"\t\thost = \"h\"\n" +
+		"\t\tlabels = {\n" +
+		"\t\t\tk = \"v\"\n" +
+		"\t\t}\n" +
+		"\t}\n" +
+		"\titem \"i\" {\n" +
+		"\t\thost = \"j\"\n" +
+		"\t}\n" +
+		"\traw {"
+	if !strings.HasPrefix(string(bs), expected) {
+		t.Errorf("got\n%q\nwant\n%q", bs, expected)
+	}
+	// the output of a Marshaler is indented the same way
+	if !strings.HasSuffix(string(bs), "\n\ta = 1\n\tb = 2\n\t}") {
+		t.Errorf("custom marshaler: %q", bs)
+	}
+
+	// two spaces, given or not, is the layout of Marshal
+	plain, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spaces, err := MarshalWithOptions(cfg, MarshalOptions{IndentString: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != string(spaces) || string(plain) != strings.ReplaceAll(string(bs), "\t", "  ") {
+		t.Errorf("got\n%s\nand\n%s", plain, spaces)
+	}
+}
+
+func TestUnmarshalContinueOnError(t *testing.T) {
+	type service struct {
+		Port int `hcl:"port"`
+	}
+	type config struct {
+		Name     string              `hcl:"name"`
+		Services map[string]*service `hcl:"service,block"`
+		Backends []*service          `hcl:"backend,block,optional"`
+	}
+	data := []byte(`
+name = "app"
+service "api" {
+  port = 8080
+}
+service "web" {
+  port = "eighty"
+}
+backend {
+  port = "x"
+}
+backend {
+  port = 9000
+}
+`)
+
+	// by default decoding stops at the first failing block
+	var cfg config
+	err := Unmarshal(data, &cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	cfg = config{}
+	err = UnmarshalWithOptions(data, &cfg, UnmarshalOptions{ContinueOnError: true})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"service.web.port: ", "backend.port: "} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in %v", want, err)
+		}
+	}
+	if cfg.Name != "app" || len(cfg.Services) != 1 || cfg.Services["api"].Port != 8080 {
+		t.Errorf("services: %#v", cfg.Services)
+	}
+	if len(cfg.Backends) != 1 || cfg.Backends[0].Port != 9000 {
+		t.Errorf("backends: %#v", cfg.Backends)
+	}
+}
+
+func TestUnmarshalRootBlock(t *testing.T) {
+	type service struct {
+		Kind string `hcl:"kind,label"`
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+
+	data := []byte(`service "http" "api" {
+  port = 8080
+}
+`)
+	s := new(service)
+	if err := UnmarshalWithOptions(data, s, UnmarshalOptions{RootBlock: "service"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Kind != "http" || s.Name != "api" || s.Port != 8080 {
+		t.Errorf("got %#v", s)
+	}
+
+	for _, bad := range []string{
+		`port = 8080`,
+		`server "api" { port = 8080 }`,
+		"service \"a\" {\n  port = 1\n}\nservice \"b\" {\n  port = 2\n}",
+		"service \"a\" {\n  port = 1\n}\nport = 2",
+	} {
+		if err := UnmarshalWithOptions([]byte(bad), new(service), UnmarshalOptions{RootBlock: "service"}); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestUnmarshalInternStrings(t *testing.T) {
+	type rule struct {
+		Action string `hcl:"action,label"`
+		Proto  string `hcl:"proto,label"`
+		Port   int    `hcl:"port"`
+	}
+	type firewall struct {
+		Rules  []*rule             `hcl:"rule,block"`
+		Routes map[[2]string]*rule `hcl:"route,block"`
+		Zones  map[string]*rule    `hcl:"zone,block"`
+		Extra  map[string]any      `hcl:"extra,block"`
+	}
+	data := []byte(`rule "allow" "tcp" {
+  port = 80
+}
+rule "allow" "tcp" {
+  port = 443
+}
+route "allow" "tcp" {
+  port = 1
+}
+zone "allow" {
+  port = 2
+}
+extra {
+  allow = 1
+}
+`)
+
+	same := func(a, b string) bool { return unsafe.StringData(a) == unsafe.StringData(b) }
+
+	fw := new(firewall)
+	if err := UnmarshalWithOptions(data, fw, UnmarshalOptions{InternStrings: true}); err != nil {
+		t.Fatal(err)
+	}
+	allow := fw.Rules[0].Action
+	if allow != "allow" || !same(allow, fw.Rules[1].Action) || !same(fw.Rules[0].Proto, fw.Rules[1].Proto) {
+		t.Errorf("labels not shared: %#v %#v", fw.Rules[0], fw.Rules[1])
+	}
+	for key := range fw.Routes {
+		if !same(key[0], allow) {
+			t.Errorf("map2 key not shared")
+		}
+	}
+	for key := range fw.Zones {
+		if !same(key, allow) {
+			t.Errorf("map key not shared")
+		}
+	}
+	for key := range fw.Extra {
+		if !same(key, allow) {
+			t.Errorf("generic key not shared")
+		}
+	}
+
+	fw = new(firewall)
+	if err := UnmarshalWithOptions(data, fw, UnmarshalOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if same(fw.Rules[0].Action, fw.Rules[1].Action) {
+		t.Errorf("labels shared without InternStrings")
+	}
+}
+
+func TestMarshalInlinePrimitiveSlices(t *testing.T) {
+	doc := map[string]any{
+		"tags":  []any{"a", "b", "c"},
+		"ports": []any{80, 443, nil, true},
+		"long":  []any{strings.Repeat("x", 40), strings.Repeat("y", 40)},
+		"nodes": []any{map[string]any{"id": 1}},
+	}
+	bs, err := MarshalWithOptions(doc, MarshalOptions{InlinePrimitiveSlices: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`tags = ["a", "b", "c"]`, `ports = [80, 443, null, true]`, "long = [\n", "nodes = [\n"} {
+		if !strings.Contains(string(bs), want) {
+			t.Errorf("missing %q in\n%s", want, bs)
+		}
+	}
+
+	var back map[string]any
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back["tags"], []any{"a", "b", "c"}) || !reflect.DeepEqual(back["ports"], []any{80, 443, nil, true}) {
+		t.Errorf("round trip: %#v", back)
+	}
+
+	bs, err = MarshalWithOptions(doc, MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), `["a", "b", "c"]`) {
+		t.Errorf("inline by default:\n%s", bs)
+	}
+}
+
+type sortedService struct {
+	Port int `hcl:"port"`
+}
+
+type sortedConfig struct {
+	Services map[string]*sortedService    `hcl:"service,block"`
+	Routes   map[[2]string]*sortedService `hcl:"route,block"`
+}
+
+func TestMarshalSortMapKeys(t *testing.T) {
+	cfg := &sortedConfig{
+		Services: make(map[string]*sortedService),
+		Routes:   make(map[[2]string]*sortedService),
+	}
+	for i, name := range []string{"web", "api", "worker", "db", "cache", "auth", "mail", "queue"} {
+		cfg.Services[name] = &sortedService{Port: 8000 + i}
+		cfg.Routes[[2]string{name, "v2"}] = &sortedService{Port: 9000 + i}
+		cfg.Routes[[2]string{name, "v1"}] = &sortedService{Port: 9100 + i}
+	}
+
+	first, err := MarshalWithOptions(cfg, MarshalOptions{SortMapKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		bs, err := MarshalWithOptions(cfg, MarshalOptions{SortMapKeys: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(bs, first) {
+			t.Fatalf("output differs:\n%s\nand\n%s", first, bs)
+		}
+	}
+
+	out := string(first)
+	last := -1
+	for _, want := range []string{`service "api"`, `service "auth"`, `service "cache"`, `service "db"`, `service "mail"`, `service "queue"`, `service "web"`, `service "worker"`, `route "api" "v1"`, `route "api" "v2"`, `route "auth" "v1"`, `route "worker" "v2"`} {
+		i := strings.Index(out, want)
+		if i <= last {
+			t.Fatalf("%s out of order in\n%s", want, out)
+		}
+		last = i
+	}
+
+	var back sortedConfig
+	if err := Unmarshal(first, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&back, cfg) {
+		t.Errorf("round trip: %#v", back)
+	}
+}
+
+type jsonTagged struct {
+	ServiceName string            `json:"service_name"`
+	Port        int               `json:"port,omitempty"`
+	Replicas    int               `json:"replicas,omitempty" hcl:"count"`
+	Secret      string            `json:"-"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Backend     *sortedService    `json:"backend"`
+	Plain       bool
+}
+
+func TestMarshalUseJSONTagFallback(t *testing.T) {
+	v := &jsonTagged{
+		ServiceName: "api",
+		Replicas:    2,
+		Secret:      "hunter2",
+		Backend:     &sortedService{Port: 8080},
+		Plain:       true,
+	}
+
+	bs, err := MarshalWithOptions(v, MarshalOptions{UseJSONTagFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(strings.Fields(string(bs)), " ")
+	for _, want := range []string{`service_name = "api"`, `count = 2`, `plain = true`, `backend {`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"secret", "hunter2", "labels", "servicename", "replicas"} {
+		if strings.Contains(out, unwanted+" ") {
+			t.Errorf("unexpected %q in\n%s", unwanted, out)
+		}
+	}
+
+	// off by default
+	bs, err = Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := string(bs); !strings.Contains(out, `servicename = "api"`) || !strings.Contains(out, "hunter2") {
+		t.Errorf("got\n%s", out)
+	}
+}
+
+func TestUnmarshalUseJSONTagFallback(t *testing.T) {
+	type pool struct {
+		MaxConns int    `json:"max_conns"`
+		Name     string `json:"name,omitempty"`
+		Secret   string `json:"-"`
+		Idle     *int   `json:"idle_conns"`
+		Plain    bool
+	}
+	idle := 2
+	v := &pool{MaxConns: 5, Name: "db", Secret: "hunter2", Idle: &idle, Plain: true}
+	bs, err := MarshalWithOptions(v, MarshalOptions{UseJSONTagFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := new(pool)
+	if err := UnmarshalWithOptions(bs, back, UnmarshalOptions{UseJSONTagFallback: true}); err != nil {
+		t.Fatalf("%v\n%s", err, bs)
+	}
+	v.Secret = ""
+	if !reflect.DeepEqual(back, v) {
+		t.Errorf("round trip got %#v from\n%s", back, bs)
+	}
+
+	// a field left out of JSON is not decoded, and null clears a pointer
+	back = &pool{Idle: &idle}
+	data := "max_conns = 1\nidle_conns = null\nsecret = \"x\"\n"
+	if err := UnmarshalWithOptions([]byte(data), back, UnmarshalOptions{UseJSONTagFallback: true}); err != nil {
+		t.Fatal(err)
+	}
+	if back.MaxConns != 1 || back.Idle != nil || back.Secret != "" {
+		t.Errorf("got %#v", back)
+	}
+
+	// off by default, the lowercased field names are read
+	back = new(pool)
+	if err := Unmarshal([]byte("maxconns = 3\n"), back); err != nil || back.MaxConns != 3 {
+		t.Errorf("got %#v, %v", back, err)
+	}
+}
+
+type layered struct {
+	Name   string  `hcl:"name"`
+	Cache  *square `hcl:"cache,block"`
+	Shape  inter   `hcl:"shape,block"`
+	Limit  *int    `hcl:"limit,optional"`
+	Hidden *int    `hcl:"hidden,omitempty"`
+}
+
+func TestMarshalExplicitNulls(t *testing.T) {
+	bs, err := MarshalWithOptions(&layered{Name: "base"}, MarshalOptions{ExplicitNulls: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := strings.Join(strings.Fields(string(bs)), " ")
+	for _, want := range []string{"cache = null", "shape = null", "limit = null"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, bs)
+		}
+	}
+	if strings.Contains(out, "hidden") {
+		t.Errorf("omitempty field written in\n%s", bs)
+	}
+
+	// cleared fields are reset, absent ones keep their value
+	limit := 5
+	got := &layered{Cache: &square{SX: 1}, Shape: &circle{Radius: 1}, Limit: &limit, Hidden: &limit}
+	ref := map[string]any{"circle": new(circle)}
+	if err := UnmarshalSpec(bs, got, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "base" || got.Cache != nil || got.Shape != nil || got.Limit != nil || got.Hidden != &limit {
+		t.Errorf("got %#v", got)
+	}
+
+	// off by default
+	bs, err = Marshal(&layered{Name: "base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "null") {
+		t.Errorf("got\n%s", bs)
+	}
+}
+
+type nested struct {
+	Level int     `hcl:"level,optional"`
+	Child *nested `hcl:"child,block"`
+}
+
+func TestUnmarshalMaxDepth(t *testing.T) {
+	deep := func(n int) []byte {
+		var b strings.Builder
+		for i := 0; i < n; i++ {
+			b.WriteString("child {\n")
+		}
+		b.WriteString("level = 1\n")
+		b.WriteString(strings.Repeat("}\n", n))
+		return []byte(b.String())
+	}
+	data := deep(2000)
+
+	m := make(map[string]any)
+	err := Unmarshal(data, &m)
+	if err == nil || !strings.Contains(err.Error(), "maximum nesting depth 1000 exceeded") {
+		t.Errorf("map: got %v", err)
+	}
+	if err := UnmarshalWithOptions(data, &m, UnmarshalOptions{MaxDepth: 5000}); err != nil {
+		t.Errorf("map with MaxDepth: %v", err)
+	}
+
+	list := []byte("x = " + strings.Repeat("[", 1500) + strings.Repeat("]", 1500))
+	if err := Unmarshal(list, &m); err == nil || !strings.Contains(err.Error(), "maximum nesting depth") {
+		t.Errorf("list: got %v", err)
+	}
+
+	v := new(nested)
+	err = UnmarshalWithOptions(deep(20), v, UnmarshalOptions{MaxDepth: 10})
+	if err == nil || !strings.Contains(err.Error(), "maximum nesting depth 10 exceeded") {
+		t.Errorf("struct: got %v", err)
+	}
+	if err := UnmarshalWithOptions(deep(5), v, UnmarshalOptions{MaxDepth: 10}); err != nil || v.Child == nil {
+		t.Errorf("got %v, %#v", err, v)
+	}
+}
+
+func TestMarshalMaxDepth(t *testing.T) {
+	chain := func(n int) *nested {
+		v := &nested{Level: n}
+		for i := n - 1; i > 0; i-- {
+			v = &nested{Level: i, Child: v}
+		}
+		return v
+	}
+
+	// a cycle fails instead of recursing forever
+	cyclic := &nested{Level: 1}
+	cyclic.Child = cyclic
+	if _, err := Marshal(cyclic); err == nil || !strings.Contains(err.Error(), "maximum nesting depth 1000 exceeded") {
+		t.Errorf("cycle: got %v", err)
+	}
+
+	if _, err := MarshalWithOptions(chain(20), MarshalOptions{MaxDepth: 10}); err == nil {
+		t.Error("expected an error with MaxDepth 10")
+	}
+	bs, err := MarshalWithOptions(chain(5), MarshalOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := new(nested)
+	if err := Unmarshal(bs, back); err != nil || !reflect.DeepEqual(back, chain(5)) {
+		t.Errorf("got %v, %#v", err, back)
+	}
+}
@@ -0,0 +1,69 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/OpenUdon/schema"
+)
+
+// Registry holds the types that specs name, like the ref map passed to
+// UnmarshalSpec, for registration from init functions across packages. It is
+// safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]any
+}
+
+// DefaultRegistry is the Registry used by UnmarshalDefault.
+var DefaultRegistry = &Registry{}
+
+// Register adds zero, usually a pointer to a zero struct such as &Circle{},
+// under name. Registering the same type again under a name is a no-op;
+// Register panics if name is already taken by a different type, like
+// gob.Register does for conflicting names.
+func (r *Registry) Register(name string, zero any) {
+	if zero == nil {
+		panic(fmt.Sprintf("dethcl: Register: nil value for %q", name))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.types[name]; ok {
+		if reflect.TypeOf(old) != reflect.TypeOf(zero) {
+			panic(fmt.Sprintf("dethcl: Register: %q is registered for %T, not %T", name, old, zero))
+		}
+		return
+	}
+	if r.types == nil {
+		r.types = make(map[string]any)
+	}
+	r.types[name] = zero
+}
+
+// Ref returns a new ref map holding the registered types, which the caller
+// may extend, e.g. with Alias, before passing it to UnmarshalSpec.
+func (r *Registry) Ref() map[string]any {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ref := make(map[string]any, len(r.types))
+	for name, zero := range r.types {
+		ref[name] = zero
+	}
+	return ref
+}
+
+// UnmarshalDefault decodes hclData into current like UnmarshalSpec, looking
+// up the classes of spec in DefaultRegistry.
+//
+// Example:
+//
+//	func init() {
+//	    dethcl.DefaultRegistry.Register("circle", &Circle{})
+//	}
+//
+//	spec, _ := schema.NewStruct("Geo", map[string]any{"Shape": "circle"})
+//	err := dethcl.UnmarshalDefault(hclBytes, &geo, spec)
+func UnmarshalDefault(hclData []byte, current any, spec *schema.Struct, labels ...string) error {
+	return UnmarshalSpec(hclData, current, spec, DefaultRegistry.Ref(), labels...)
+}
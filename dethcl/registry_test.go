@@ -0,0 +1,60 @@
+package dethcl
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/OpenUdon/schema"
+)
+
+func TestRegistry(t *testing.T) {
+	r := &Registry{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Register("circle", new(circle))
+			r.Register("square", new(square))
+			_ = r.Ref()
+		}()
+	}
+	wg.Wait()
+	ref := r.Ref()
+	if len(ref) != 2 || !reflect.DeepEqual(ref["circle"], new(circle)) {
+		t.Errorf("got %#v", ref)
+	}
+
+	// the same name for another type panics
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for a conflicting name")
+			}
+		}()
+		r.Register("circle", new(square))
+	}()
+}
+
+func TestUnmarshalDefault(t *testing.T) {
+	DefaultRegistry.Register("dethcl.registry.circle", new(circle))
+	spec, err := schema.NewStruct("geo", map[string]any{"Shape": "dethcl.registry.circle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := DefaultRegistry.Ref()
+	g := new(geo)
+	if err := UnmarshalDefault([]byte("name = \"drawing\"\nshape {\n  radius = 2\n}\n"), g, spec); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.Shape, &circle{Radius: 2}) {
+		t.Errorf("got %#v", g.Shape)
+	}
+	// decoding neither adds to the registry nor fills its zero value, and
+	// other tests may register types of their own
+	ref := DefaultRegistry.Ref()
+	if !reflect.DeepEqual(ref, before) || !reflect.DeepEqual(ref["dethcl.registry.circle"], new(circle)) {
+		t.Errorf("registry changed: %#v", ref)
+	}
+}
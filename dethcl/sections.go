@@ -0,0 +1,170 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/genelet/horizon/utils"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// UnmarshalSections decodes the top-level blocks of an HCL document into
+// separate targets, routing each block by its type. It avoids defining one
+// envelope struct for documents whose sections map to unrelated Go types.
+//
+// Each target is a pointer to:
+//   - a struct: the section must appear once; its labels fill the label fields
+//   - a slice: every block of the type is decoded into a new element
+//   - a map[string]T: every block is decoded into an element keyed by its
+//     single label
+//
+// Top-level attributes are decoded into the target under the empty key "".
+// If the document has top-level attributes but no such target, or a block type
+// has no target, an error is returned.
+//
+// Example:
+//
+//	var server Server
+//	var users map[string]*User
+//	var settings Settings
+//	err := UnmarshalSections(hclBytes, map[string]any{
+//	    "server": &server,   // server { ... }
+//	    "user":   &users,    // user "alice" { ... }
+//	    "":       &settings, // name = "app"
+//	})
+func UnmarshalSections(hclData []byte, targets map[string]any) error {
+	for key, target := range targets {
+		rv := reflect.ValueOf(target)
+		if rv.Kind() != reflect.Pointer || rv.IsNil() {
+			return fmt.Errorf("section %q: non-pointer or nil data", key)
+		}
+	}
+
+	file, hclBody, err := parseHCLFile(hclData)
+	if err != nil {
+		return err
+	}
+
+	// one registry and variable tree shared by all sections
+	ref := make(map[string]any)
+	keys := make([]string, 0, len(targets))
+	for key := range targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for k, v := range collectStructTypesFromObject(targets[key], nil) {
+			ref[k] = v
+		}
+	}
+	node := utils.NewEvalContext(ref)
+	ref = node.GetRef()
+
+	if len(hclBody.Attributes) > 0 {
+		target, ok := targets[""]
+		if !ok {
+			return fmt.Errorf("top-level attributes found but no target for key \"\"")
+		}
+		// keep source order so that later attributes can refer to earlier ones
+		attrs := make([]*hclsyntax.Attribute, 0, len(hclBody.Attributes))
+		for _, attr := range hclBody.Attributes {
+			attrs = append(attrs, attr)
+		}
+		sort.Slice(attrs, func(i, j int) bool {
+			return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte
+		})
+		var bs []byte
+		for _, attr := range attrs {
+			bs = append(bs, file.Bytes[attr.SrcRange.Start.Byte:attr.SrcRange.End.Byte]...)
+			bs = append(bs, '\n')
+		}
+		if err := UnmarshalSpecTree(node, bs, target, nil, ref); err != nil {
+			return fmt.Errorf("top-level attributes: %w", err)
+		}
+	}
+
+	addBlocksToTree(node, hclBody.Blocks)
+
+	seen := make(map[string]int)
+	for _, block := range hclBody.Blocks {
+		target, ok := targets[block.Type]
+		if !ok {
+			return fmt.Errorf("no target for block type %q", block.Type)
+		}
+		bs, labels, err := getBlockBytes(block, file)
+		if err != nil {
+			return err
+		}
+		subnode := node.GetNode(block.Type, labels...)
+		if err := unmarshalSection(subnode, bs, target, ref, seen[block.Type], labels); err != nil {
+			return fmt.Errorf("section %s: %w", block.Type, err)
+		}
+		seen[block.Type]++
+	}
+	return nil
+}
+
+// unmarshalSection decodes one block into target. The count is the number of
+// blocks of the same type already decoded.
+func unmarshalSection(node *utils.Tree, bs []byte, target any, ref map[string]any, count int, labels []string) error {
+	rv := reflect.ValueOf(target).Elem()
+	switch rv.Kind() {
+	case reflect.Slice:
+		if count == 0 {
+			rv.Set(reflect.MakeSlice(rv.Type(), 0, 1))
+		}
+		elem, err := unmarshalSectionElem(node, bs, rv.Type().Elem(), ref, labels)
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.Append(rv, elem))
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map key must be string, got %v", rv.Type().Key().Kind())
+		}
+		if len(labels) != 1 {
+			return fmt.Errorf("map target needs exactly one label, got %d", len(labels))
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		key := reflect.ValueOf(labels[0]).Convert(rv.Type().Key())
+		if rv.MapIndex(key).IsValid() {
+			return fmt.Errorf("duplicate label %q", labels[0])
+		}
+		elem, err := unmarshalSectionElem(node, bs, rv.Type().Elem(), ref, labels)
+		if err != nil {
+			return err
+		}
+		rv.SetMapIndex(key, elem)
+	default:
+		if count > 0 {
+			return fmt.Errorf("block appears more than once; use a slice or map target")
+		}
+		return tryUnmarshalWithCustom(node, bs, target, nil, ref, labels...)
+	}
+	return nil
+}
+
+// unmarshalSectionElem decodes one block into a new value of type typ, which
+// is a struct, a pointer to struct, map[string]any or []any.
+func unmarshalSectionElem(node *utils.Tree, bs []byte, typ reflect.Type, ref map[string]any, labels []string) (reflect.Value, error) {
+	isPointer := typ.Kind() == reflect.Pointer
+	base := typ
+	if isPointer {
+		base = typ.Elem()
+	}
+	if base.Kind() == reflect.Interface {
+		base = reflect.TypeOf(map[string]any{})
+	}
+
+	item := reflect.New(base)
+	if err := tryUnmarshalWithCustom(node, bs, item.Interface(), nil, ref, labels...); err != nil {
+		return reflect.Value{}, err
+	}
+	if isPointer {
+		return item, nil
+	}
+	return item.Elem(), nil
+}
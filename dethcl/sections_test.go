@@ -0,0 +1,77 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalSections(t *testing.T) {
+	type server struct {
+		Host string `hcl:"host"`
+		Port int    `hcl:"port"`
+	}
+	type user struct {
+		Name  string `hcl:"name,label"`
+		Admin bool   `hcl:"admin,optional"`
+	}
+	type settings struct {
+		Name  string `hcl:"name"`
+		Debug bool   `hcl:"debug,optional"`
+	}
+	data := `
+name = "app"
+server {
+  host = "localhost"
+  port = 8080
+}
+user "alice" {
+  admin = true
+}
+user "bob" {
+}
+rule {
+  allow = "all"
+}
+rule {
+  allow = "none"
+}
+`
+	var srv server
+	var users map[string]*user
+	var rules []map[string]any
+	var conf settings
+	err := UnmarshalSections([]byte(data), map[string]any{
+		"server": &srv,
+		"user":   &users,
+		"rule":   &rules,
+		"":       &conf,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.Host != "localhost" || srv.Port != 8080 {
+		t.Errorf("server = %#v", srv)
+	}
+	if len(users) != 2 || !users["alice"].Admin || users["bob"].Admin || users["bob"].Name != "bob" {
+		t.Errorf("users = %#v", users)
+	}
+	if len(rules) != 2 || rules[1]["allow"] != "none" {
+		t.Errorf("rules = %#v", rules)
+	}
+	if conf.Name != "app" {
+		t.Errorf("settings = %#v", conf)
+	}
+
+	err = UnmarshalSections([]byte(data), map[string]any{"server": &srv, "user": &users, "rule": &rules})
+	if err == nil || !strings.Contains(err.Error(), "top-level attributes") {
+		t.Errorf("expected attribute routing error, got %v", err)
+	}
+	err = UnmarshalSections([]byte(data), map[string]any{"user": &users, "rule": &rules, "": &conf})
+	if err == nil || !strings.Contains(err.Error(), `"server"`) {
+		t.Errorf("expected missing target error, got %v", err)
+	}
+	err = UnmarshalSections([]byte(data), map[string]any{"server": &srv, "user": &users, "rule": &srv, "": &conf})
+	if err == nil {
+		t.Error("expected error for repeated block into struct target")
+	}
+}
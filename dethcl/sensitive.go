@@ -0,0 +1,125 @@
+package dethcl
+
+import (
+	"reflect"
+	"sort"
+)
+
+// SensitiveFields returns the paths of the fields tagged sensitive that hold
+// a value in current, usually a struct just decoded, so that callers can keep
+// them out of logs. Paths are dot-joined HCL names from the root with block
+// labels and map keys as elements, as reported by UnmarshalWithPresence, e.g.
+// "database.primary.password". They are sorted and given once each.
+//
+// Example:
+//
+//	type Database struct {
+//	    Name     string `hcl:"name,label"`
+//	    Password string `hcl:"password,sensitive"`
+//	}
+//	// database "primary" { password = "s3cret" } gives database.primary.password
+func SensitiveFields(current any) []string {
+	seen := make(map[string]bool)
+	sensitiveBody(seen, "", reflect.ValueOf(current))
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// sensitiveValue adds to seen the sensitive fields of the block at path held
+// by rv, which may be a pointer or interface to a struct or to a list or map
+// of them.
+func sensitiveValue(seen map[string]bool, path string, rv reflect.Value) {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		if isTimeType(rv.Type()) {
+			return
+		}
+		sensitiveStruct(seen, path, rv)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			sensitiveValue(seen, path, rv.Index(i))
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			sub := path
+			switch key := iter.Key(); {
+			case key.Kind() == reflect.String:
+				sub = joinPath(sub, key.String())
+			case key.Kind() == reflect.Array && key.Len() == 2 && key.Type().Elem().Kind() == reflect.String:
+				sub = joinPath(joinPath(sub, key.Index(0).String()), key.Index(1).String())
+			}
+			sensitiveBody(seen, sub, iter.Value())
+		}
+	default:
+	}
+}
+
+// sensitiveBody is sensitiveValue for a struct whose labels are not part of
+// path: the root, labeled by the caller, or a map entry, labeled by its key.
+func sensitiveBody(seen map[string]bool, path string, rv reflect.Value) {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct && !isTimeType(rv.Type()) {
+		sensitiveFields(seen, path, rv)
+		return
+	}
+	sensitiveValue(seen, path, rv)
+}
+
+// sensitiveStruct adds the labels of the struct rv to path and walks its
+// fields.
+func sensitiveStruct(seen map[string]bool, path string, rv reflect.Value) {
+	fields, err := getStructFields(rv.Type(), rv)
+	if err != nil {
+		return
+	}
+	for _, info := range fields {
+		if info.IsLabel && info.Value.Kind() == reflect.String && info.Value.String() != "" {
+			path = joinPath(path, info.Value.String())
+		}
+	}
+	sensitiveFields(seen, path, rv)
+}
+
+// sensitiveFields walks the fields of the struct rv in the block at path.
+func sensitiveFields(seen map[string]bool, path string, rv reflect.Value) {
+	fields, err := getStructFields(rv.Type(), rv)
+	if err != nil {
+		return
+	}
+	for _, info := range fields {
+		if info.IsLabel {
+			continue
+		}
+		sub := joinPath(path, info.TagName)
+		if info.IsSensitive {
+			if !info.Value.IsZero() {
+				seen[sub] = true
+			}
+			continue
+		}
+		sensitiveValue(seen, sub, info.Value)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,148 @@
+package dethcl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type credentials struct {
+	User     string `hcl:"user"`
+	Password string `hcl:"password,sensitive"`
+}
+
+type database struct {
+	Name  string       `hcl:"name,label"`
+	Host  string       `hcl:"host"`
+	Token string       `hcl:"token,optional,sensitive"`
+	Login *credentials `hcl:"login,block"`
+}
+
+type secretConfig struct {
+	APIKey    string               `hcl:"api_key,sensitive"`
+	Port      int                  `hcl:"port,optional,sensitive"`
+	Databases []*database          `hcl:"database,block"`
+	Backups   map[string]*database `hcl:"backup,block"`
+}
+
+func TestSensitiveFields(t *testing.T) {
+	data := `api_key = "k-123"
+database "primary" {
+  host  = "db1"
+  token = "t-1"
+  login {
+    user     = "admin"
+    password = "s3cret"
+  }
+}
+database "replica" {
+  host = "db2"
+}
+backup "nightly" {
+  host = "db3"
+  token = "t-3"
+}
+`
+	cfg := new(secretConfig)
+	if err := Unmarshal([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "k-123" || cfg.Databases[0].Login.Password != "s3cret" {
+		t.Fatalf("got %#v", cfg)
+	}
+	expected := []string{
+		"api_key",
+		"backup.nightly.token",
+		"database.primary.login.password",
+		"database.primary.token",
+	}
+	if got := SensitiveFields(cfg); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v, want %v", got, expected)
+	}
+}
+
+func TestMarshalRedactSensitive(t *testing.T) {
+	cfg := &secretConfig{
+		APIKey: "k-123",
+		Port:   8443,
+		Databases: []*database{{
+			Name:  "primary",
+			Host:  "db1",
+			Login: &credentials{User: "admin", Password: "s3cret"},
+		}},
+	}
+	bs, err := MarshalWithOptions(cfg, MarshalOptions{RedactSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(bs)
+	for _, secret := range []string{"k-123", "8443", "s3cret"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("%q not redacted in\n%s", secret, got)
+		}
+	}
+	for _, line := range []string{`api_key = "(sensitive)"`, `password = "(sensitive)"`, `user     = "admin"`, `host = "db1"`} {
+		if !strings.Contains(got, line) {
+			t.Errorf("missing %q in\n%s", line, got)
+		}
+	}
+
+	// without the option, sensitive fields are written as usual
+	bs, err = Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), `password = "s3cret"`) {
+		t.Errorf("got\n%s", bs)
+	}
+}
+
+func TestMarshalRedactSensitiveOutliers(t *testing.T) {
+	password := "s3cret"
+	type config struct {
+		User     string            `hcl:"user"`
+		Password *string           `hcl:"password,optional,sensitive"`
+		Unset    *string           `hcl:"unset,optional,sensitive"`
+		Login    *credentials      `hcl:"login,block,sensitive"`
+		Keys     []string          `hcl:"keys,optional,sensitive"`
+		Env      map[string]string `hcl:"env,optional,sensitive"`
+		Extra    any               `hcl:"extra,optional,sensitive"`
+	}
+	cfg := &config{
+		User:     "admin",
+		Password: &password,
+		Login:    &credentials{User: "root", Password: "hunter2"},
+		Keys:     []string{"k-1"},
+		Env:      map[string]string{"TOKEN": "t-1"},
+		Extra:    "x-1",
+	}
+	bs, err := MarshalWithOptions(cfg, MarshalOptions{RedactSensitive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(bs)
+	for _, secret := range []string{"s3cret", "root", "hunter2", "k-1", "t-1", "x-1", "unset"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("%q not redacted in\n%s", secret, got)
+		}
+	}
+	expected := `  user = "admin"
+  keys = "(sensitive)"
+  env  = "(sensitive)"
+  password = "(sensitive)"
+  login = "(sensitive)"
+  extra = "(sensitive)"`
+	if got != expected {
+		t.Errorf("got\n%s\nwant\n%s", got, expected)
+	}
+
+	bs, err = Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"s3cret", "hunter2", "k-1", "t-1", "x-1"} {
+		if !strings.Contains(string(bs), secret) {
+			t.Errorf("%q missing without the option in\n%s", secret, bs)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package dethcl
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/genelet/horizon/utils"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// decodeState collects information about one Unmarshal call across all the
+// nested UnmarshalSpecTree calls. It travels in the ref map under
+// contextKeyDecodeState, so existing signatures stay unchanged.
+type decodeState struct {
+	mu        sync.Mutex
+	present   map[string]bool   // field paths set by the HCL data
+	maxLabels int               // label limit for generic map blocks, 0 for none
+	numbers   utils.NumberMode  // Go types of numbers decoded into interfaces
+	foldCase  bool              // lower-case block labels used as map keys
+	keepGoing bool              // collect block errors instead of stopping
+	strict    bool              // reject attributes and blocks without field
+	src       []byte            // the whole document, for positions of errors
+	unknown   []error           // attributes and blocks without field
+	strs      map[string]string // interned labels and keys, nil if off
+	maxDepth  int               // nesting limit, 0 for defaultMaxDepth
+	jsonNames bool              // name untagged fields by their json tag
+}
+
+// newDecodeState returns an empty decodeState.
+func newDecodeState() *decodeState {
+	return &decodeState{present: make(map[string]bool)}
+}
+
+// getDecodeState returns the decodeState in ref, or nil if there is none.
+func getDecodeState(ref map[string]any) *decodeState {
+	if ref == nil {
+		return nil
+	}
+	state, _ := ref[contextKeyDecodeState].(*decodeState)
+	return state
+}
+
+// numberMode returns the number mode of s, or utils.NumberAuto if s is nil.
+func (s *decodeState) numberMode() utils.NumberMode {
+	if s == nil {
+		return utils.NumberAuto
+	}
+	return s.numbers
+}
+
+// fieldName returns the HCL name of field as marshal gives it: the name of
+// its hcl tag, else its json tag name if s names fields that way, as with
+// UnmarshalOptions.UseJSONTagFallback, else the lowercased field name. It
+// returns false for a field without hcl tag name left out of JSON by
+// json:"-". It is safe to call on a nil s.
+func (s *decodeState) fieldName(field reflect.StructField) (string, bool) {
+	name, _ := HCLName(field)
+	if s == nil || !s.jsonNames || parseHCLTag(field.Tag)[0] != "" {
+		return name, true
+	}
+	jsonName, _, ok := jsonTagName(field)
+	if jsonName != "" {
+		name = jsonName
+	}
+	return name, ok
+}
+
+// jsonName returns the json tag name of field if s names untagged fields by
+// it, or "". It is safe to call on a nil s.
+func (s *decodeState) jsonName(field reflect.StructField) string {
+	if s == nil || !s.jsonNames {
+		return ""
+	}
+	name, _, _ := jsonTagName(field)
+	return name
+}
+
+// checkDepth returns an error if node lies deeper than the nesting limit of
+// s, or defaultMaxDepth if s is nil or sets none, so that a pathological
+// document fails instead of exhausting the stack.
+func (s *decodeState) checkDepth(node *utils.Tree) error {
+	limit := defaultMaxDepth
+	if s != nil && s.maxDepth > 0 {
+		limit = s.maxDepth
+	}
+	if node != nil && node.Depth() > limit {
+		return fmt.Errorf("maximum nesting depth %d exceeded", limit)
+	}
+	return nil
+}
+
+// labelKey returns the map key for a block label. If s folds case, the key
+// is the label in lower case, and seen, which maps the keys of one field to
+// their original labels, is used to reject labels that differ only in case,
+// e.g. "Prod" and "prod", instead of overwriting one entry with the other.
+func (s *decodeState) labelKey(label string, seen map[string]string) (string, error) {
+	if s == nil || !s.foldCase {
+		return s.intern(label), nil
+	}
+	key := strings.ToLower(label)
+	if original, ok := seen[key]; ok && original != label {
+		return "", fmt.Errorf("labels %q and %q both fold to key %q", original, label, key)
+	}
+	seen[key] = label
+	return s.intern(key), nil
+}
+
+// intern returns the first string equal to str seen by s, so that repeated
+// labels and keys share one copy. It returns str itself if s does not intern.
+func (s *decodeState) intern(str string) string {
+	if s == nil || s.strs == nil {
+		return str
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.strs[str]; ok {
+		return old
+	}
+	s.strs[str] = str
+	return str
+}
+
+// blockError handles err, the failure of one block or field. If s keeps
+// going, err is added to errs and nil is returned so that decoding goes on;
+// otherwise err is returned to stop.
+func (s *decodeState) blockError(errs *[]error, err error) error {
+	if s == nil || !s.keepGoing {
+		return err
+	}
+	*errs = append(*errs, err)
+	return nil
+}
+
+// recordPresence marks the attributes and blocks of body as present. Keys are
+// the dot-joined HCL names from the root, with block labels as path elements,
+// e.g. "service.api.port" for port inside service "api" { ... }.
+func (s *decodeState) recordPresence(node *utils.Tree, body *hclsyntax.Body) {
+	prefix := strings.Join(node.Path(), ".")
+	if prefix != "" {
+		prefix += "."
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range body.Attributes {
+		s.present[prefix+name] = true
+	}
+	for _, block := range body.Blocks {
+		path := prefix + block.Type
+		s.present[path] = true
+		for _, label := range block.Labels {
+			path += "." + label
+			s.present[path] = true
+		}
+	}
+}
+
+// position returns the line and column in the whole document of the start
+// of rng, a range in hclData. Nested blocks are decoded from slices of the
+// document, so their offset is found by comparing the backing arrays;
+// otherwise rng is given as is.
+func (s *decodeState) position(hclData []byte, rng hcl.Range) string {
+	if s != nil && len(hclData) > 0 {
+		off := cap(s.src) - cap(hclData)
+		if off >= 0 && off+len(hclData) <= len(s.src) && &s.src[off] == &hclData[0] {
+			at := off + rng.Start.Byte
+			line := 1 + bytes.Count(s.src[:at], []byte("\n"))
+			column := at - bytes.LastIndexByte(s.src[:at], '\n')
+			return fmt.Sprintf("line %d, column %d", line, column)
+		}
+	}
+	return fmt.Sprintf("line %d, column %d", rng.Start.Line, rng.Start.Column)
+}
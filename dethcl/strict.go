@@ -0,0 +1,102 @@
+package dethcl
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// UnmarshalStrict decodes HCL data like Unmarshal, but fails if the data
+// has attributes or blocks that match no field of the target struct, or of
+// the structs of its blocks, e.g. a misspelled prot = 8080. The error lists
+// each of them with its line and column in hclData.
+//
+// Object attributes giving the same key twice, e.g. env = { a = 1, a = 2 },
+// fail too, as with UnmarshalOptions.RejectDuplicateKeys.
+//
+// Fields of embedded structs are matched like fields of the struct itself.
+// A field tagged "-" is never decoded, but an attribute named after it, in
+// lower case, is not reported either. Types implementing Unmarshaler decode
+// themselves and are not checked.
+func UnmarshalStrict(hclData []byte, current any, labels ...string) error {
+	if current == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(current)
+	if rv.Kind() != reflect.Pointer {
+		return fmt.Errorf("non-pointer or nil data")
+	}
+	if rv.IsNil() {
+		return nil
+	}
+	if ok, err := unmarshalCustom(nil, hclData, current, labels...); ok {
+		return err
+	}
+	if err := checkDuplicateKeys(hclData); err != nil {
+		return err
+	}
+	state := newDecodeState()
+	state.strict = true
+	state.src = hclData
+	err := unmarshalSpec(hclData, current, nil, nil, state, labels...)
+	return errors.Join(append([]error{err}, state.unknown...)...)
+}
+
+// recordUnknown adds an error to s for each attribute and block of body,
+// parsed from hclData, that matches no field of structType.
+func (s *decodeState) recordUnknown(hclData []byte, structType reflect.Type, body *hclsyntax.Body) {
+	known := make(map[string]bool)
+	fieldNames(structType, known)
+
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte })
+
+	var errs []error
+	for _, attr := range attrs {
+		if !known[attr.Name] {
+			errs = append(errs, fmt.Errorf("unknown attribute %q at %s", attr.Name, s.position(hclData, attr.NameRange)))
+		}
+	}
+	for _, block := range body.Blocks {
+		if !known[block.Type] {
+			errs = append(errs, fmt.Errorf("unknown block %q at %s", block.Type, s.position(hclData, block.TypeRange)))
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unknown = append(s.unknown, errs...)
+}
+
+// fieldNames adds the HCL names of the fields of structType to known,
+// including those of embedded structs. Label fields have no name in the body.
+func fieldNames(structType reflect.Type, known map[string]bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, modifier := HCLName(field)
+		switch {
+		case name == tagIgnore:
+			known[strings.ToLower(field.Name)] = true
+		case field.Anonymous && parseHCLTag(field.Tag)[0] == "":
+			typ := field.Type
+			if typ.Kind() == reflect.Pointer {
+				typ = typ.Elem()
+			}
+			if typ.Kind() == reflect.Struct {
+				fieldNames(typ, known)
+			}
+		case strings.ToLower(modifier) == tagModifierLabel, isCommentField(field):
+		default:
+			known[name] = true
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+)
+
+type StrictBase struct {
+	ID string `hcl:"id"`
+}
+
+type strictListener struct {
+	Name string `hcl:"name,label"`
+	Port int    `hcl:"port"`
+}
+
+type strictConfig struct {
+	StrictBase
+	Secret    string            `hcl:"-"`
+	Listeners []*strictListener `hcl:"listener,block"`
+	Labels    map[string]string `hcl:"labels,optional"`
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	data := `id = "a1"
+secret = "ignored"
+labels = { env = "dev" }
+listener "http" {
+  port = 80
+}
+`
+	cfg := new(strictConfig)
+	if err := UnmarshalStrict([]byte(data), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ID != "a1" || cfg.Secret != "" || cfg.Listeners[0].Port != 80 || cfg.Labels["env"] != "dev" {
+		t.Errorf("got %#v", cfg)
+	}
+	// Unmarshal stays lenient
+	if err := Unmarshal([]byte(data+"extra = 1\n"), new(strictConfig)); err != nil {
+		t.Fatal(err)
+	}
+
+	data = `id = "a1"
+name = "x"
+listener "http" {
+  port = 80
+  prot = 8080
+}
+logging {
+  level = "info"
+}
+`
+	err := UnmarshalStrict([]byte(data), new(strictConfig))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		`unknown attribute "name" at line 2, column 1`,
+		`unknown attribute "prot" at line 5, column 3`,
+		`unknown block "logging" at line 7, column 1`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in %v", want, err)
+		}
+	}
+}
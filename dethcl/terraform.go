@@ -0,0 +1,95 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// terraformBlockLabels gives the number of labels of the top-level block
+// types of Terraform, e.g. resource "aws_instance" "web" { ... }.
+var terraformBlockLabels = map[string]int{
+	"resource":  2,
+	"data":      2,
+	"provider":  1,
+	"variable":  1,
+	"output":    1,
+	"module":    1,
+	"check":     1,
+	"terraform": 0,
+	"locals":    0,
+	"moved":     0,
+	"import":    0,
+}
+
+// terraform reports whether o selects the Terraform conventions. It is safe
+// to call on a nil o.
+func (o *MarshalOptions) terraform() bool {
+	return o != nil && o.Terraform
+}
+
+// mapAsAttribute reports whether a map of the field is written as an object
+// attribute, tags = { ... }, rather than as a block. So is a field tagged
+// collapse; in Terraform mode only fields tagged block give blocks.
+func (o *MarshalOptions) mapAsAttribute(field reflect.StructField) bool {
+	modifier := parseHCLTag(field.Tag)[1]
+	if hasTagOption(modifier, tagModifierCollapse) {
+		return true
+	}
+	return o.terraform() && !hasTagOption(modifier, tagModifierBlock)
+}
+
+// terraformHash writes the generic map m found under header. At the top of
+// the document, a known block type such as resource takes its labels from
+// the map keys, one level per label; any other map is an object attribute.
+func terraformHash(opts *MarshalOptions, lines *[]string, header string, m map[string]any, depth, level int) error {
+	if n, ok := terraformBlockLabels[header]; ok && depth == 0 && level == 0 {
+		return terraformBlock(opts, lines, header, m, n, level)
+	}
+	if len(m) == 0 {
+		*lines = append(*lines, header+" = {}")
+		return nil
+	}
+	bs, err := marshalLevel(opts, m, false, level+1, header)
+	if err != nil {
+		return err
+	}
+	*lines = append(*lines, header+" = "+string(bs))
+	return nil
+}
+
+// terraformBlock writes m as blocks of header with the given number of
+// labels still to take from the map keys.
+func terraformBlock(opts *MarshalOptions, lines *[]string, header string, m map[string]any, labels, level int) error {
+	if labels == 0 {
+		bs, err := marshalLevel(opts, m, false, level+1, header)
+		if err != nil {
+			return err
+		}
+		if isBlank(bs) || len(m) == 0 {
+			bs = []byte("{\n" + opts.indent(level) + "}")
+		}
+		*lines = append(*lines, header+" "+string(bs))
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			return fmt.Errorf("block %s %q: expected a map for the next label, got %T", header, key, m[key])
+		}
+		label := string(hclwrite.TokensForValue(cty.StringVal(key)).Bytes())
+		if err := terraformBlock(opts, lines, header+" "+label, next, labels-1, level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
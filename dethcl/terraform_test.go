@@ -0,0 +1,286 @@
+package dethcl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+type tfIngress struct {
+	FromPort int      `hcl:"from_port"`
+	ToPort   int      `hcl:"to_port"`
+	Cidr     []string `hcl:"cidr_blocks"`
+}
+
+type tfSecurityGroup struct {
+	Type    string            `hcl:"type,label"`
+	Name    string            `hcl:"name,label"`
+	Desc    string            `hcl:"description"`
+	Tags    map[string]string `hcl:"tags,optional"`
+	Labels  map[string]any    `hcl:"labels,optional"`
+	Ingress []*tfIngress      `hcl:"ingress,block"`
+}
+
+type tfConfig struct {
+	Providers map[string]map[string]any `hcl:"provider,block"`
+	Resources []*tfSecurityGroup        `hcl:"resource,block"`
+	Locals    map[string]any            `hcl:"locals,block"`
+}
+
+// terraformFormatted checks that bs is valid HCL that terraform fmt would
+// leave as it is, and returns it.
+func terraformFormatted(t *testing.T, bs []byte) string {
+	t.Helper()
+	if _, diags := hclsyntax.ParseConfig(bs, "main.tf", hcl.Pos{Line: 1, Column: 1}); diags.HasErrors() {
+		t.Fatalf("invalid HCL: %v\n%s", diags, bs)
+	}
+	if formatted := hclwrite.Format(bs); string(formatted) != string(bs) {
+		t.Errorf("not in canonical format, got:\n%s\nwant:\n%s", bs, formatted)
+	}
+	return string(bs)
+}
+
+func TestMarshalTerraform(t *testing.T) {
+	cfg := &tfConfig{
+		Providers: map[string]map[string]any{"aws": {"region": "us-east-1"}},
+		Resources: []*tfSecurityGroup{{
+			Type:    "aws_security_group",
+			Name:    "web",
+			Desc:    "web access",
+			Tags:    map[string]string{"Name": "web"},
+			Labels:  map[string]any{"team": map[string]any{"owner": "ops"}},
+			Ingress: []*tfIngress{{FromPort: 443, ToPort: 443, Cidr: []string{"0.0.0.0/0"}}},
+		}},
+		Locals: map[string]any{"common": map[string]any{"env": "prod"}},
+	}
+	bs, err := MarshalWithOptions(cfg, MarshalOptions{Terraform: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `provider "aws" {
+  region = "us-east-1"
+}
+resource "aws_security_group" "web" {
+  description = "web access"
+  tags = {
+    Name = "web"
+  }
+  labels = {
+    team = {
+      owner = "ops"
+    }
+  }
+  ingress {
+    from_port   = 443
+    to_port     = 443
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+}
+locals {
+  common = {
+    env = "prod"
+  }
+}
+`
+	if got := terraformFormatted(t, bs); got != expected {
+		t.Errorf("got:\n%s", got)
+	}
+
+	// without the option, labels and locals.common are written as blocks
+	bs, err = Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "labels {") {
+		t.Errorf("expected labels block in:\n%s", bs)
+	}
+}
+
+func TestMarshalTerraformGeneric(t *testing.T) {
+	doc := map[string]any{
+		"resource": map[string]any{
+			"aws_instance": map[string]any{
+				"web": map[string]any{
+					"tags": map[string]any{"Name": "web"},
+				},
+			},
+		},
+		"variable": map[string]any{
+			"region": map[string]any{"default": "us-east-1"},
+		},
+		"terraform": map[string]any{
+			"required_providers": map[string]any{
+				"aws": map[string]any{"source": "hashicorp/aws"},
+			},
+		},
+	}
+	bs, err := MarshalWithOptions(doc, MarshalOptions{Terraform: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := terraformFormatted(t, bs)
+	// top-level keys of a generic map are not ordered
+	for _, block := range []string{`resource "aws_instance" "web" {
+  tags = {
+    Name = "web"
+  }
+}
+`, `variable "region" {
+  default = "us-east-1"
+}
+`, `terraform {
+  required_providers = {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+`} {
+		if !strings.Contains(got, block) {
+			t.Errorf("expected\n%s\nin:\n%s", block, got)
+		}
+	}
+
+	// labels are quoted and escaped like any string
+	doc = map[string]any{
+		"variable": map[string]any{
+			`say "hi" \ ${name}`: map[string]any{"default": "x"},
+		},
+	}
+	bs, err = MarshalWithOptions(doc, MarshalOptions{Terraform: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `variable "say \"hi\" \\ $${name}" {
+  default = "x"
+}
+`
+	if got := terraformFormatted(t, bs); !strings.Contains(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	_, err = MarshalWithOptions(map[string]any{"resource": map[string]any{"aws_instance": "web"}}, MarshalOptions{Terraform: true})
+	if err == nil {
+		t.Error("expected error for a resource without a name label")
+	}
+}
+
+type collapseService struct {
+	Name string   `hcl:"name,label"`
+	Port int      `hcl:"port"`
+	Tags []string `hcl:"tags,optional"`
+}
+
+func TestMarshalCollapse(t *testing.T) {
+	type config struct {
+		Services map[string]*collapseService `hcl:"service,block,collapse"`
+		Pairs    map[[2]string]*square       `hcl:"pair,block,collapse"`
+	}
+	c := &config{
+		Services: map[string]*collapseService{
+			"api": {Name: "api", Port: 8080, Tags: []string{"web"}},
+			"db":  {Name: "db", Port: 5432},
+		},
+		Pairs: map[[2]string]*square{{"a", "b"}: {SX: 1, SY: 2}},
+	}
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `  service = {
+    api = {
+      port = 8080
+      tags = ["web"]
+    }
+    db = {
+      port = 5432
+    }
+  }
+  pair = {
+    a = {
+      b = {
+        sx = 1
+        sy = 2
+      }
+    }
+  }`
+	if string(bs) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", bs, want)
+	}
+
+	for _, input := range []string{string(bs), `
+service "api" {
+  port = 8080
+  tags = ["web"]
+}
+service "db" {
+  port = 5432
+}
+pair "a" "b" {
+  sx = 1
+  sy = 2
+}`} {
+		got := new(config)
+		if err := Unmarshal([]byte(input), got); err != nil {
+			t.Fatalf("%v\n%s", err, input)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("got %#v from:\n%s", got, input)
+		}
+	}
+
+	got := new(config)
+	if err := Unmarshal([]byte("service = {}"), got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Services) != 0 {
+		t.Errorf("expected no services, got %v", got.Services)
+	}
+}
+
+func TestMarshalCollapseList(t *testing.T) {
+	type listener struct {
+		Port int `hcl:"port"`
+	}
+	type service struct {
+		Name      string      `hcl:"name,label"`
+		Listeners []*listener `hcl:"listener,block"`
+	}
+	type config struct {
+		Services map[string]*service `hcl:"service,block,collapse"`
+	}
+	c := &config{Services: map[string]*service{
+		"api": {Name: "api", Listeners: []*listener{{Port: 80}, {Port: 443}}},
+	}}
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `  service = {
+    api = {
+      listener = [
+        {
+          port = 80
+        },
+        {
+          port = 443
+        }
+      ]
+    }
+  }`
+	if string(bs) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", bs, want)
+	}
+
+	got := new(config)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("got %#v from:\n%s", got.Services["api"], bs)
+	}
+}
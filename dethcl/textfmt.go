@@ -0,0 +1,170 @@
+package dethcl
+
+import (
+	stdencoding "encoding"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+
+	"github.com/genelet/horizon/utils"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*stdencoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*stdencoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// stringType writes and parses a text type by hand.
+type stringType struct {
+	format func(v reflect.Value) string
+	parse  func(s string) (reflect.Value, error)
+}
+
+// stringTypes are the text types checked explicitly: url.URL and net.IPNet
+// have no text methods, and net.IP, a []byte, must not be taken for a list.
+var stringTypes = map[reflect.Type]stringType{
+	reflect.TypeOf(net.IP{}): {
+		format: func(v reflect.Value) string {
+			if v.Len() == 0 {
+				return ""
+			}
+			return v.Interface().(net.IP).String()
+		},
+		parse: func(s string) (reflect.Value, error) {
+			if s == "" {
+				return reflect.ValueOf(net.IP(nil)), nil
+			}
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return reflect.Value{}, fmt.Errorf("invalid IP address %q", s)
+			}
+			return reflect.ValueOf(ip), nil
+		},
+	},
+	reflect.TypeOf(net.IPNet{}): {
+		format: func(v reflect.Value) string {
+			ipnet := v.Interface().(net.IPNet)
+			if ipnet.IP == nil {
+				return ""
+			}
+			return ipnet.String()
+		},
+		parse: func(s string) (reflect.Value, error) {
+			if s == "" {
+				return reflect.ValueOf(net.IPNet{}), nil
+			}
+			_, ipnet, err := net.ParseCIDR(s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(*ipnet), nil
+		},
+	},
+	reflect.TypeOf(url.URL{}): {
+		format: func(v reflect.Value) string {
+			u := v.Interface().(url.URL)
+			return u.String()
+		},
+		parse: func(s string) (reflect.Value, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(*u), nil
+		},
+	},
+}
+
+// isTextType reports whether typ or *typ implements both
+// encoding.TextMarshaler and encoding.TextUnmarshaler, e.g. netip.Addr, or is
+// one of stringTypes, so that its values are written as strings rather than
+// as blocks or lists. So are slices and arrays of bytes, in base64. A time,
+// with its own layout, and a Marshaler are not text types. big.Int and
+// big.Float are, but are written as numbers, see isBigType.
+func isTextType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if _, ok := stringTypes[typ]; ok {
+		return true
+	}
+	if typ == timeType || typ.Kind() == reflect.Interface {
+		return false
+	}
+	if isMarshalerType(typ) {
+		return false
+	}
+	ptr := reflect.PointerTo(typ)
+	if (typ.Implements(textMarshalerType) || ptr.Implements(textMarshalerType)) && ptr.Implements(textUnmarshalerType) {
+		return true
+	}
+	return isBytesType(typ)
+}
+
+// marshalText returns the text of v, a value of a text type.
+func marshalText(v reflect.Value) (string, error) {
+	if st, ok := stringTypes[v.Type()]; ok {
+		return st.format(v), nil
+	}
+	m, ok := v.Interface().(stdencoding.TextMarshaler)
+	if !ok && !reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		return marshalBytes(v), nil
+	}
+	if !ok {
+		// MarshalText has a pointer receiver
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		m = ptr.Interface().(stdencoding.TextMarshaler)
+	}
+	bs, err := m.MarshalText()
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
+
+// textValue decodes a string ctyVal into the text field with UnmarshalText,
+// the parser of stringTypes, or from base64 for bytes. A big.Int or a
+// big.Float field also takes a number.
+// It returns ok false if the field is not of a text type.
+func textValue(ctyVal cty.Value, field reflect.StructField) (any, bool, error) {
+	if !isTextType(field.Type) {
+		return nil, false, nil
+	}
+	if ctyVal.IsNull() {
+		return reflect.Zero(field.Type).Interface(), true, nil
+	}
+	if ctyVal.IsKnown() && ctyVal.Type() == cty.Number && isBigType(field.Type) {
+		v, err := utils.ConvertCtyToFieldType(ctyVal, field.Type)
+		return v, true, err
+	}
+	if !ctyVal.IsKnown() || ctyVal.Type() != cty.String {
+		return nil, true, fmt.Errorf("expected a string for %v, got %s", field.Type, ctyVal.Type().FriendlyName())
+	}
+	typ := field.Type
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	ptr := reflect.New(typ)
+	if st, ok := stringTypes[typ]; ok {
+		v, err := st.parse(ctyVal.AsString())
+		if err != nil {
+			return nil, true, err
+		}
+		ptr.Elem().Set(v)
+	} else if !ptr.Type().Implements(textUnmarshalerType) {
+		v, err := parseBytes(ctyVal.AsString(), typ)
+		if err != nil {
+			return nil, true, err
+		}
+		ptr.Elem().Set(v)
+	} else if err := ptr.Interface().(stdencoding.TextUnmarshaler).UnmarshalText([]byte(ctyVal.AsString())); err != nil {
+		return nil, true, err
+	}
+	if field.Type.Kind() == reflect.Pointer {
+		return ptr.Interface(), true, nil
+	}
+	return ptr.Elem().Interface(), true, nil
+}
@@ -0,0 +1,139 @@
+package dethcl
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// level is an enum with text methods on the pointer receiver
+type level int
+
+func (l *level) MarshalText() ([]byte, error) {
+	return []byte([]string{"debug", "info", "warn"}[*l]), nil
+}
+
+func (l *level) UnmarshalText(text []byte) error {
+	for i, name := range []string{"debug", "info", "warn"} {
+		if name == string(text) {
+			*l = level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %q", text)
+}
+
+type listener struct {
+	Addr     netip.Addr  `hcl:"addr"`
+	Gateway  *netip.Addr `hcl:"gateway,optional"`
+	Mask     net.IP      `hcl:"mask,optional"`
+	LogLevel level       `hcl:"log_level"`
+}
+
+func TestMarshalTextTypes(t *testing.T) {
+	gw := netip.MustParseAddr("10.0.0.1")
+	l := &listener{
+		Addr:     netip.MustParseAddr("10.0.0.5"),
+		Gateway:  &gw,
+		Mask:     net.ParseIP("255.255.255.0").To4(),
+		LogLevel: 2,
+	}
+	bs, err := Marshal(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(bs)
+	for _, line := range []string{`addr      = "10.0.0.5"`, `gateway   = "10.0.0.1"`, `mask      = "255.255.255.0"`, `log_level = "warn"`} {
+		if !strings.Contains(got, line) {
+			t.Errorf("missing %q in\n%s", line, got)
+		}
+	}
+
+	back := new(listener)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Addr != l.Addr || back.Gateway == nil || *back.Gateway != gw || !back.Mask.Equal(l.Mask) || back.LogLevel != 2 {
+		t.Errorf("got %#v", back)
+	}
+
+	// optional zero values are left out
+	bs, err = Marshal(&listener{Addr: l.Addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "gateway") || strings.Contains(string(bs), "mask") {
+		t.Errorf("got\n%s", bs)
+	}
+
+	if err := Unmarshal([]byte("addr = \"10.0.0\"\nlog_level = \"info\""), new(listener)); err == nil {
+		t.Error("expected an error for a bad address")
+	}
+	if err := Unmarshal([]byte("addr = \"10.0.0.1\"\nlog_level = \"loud\""), new(listener)); err == nil {
+		t.Error("expected an error for a bad level")
+	}
+}
+
+type endpoint struct {
+	Home    url.URL    `hcl:"home"`
+	Proxy   *url.URL   `hcl:"proxy,optional"`
+	Addr    net.IP     `hcl:"addr"`
+	Subnet  net.IPNet  `hcl:"subnet,optional"`
+	Allowed *net.IPNet `hcl:"allowed,optional"`
+}
+
+func TestMarshalNetTypes(t *testing.T) {
+	proxy, _ := url.Parse("http://[::1]:3128")
+	_, allowed, _ := net.ParseCIDR("2001:db8::/32")
+	_, subnet, _ := net.ParseCIDR("10.1.0.0/16")
+	e := &endpoint{
+		Home:    url.URL{Scheme: "https", Host: "example.com", Path: "/a b", RawQuery: "x=1"},
+		Proxy:   proxy,
+		Addr:    net.ParseIP("2001:db8::68"),
+		Subnet:  *subnet,
+		Allowed: allowed,
+	}
+	bs, err := Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(bs)
+	for _, line := range []string{`home    = "https://example.com/a%20b?x=1"`, `proxy   = "http://[::1]:3128"`, `addr    = "2001:db8::68"`, `subnet  = "10.1.0.0/16"`, `allowed = "2001:db8::/32"`} {
+		if !strings.Contains(got, line) {
+			t.Errorf("missing %q in\n%s", line, got)
+		}
+	}
+
+	back := new(endpoint)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Home.String() != e.Home.String() || back.Proxy == nil || back.Proxy.String() != proxy.String() {
+		t.Errorf("got urls %v and %v", back.Home, back.Proxy)
+	}
+	if !back.Addr.Equal(e.Addr) || back.Subnet.String() != subnet.String() || back.Allowed == nil || back.Allowed.String() != allowed.String() {
+		t.Errorf("got %v, %v and %v", back.Addr, back.Subnet, back.Allowed)
+	}
+
+	// optional zero values are left out
+	bs, err = Marshal(&endpoint{Home: e.Home, Addr: e.Addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "proxy") || strings.Contains(string(bs), "subnet") || strings.Contains(string(bs), "allowed") {
+		t.Errorf("got\n%s", bs)
+	}
+
+	for _, data := range []string{
+		"home = \"https://example.com\"\naddr = \"2001:db8::zz\"",
+		"home = \"https://example.com\"\naddr = \"::1\"\nsubnet = \"10.0.0.0\"",
+		"home = \"%zz\"\naddr = \"::1\"",
+	} {
+		if err := Unmarshal([]byte(data), new(endpoint)); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isTimeType reports whether typ is time.Time or *time.Time, which are
+// written as strings rather than as blocks.
+func isTimeType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ == timeType
+}
+
+// timeLayout returns the layout of a time field, given by its hcltime tag,
+// or time.RFC3339.
+func timeLayout(field reflect.StructField) string {
+	if layout, ok := field.Tag.Lookup(tagKeyTime); ok && layout != "" {
+		return layout
+	}
+	return time.RFC3339
+}
+
+// timeValue parses a string ctyVal into the time field, with the layout of
+// timeLayout. It returns ok false if the field is not a time.
+func timeValue(ctyVal cty.Value, field reflect.StructField) (any, bool, error) {
+	if !isTimeType(field.Type) {
+		return nil, false, nil
+	}
+	if ctyVal.IsNull() {
+		return reflect.Zero(field.Type).Interface(), true, nil
+	}
+	if !ctyVal.IsKnown() || ctyVal.Type() != cty.String {
+		return nil, true, fmt.Errorf("expected a time string, got %s", ctyVal.Type().FriendlyName())
+	}
+	layout := timeLayout(field)
+	t, err := time.Parse(layout, ctyVal.AsString())
+	if err != nil {
+		return nil, true, fmt.Errorf("time %q does not match layout %q: %w", ctyVal.AsString(), layout, err)
+	}
+	if field.Type.Kind() == reflect.Pointer {
+		return &t, true, nil
+	}
+	return t, true, nil
+}
@@ -0,0 +1,252 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/genelet/horizon/utils"
+)
+
+// ToMap returns a struct, or a map, as the generic value that Unmarshal into
+// a map[string]any gives for its HCL, without writing and parsing the HCL,
+// e.g. to convert the struct to YAML or JSON afterwards:
+//
+//	m, err := dethcl.ToMap(&Config{Name: "api", Port: 8080})
+//	// m: map[string]any{"name": "api", "port": 8080}
+//
+// The fields are those Marshal writes, under the same names. Blocks give
+// maps, nested under their labels or map keys, e.g. service "api" { ... }
+// gives {"service": {"api": {...}}}, and slices give []any, even of a single
+// element, which HCL would not tell apart from a block. Pointer and
+// interface fields give their contents, numbers are int or float as
+// Unmarshal decodes them, and times, text types, enums and bytes are strings
+// as Marshal writes them.
+//
+// Returns nil for a nil value, and an error for other kinds such as slices.
+func ToMap(current any) (map[string]any, error) {
+	rv := reflect.ValueOf(current)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Kind() != reflect.Struct && rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("ToMap needs a struct or a map, got %T", current)
+	}
+	value, labels, err := genericValue(rv)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := value.(map[string]any); ok && len(labels) == 0 {
+		return m, nil
+	}
+	return nestLabels(make(map[string]any), labels, value), nil
+}
+
+// structToMap returns the fields of the struct v, keyed by their HCL names,
+// along with the values of its label fields.
+func structToMap(v reflect.Value) (map[string]any, []string, error) {
+	fields, err := getFields(nil, v.Type(), v)
+	if err != nil {
+		return nil, nil, err
+	}
+	body := make(map[string]any, len(fields))
+	var labels []string
+	for _, f := range fields {
+		if f.omitted {
+			continue
+		}
+		tagParts := parseHCLTag(f.field.Tag)
+		if !f.out && tagParts[1] == tagModifierLabel {
+			label, err := labelString(f.value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("field %s: %w", f.field.Name, err)
+			}
+			if label != "" {
+				labels = append(labels, label)
+			}
+			continue
+		}
+		fieldValue := f.value
+		if prefix, suffix := stringAffixes(f.field); prefix != "" || suffix != "" {
+			fieldValue = reflect.ValueOf(prefix + fieldValue.String() + suffix)
+		}
+		var value any
+		var fieldLabels []string
+		if !f.out && isTimeType(fieldValue.Type()) {
+			value = fieldValue.Interface().(time.Time).Format(timeLayout(f.field))
+		} else if value, fieldLabels, err = genericValue(fieldValue); err != nil {
+			return nil, nil, fmt.Errorf("field %s: %w", f.field.Name, err)
+		}
+		if value == nil {
+			continue
+		}
+		if len(fieldLabels) > 0 {
+			value = nestLabels(make(map[string]any), fieldLabels, value)
+		}
+		body[tagParts[0]] = value
+	}
+	return body, labels, nil
+}
+
+// genericValue returns v as a value of map[string]any, []any, string, bool
+// or number, and the labels of a struct. It returns nil for a nil pointer,
+// interface, map or slice.
+func genericValue(v reflect.Value) (any, []string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil, nil, nil
+	}
+	if name, ok := enumName(v); ok {
+		return name, nil, nil
+	}
+	if isTimeType(v.Type()) {
+		return v.Interface().(time.Time).Format(time.RFC3339), nil, nil
+	}
+	if isBigType(v.Type()) {
+		return bigNumber(v), nil, nil
+	}
+	if isTextType(v.Type()) {
+		text, err := marshalText(v)
+		return text, nil, err
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		body, labels, err := structToMap(v)
+		return body, labels, err
+	case reflect.Slice, reflect.Array:
+		return sliceToGeneric(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil, nil
+		}
+		m, err := mapToGeneric(v)
+		return m, nil, err
+	case reflect.String:
+		return v.String(), nil, nil
+	case reflect.Bool:
+		return v.Bool(), nil, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// numbers take the types Unmarshal gives them
+		c, err := utils.NativeToCty(v.Interface())
+		if err != nil {
+			return nil, nil, err
+		}
+		n, err := utils.CtyNumberToNative(c)
+		return n, nil, err
+	default:
+		return nil, nil, fmt.Errorf("unsupported type %v", v.Type())
+	}
+}
+
+// sliceToGeneric returns the elements of the slice or array v as []any, or,
+// if they carry labels, as a map of them nested under their labels, as
+// Unmarshal reads repeated labeled blocks.
+func sliceToGeneric(v reflect.Value) (any, []string, error) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return nil, nil, nil
+	}
+	list := make([]any, v.Len())
+	var labeled map[string]any
+	for i := range list {
+		value, labels, err := genericValue(v.Index(i))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(labels) > 0 {
+			if labeled == nil {
+				labeled = make(map[string]any)
+			}
+			nestLabels(labeled, labels, value)
+			continue
+		}
+		list[i] = value
+	}
+	if labeled != nil {
+		return labeled, nil, nil
+	}
+	return list, nil, nil
+}
+
+// mapToGeneric returns the map v as a map[string]any keyed by its keys, or
+// nested under both keys for a [2]string key. The labels of a struct value
+// nest it further, unless they repeat the keys, as Marshal writes them.
+func mapToGeneric(v reflect.Value) (map[string]any, error) {
+	m := make(map[string]any, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		keys, err := mapKeyLabels(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		value, labels, err := genericValue(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		for i, label := range labels {
+			if i >= len(keys) || keys[i] != label {
+				keys = append(keys, label)
+			}
+		}
+		nestLabels(m, keys, value)
+	}
+	return m, nil
+}
+
+// mapKeyLabels returns the labels given by the key of a map: a string, an
+// integer in decimal, or both strings of a [2]string.
+func mapKeyLabels(key reflect.Value) ([]string, error) {
+	switch key.Kind() {
+	case reflect.String:
+		return []string{key.String()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []string{strconv.FormatInt(key.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []string{strconv.FormatUint(key.Uint(), 10)}, nil
+	case reflect.Array:
+		if key.Len() == 2 && key.Type().Elem().Kind() == reflect.String {
+			return []string{key.Index(0).String(), key.Index(1).String()}, nil
+		}
+	default:
+	}
+	return nil, fmt.Errorf("unsupported map key type %v", key.Type())
+}
+
+// nestLabels sets value in m under the path of labels, e.g. m[a][b] for
+// labels a and b, creating the maps on the way, and returns m. Without
+// labels, value must be a map, whose entries are copied to m.
+func nestLabels(m map[string]any, labels []string, value any) map[string]any {
+	if len(labels) == 0 {
+		if entries, ok := value.(map[string]any); ok {
+			for k, v := range entries {
+				m[k] = v
+			}
+		}
+		return m
+	}
+	current := m
+	for _, label := range labels[:len(labels)-1] {
+		next, ok := current[label].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			current[label] = next
+		}
+		current = next
+	}
+	current[labels[len(labels)-1]] = value
+	return m
+}
@@ -0,0 +1,109 @@
+package dethcl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mappedService struct {
+	Name string `hcl:"name,label"`
+	Port int    `hcl:"port"`
+}
+
+type mapped struct {
+	Title    string                    `hcl:"title"`
+	Count    int                       `hcl:"count"`
+	Ratio    float64                   `hcl:"ratio"`
+	Enabled  bool                      `hcl:"enabled"`
+	Tags     []string                  `hcl:"tags"`
+	Env      map[string]string         `hcl:"env"`
+	Started  time.Time                 `hcl:"started" hcltime:"2006-01-02"`
+	Key      []byte                    `hcl:"key"`
+	Main     *mappedService            `hcl:"main,block"`
+	Replicas []*mappedService          `hcl:"replica,block"`
+	Services map[string]*mappedService `hcl:"service,block"`
+	Routes   map[[2]string]*square     `hcl:"route,block"`
+	Shape    inter                     `hcl:"shape,block"`
+	Shapes   []inter                   `hcl:"shapes,block"`
+	Extra    any                       `hcl:"extra,optional"`
+	Unset    *square                   `hcl:"unset,block"`
+	Skipped  string                    `hcl:"skipped,optional"`
+}
+
+func TestToMap(t *testing.T) {
+	v := &mapped{
+		Title:    "demo",
+		Count:    3,
+		Ratio:    1.5,
+		Enabled:  true,
+		Tags:     []string{"a", "b"},
+		Env:      map[string]string{"HOME": "/root"},
+		Started:  time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+		Key:      []byte("hi"),
+		Main:     &mappedService{Name: "main", Port: 80},
+		Replicas: []*mappedService{{Name: "r1", Port: 81}, {Name: "r2", Port: 82}},
+		Services: map[string]*mappedService{"api": {Name: "api", Port: 8080}},
+		Routes:   map[[2]string]*square{{"get", "/"}: {SX: 1, SY: 2}},
+		Shape:    &circle{Radius: 2},
+		Shapes:   []inter{&square{SX: 3, SY: 4}, &circle{Radius: 5}},
+		Extra:    map[string]any{"z": 1},
+	}
+
+	got, err := ToMap(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"title":   "demo",
+		"count":   3,
+		"ratio":   float32(1.5),
+		"enabled": true,
+		"tags":    []any{"a", "b"},
+		"env":     map[string]any{"HOME": "/root"},
+		"started": "2024-05-06",
+		"key":     "aGk=",
+		"main":    map[string]any{"main": map[string]any{"port": 80}},
+		"replica": map[string]any{
+			"r1": map[string]any{"port": 81},
+			"r2": map[string]any{"port": 82},
+		},
+		"service": map[string]any{"api": map[string]any{"port": 8080}},
+		"route":   map[string]any{"get": map[string]any{"/": map[string]any{"sx": 1, "sy": 2}}},
+		"shape":   map[string]any{"radius": 2},
+		"shapes":  []any{map[string]any{"sx": 3, "sy": 4}, map[string]any{"radius": 5}},
+		"extra":   map[string]any{"z": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %#v\nwant %#v", got, want)
+	}
+
+	// the same map as through HCL
+	bs, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := make(map[string]any)
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, back) {
+		t.Errorf("got  %#v\nfrom %#v", got, back)
+	}
+}
+
+func TestToMapErrors(t *testing.T) {
+	if m, err := ToMap(nil); m != nil || err != nil {
+		t.Errorf("nil: got %v, %v", m, err)
+	}
+	if m, err := ToMap((*mapped)(nil)); m != nil || err != nil {
+		t.Errorf("nil pointer: got %v, %v", m, err)
+	}
+	if _, err := ToMap([]int{1}); err == nil {
+		t.Error("expected an error for a slice")
+	}
+	m, err := ToMap(map[string]*square{"a": {SX: 1, SY: 2}})
+	if err != nil || !reflect.DeepEqual(m, map[string]any{"a": map[string]any{"sx": 1, "sy": 2}}) {
+		t.Errorf("map: got %#v, %v", m, err)
+	}
+}
@@ -0,0 +1,150 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/OpenUdon/schema"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// typedSpecs adds to objectMap the spec of each interface field tagged with
+// typefield=attr that the spec leaves out. The class of each block is read
+// from its attr attribute, e.g. type = "http", and looked up in ref, so a
+// list of steps of different types needs no spec per index. Single interface
+// fields and maps keyed by label are handled alike.
+//
+// Untagged interface fields are typed alike by the discriminator attribute,
+// see SetDiscriminator, which is returned to be consumed. With directives,
+// see EnableDirectives, a block without the attribute takes the class of its
+// type directive. The blocks of an untagged field are typed all or none.
+func typedSpecs(structType reflect.Type, bd *hclsyntax.Body, objectMap map[string]*schema.Value, ref map[string]any, directives map[*hclsyntax.Block]map[string]string) ([]*hclsyntax.Attribute, error) {
+	var consumed []*hclsyntax.Attribute
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, modifier := HCLName(field)
+		attr, typed := tagOptionValue(modifier, tagOptionTypeField)
+		if !typed {
+			attr = discriminator(ref)
+			if (attr == "" && directives == nil) || !holdsInterfaceBlocks(field.Type) {
+				continue
+			}
+		}
+		if _, ok := objectMap[field.Name]; ok {
+			continue
+		}
+
+		var classes []string
+		var untyped *hclsyntax.Block
+		var discriminators []*hclsyntax.Attribute
+		labels := make(map[string]string)
+		for _, block := range bd.Blocks {
+			if block.Type != name {
+				continue
+			}
+			className, err := blockClass(block, attr, typed, directives[block], ref)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			if className == "" {
+				untyped = block
+				continue
+			}
+			classes = append(classes, className)
+			var label string
+			if len(block.Labels) > 0 {
+				label = block.Labels[0]
+			}
+			labels[label] = className
+			if a, ok := block.Body.Attributes[attr]; ok && !typed {
+				discriminators = append(discriminators, a)
+			}
+		}
+		if classes == nil {
+			continue
+		}
+		if untyped != nil {
+			var missing []string
+			if directives != nil {
+				missing = append(missing, directivePrefix+directiveType+" directive")
+			}
+			if attr != "" {
+				missing = append(missing, attr+" attribute")
+			}
+			return nil, fmt.Errorf("field %s: block %s at %s: missing %s", field.Name, untyped.Type, untyped.DefRange(), strings.Join(missing, " or "))
+		}
+		consumed = append(consumed, discriminators...)
+
+		typ := field.Type
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		var valueSpec *schema.Value
+		var err error
+		switch {
+		case typ.Kind() == reflect.Interface:
+			valueSpec, err = schema.NewValue(classes[0])
+		case (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) && typ.Elem().Kind() == reflect.Interface:
+			valueSpec, err = schema.NewValue(classes)
+		case typ.Kind() == reflect.Map && typ.Key().Kind() == reflect.String && typ.Elem().Kind() == reflect.Interface:
+			valueSpec, err = schema.NewValue(labels)
+		default:
+			return nil, fmt.Errorf("field %s: %s needs an interface, or a slice or map of interfaces, got %v", field.Name, tagOptionTypeField, field.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+		objectMap[field.Name] = valueSpec
+	}
+	return consumed, nil
+}
+
+// holdsInterfaceBlocks reports whether typ is a non-empty interface, or a
+// slice, array or string-keyed map of them, which directives can type.
+// Empty interfaces decode generic blocks as maps instead.
+func holdsInterfaceBlocks(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		typ = typ.Elem()
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			return false
+		}
+		typ = typ.Elem()
+	default:
+	}
+	return typ.Kind() == reflect.Interface && typ.NumMethod() > 0
+}
+
+// blockClass returns the class name of block, which must name a type in
+// ref. It is given by the attr attribute, which must be a literal string,
+// or else by the type directive. Without either, a block of a typefield
+// field, required, is an error, while other blocks give "".
+func blockClass(block *hclsyntax.Block, attr string, required bool, directive map[string]string, ref map[string]any) (string, error) {
+	var className string
+	if a, ok := block.Body.Attributes[attr]; ok && attr != "" {
+		val, diags := a.Expr.Value(nil)
+		if diags.HasErrors() || val.IsNull() || !val.IsKnown() || val.Type() != cty.String {
+			return "", fmt.Errorf("block %s at %s: %s must be a literal string", block.Type, block.DefRange(), attr)
+		}
+		className = val.AsString()
+	} else if className, ok = directive[directiveType]; ok {
+		attr = directivePrefix + directiveType
+	} else if required {
+		return "", fmt.Errorf("block %s at %s: missing %s attribute", block.Type, block.DefRange(), attr)
+	} else {
+		return "", nil
+	}
+	if lookupType(ref, className) == nil {
+		return "", fmt.Errorf("block %s at %s: %s %q not found in ref map", block.Type, block.DefRange(), attr, className)
+	}
+	return className, nil
+}
@@ -0,0 +1,105 @@
+package dethcl
+
+import (
+	"strings"
+	"testing"
+)
+
+type step interface {
+	Run() string
+}
+
+type httpStep struct {
+	Type string `hcl:"type"`
+	URL  string `hcl:"url"`
+}
+
+func (s *httpStep) Run() string { return "GET " + s.URL }
+
+type shellStep struct {
+	Type    string `hcl:"type"`
+	Command string `hcl:"command"`
+}
+
+func (s *shellStep) Run() string { return "sh -c " + s.Command }
+
+type pipeline struct {
+	Name   string          `hcl:"name"`
+	Steps  []step          `hcl:"step,block,typefield=type"`
+	Hooks  map[string]step `hcl:"hook,block,typefield=type"`
+	Finish step            `hcl:"finish,block,typefield=type"`
+}
+
+func TestTypeField(t *testing.T) {
+	data := `name = "deploy"
+step {
+  type = "http"
+  url  = "https://example.com/build"
+}
+step {
+  type    = "shell"
+  command = "make install"
+}
+step {
+  type = "http"
+  url  = "https://example.com/notify"
+}
+hook "failure" {
+  type    = "shell"
+  command = "make clean"
+}
+finish {
+  type = "http"
+  url  = "https://example.com/done"
+}
+`
+	ref := map[string]any{"http": new(httpStep), "shell": new(shellStep)}
+	p := new(pipeline)
+	if err := UnmarshalSpec([]byte(data), p, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	var runs []string
+	for _, s := range p.Steps {
+		runs = append(runs, s.Run())
+	}
+	if got := strings.Join(runs, "; "); got != "GET https://example.com/build; sh -c make install; GET https://example.com/notify" {
+		t.Errorf("got %s", got)
+	}
+	if h, ok := p.Hooks["failure"].(*shellStep); !ok || h.Command != "make clean" {
+		t.Errorf("got %#v", p.Hooks)
+	}
+	if f, ok := p.Finish.(*httpStep); !ok || f.URL != "https://example.com/done" {
+		t.Errorf("got %#v", p.Finish)
+	}
+
+	// the type attribute is written back by the concrete types
+	bs, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again := new(pipeline)
+	if err := UnmarshalSpec(bs, again, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Steps) != 3 || again.Steps[1].Run() != "sh -c make install" {
+		t.Errorf("round trip got %#v", again.Steps)
+	}
+}
+
+func TestTypeFieldErrors(t *testing.T) {
+	ref := map[string]any{"http": new(httpStep)}
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"name = \"a\"\nstep {\n  url = \"x\"\n}\n", "missing type attribute"},
+		{"name = \"a\"\nstep {\n  type = \"ftp\"\n}\n", `type "ftp" not found`},
+		{"name = \"a\"\nstep {\n  type = upper(\"http\")\n}\n", "must be a literal string"},
+	}
+	for _, tt := range tests {
+		err := UnmarshalSpec([]byte(tt.data), new(pipeline), nil, ref)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error with %q, got %v", tt.data, tt.want, err)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package dethcl
 
 import (
 	"fmt"
+	"maps"
 	"reflect"
-	"regexp"
 	"slices"
 	"strings"
 	"unicode"
@@ -13,6 +13,7 @@ import (
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -40,6 +41,10 @@ type Unmarshaler interface {
 //   - Structs with hcl tags
 //   - map[string]any and []any for dynamic content
 //
+// A pointer block field is left unchanged, nil for a new value, when its block
+// is absent, and points to a zero value when the block is present but empty,
+// e.g. limits {}. Marshal writes such a pointer as an empty block.
+//
 // Example:
 //
 //	type Config struct {
@@ -63,13 +68,27 @@ func Unmarshal(hclData []byte, current any, labels ...string) error {
 	if rv.IsNil() {
 		return nil
 	}
-	unmarshaler, ok := current.(Unmarshaler)
-	if ok {
-		return unmarshaler.UnmarshalHCL(hclData, labels...)
+	if ok, err := unmarshalCustom(nil, hclData, current, labels...); ok {
+		return err
 	}
 	return UnmarshalSpec(hclData, current, nil, nil, labels...)
 }
 
+// unmarshalCustom decodes hclData into current by its UnmarshalHCL method and
+// validates the result, prefixing errors with the path of node. It returns
+// false if current does not implement Unmarshaler. All entry points use it,
+// so that such a type is validated however it is decoded.
+func unmarshalCustom(node *utils.Tree, hclData []byte, current any, labels ...string) (bool, error) {
+	unmarshaler, ok := current.(Unmarshaler)
+	if !ok {
+		return false, nil
+	}
+	if err := unmarshaler.UnmarshalHCL(hclData, labels...); err != nil {
+		return true, err
+	}
+	return true, validate(node, current)
+}
+
 // UnmarshalSpec decodes HCL data into a Go value with dynamic type resolution.
 //
 // This function extends Unmarshal by supporting interface fields through runtime
@@ -124,6 +143,51 @@ func Unmarshal(hclData []byte, current any, labels ...string) error {
 //
 // Returns an error if decoding fails or if referenced types are not in ref map.
 func UnmarshalSpec(hclData []byte, current any, spec *schema.Struct, ref map[string]any, labels ...string) error {
+	return unmarshalSpec(hclData, current, spec, ref, nil, labels...)
+}
+
+// UnmarshalWithPresence decodes HCL data like Unmarshal and also reports which
+// fields the data actually set, as opposed to fields left at their zero or
+// existing values.
+//
+// The keys of present are dot-joined HCL names from the root, with block labels
+// as path elements. Blocks and their labels are reported as well as attributes.
+// Repeated blocks without labels share one path.
+//
+// Example:
+//
+//	hcl := []byte(`name = "app"
+//	service "api" {
+//	    port = 8080
+//	}`)
+//	present, err := UnmarshalWithPresence(hcl, &cfg)
+//	// present: name, service, service.api, service.api.port
+//
+// Types implementing Unmarshaler decode themselves and report nothing.
+func UnmarshalWithPresence(hclData []byte, current any, labels ...string) (map[string]bool, error) {
+	if current == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(current)
+	if rv.Kind() != reflect.Pointer {
+		return nil, fmt.Errorf("non-pointer or nil data")
+	}
+	if rv.IsNil() {
+		return nil, nil
+	}
+	state := newDecodeState()
+	if ok, err := unmarshalCustom(nil, hclData, current, labels...); ok {
+		return state.present, err
+	}
+	if err := unmarshalSpec(hclData, current, nil, nil, state, labels...); err != nil {
+		return nil, err
+	}
+	return state.present, nil
+}
+
+// unmarshalSpec is UnmarshalSpec with an optional decodeState shared by all
+// nested decodes.
+func unmarshalSpec(hclData []byte, current any, spec *schema.Struct, ref map[string]any, state *decodeState, labels ...string) error {
 	// Extract implementations from ref (values that are []any)
 	implementations := make(map[string][]any)
 	for k, v := range ref {
@@ -142,6 +206,10 @@ func UnmarshalSpec(hclData []byte, current any, spec *schema.Struct, ref map[str
 		}
 	}
 
+	if state != nil {
+		autoRef[contextKeyDecodeState] = state
+	}
+
 	node := utils.NewEvalContext(autoRef)
 	return UnmarshalSpecTree(node, hclData, current, spec, node.GetRef(), labels...)
 }
@@ -184,13 +252,16 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 		return nil
 	}
 	reflectValue = reflectValue.Elem()
+	if err := getDecodeState(ref).checkDepth(node); err != nil {
+		return err
+	}
 
 	// Handle map[string]any and []any types
 	switch reflectValue.Kind() {
 	case reflect.Map:
-		return unmarshalToMap(node, hclData, current)
+		return unmarshalToMap(node, hclData, current, ref)
 	case reflect.Slice:
-		return unmarshalToSlice(node, hclData, current)
+		return unmarshalToSlice(node, hclData, current, ref)
 	default:
 	}
 
@@ -207,10 +278,12 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 		return fmt.Errorf("non-struct object")
 	}
 
-	// Get spec fields or create empty map
+	// Get spec fields or create empty map. The map is copied because inferred
+	// fields are added to it below, which must not leak into the caller's spec
+	// or into other subtrees sharing the same spec.
 	var objectMap map[string]*schema.Value
 	if spec != nil {
-		objectMap = spec.GetFields()
+		objectMap = maps.Clone(spec.GetFields())
 	}
 	if objectMap == nil {
 		objectMap = make(map[string]*schema.Value)
@@ -222,6 +295,11 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 		return err
 	}
 
+	// Read blocks of repeated fields as list attributes
+	if err := repeatedAttributes(structType, hclBody); err != nil {
+		return err
+	}
+
 	// Evaluate expressions and find null attributes
 	nullAttrs, err := evaluateExpressions(ref, node, file, hclBody)
 	if err != nil {
@@ -231,6 +309,36 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 	// Register blocks in tree
 	addBlocksToTree(node, hclBody.Blocks)
 
+	// Record what the data sets, for UnmarshalWithPresence
+	state := getDecodeState(ref)
+	if state != nil {
+		state.recordPresence(node, hclBody)
+	}
+
+	// Record names without field, for UnmarshalStrict
+	if state != nil && state.strict {
+		state.recordUnknown(hclData, structType, hclBody)
+	}
+
+	// Name the classes of blocks that carry their own type, by attribute or
+	// by comment directive. Discriminator attributes are not decoded.
+	var directives map[*hclsyntax.Block]map[string]string
+	if directivesEnabled(ref) {
+		if directives, err = blockDirectives(hclData, hclBody); err != nil {
+			return err
+		}
+	}
+	consumed, err := typedSpecs(structType, hclBody, objectMap, ref, directives)
+	if err != nil {
+		return err
+	}
+	if consumed != nil {
+		file.Bytes = blankAttributes(file.Bytes, consumed)
+	}
+	if err := checkAmbiguous(structType, hclBody, objectMap, ref); err != nil {
+		return err
+	}
+
 	// Categorize struct fields
 	fieldCategories, err := categorizeStructFields(structType, objectMap, ref, nullAttrs)
 	if err != nil {
@@ -238,7 +346,7 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 	}
 
 	// Parse and separate HCL body into labels, attributes, and blocks
-	parseResult, err := categorizeHCLBody(node, file, hclBody, nullAttrs, fieldCategories.BlockFields, fieldCategories.InterfaceFields, fieldCategories.SimpleFields, fieldCategories.Labels)
+	parseResult, err := categorizeHCLBody(node, file, hclBody, nullAttrs, state.numberMode(), fieldCategories.BlockFields, fieldCategories.InterfaceFields, fieldCategories.SimpleFields, fieldCategories.Labels)
 	if err != nil {
 		return err
 	}
@@ -249,6 +357,11 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 	updatedValue.Set(targetValue.Elem())
 
 	// Process label fields
+	if state != nil && state.strs != nil {
+		for i, label := range labels {
+			labels[i] = state.intern(label)
+		}
+	}
 	if err := processLabels(fieldCategories.Labels, updatedValue, parseResult.LabelExprs, labels); err != nil {
 		return err
 	}
@@ -256,20 +369,34 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 	// Process simple fields (strings, numbers, etc.)
 	processSimpleFields(fieldCategories.SimpleFields, parseResult.SimpleFieldsValue, updatedValue, parseResult.ExistingAttrs)
 
+	// Reset pointer fields assigned null, e.g. `port = null`
+	processNullFields(structType, updatedValue, nullAttrs, state)
+
+	// Keep the comments above attributes and blocks in a comment field
+	if err := processCommentField(structType, updatedValue, hclData, hclBody); err != nil {
+		return err
+	}
+
 	// Process map/slice interface fields
 	if err := processMapOrSliceFields(ref, node, file, fieldCategories.InterfaceFields, parseResult.InterfaceAttrs, parseResult.InterfaceBlocks, updatedValue); err != nil {
 		return err
 	}
 
-	// Process complex block fields (Map2Struct, MapStruct, ListStruct, SingleStruct)
-	if err := processBlockFields(node, file, ref, fieldCategories.BlockFields, parseResult.BlockData, objectMap, updatedValue); err != nil {
+	// Process complex block fields (Map2Struct, MapStruct, ListStruct, SingleStruct).
+	// With UnmarshalOptions.ContinueOnError, the fields decoded are kept
+	// along with the errors of the others.
+	err = processBlockFields(node, file, ref, fieldCategories.BlockFields, parseResult.BlockData, objectMap, updatedValue)
+	if err != nil && (state == nil || !state.keepGoing) {
 		return err
 	}
 
 	// Apply all changes to the original struct
 	targetValue.Set(updatedValue)
 
-	return nil
+	if err != nil {
+		return err
+	}
+	return validate(node, current)
 }
 
 // tryUnmarshalWithCustom attempts to unmarshal using custom Unmarshaler interface first,
@@ -289,9 +416,8 @@ func UnmarshalSpecTree(node *utils.Tree, hclData []byte, current any, spec *sche
 //
 // Returns error if unmarshaling fails.
 func tryUnmarshalWithCustom(subnode *utils.Tree, hclData []byte, trial any, nextStruct *schema.Struct, ref map[string]any, labels ...string) error {
-	unmarshaler, ok := trial.(Unmarshaler)
-	if ok {
-		return unmarshaler.UnmarshalHCL(hclData, labels...)
+	if ok, err := unmarshalCustom(subnode, hclData, trial, labels...); ok {
+		return err
 	}
 	return UnmarshalSpecTree(subnode, hclData, trial, nextStruct, ref, labels...)
 }
@@ -306,8 +432,9 @@ type hclBodyParseResult struct {
 	BlockData         map[string][]*hclsyntax.Block   // Complex block data
 }
 
-// categorizeHCLBody parses and categorizes HCL body elements into different field types
-func categorizeHCLBody(node *utils.Tree, file *hcl.File, hclBody *hclsyntax.Body, nullAttrs []string, blockFields, interfaceFields, newFields, newLabels []reflect.StructField) (*hclBodyParseResult, error) {
+// categorizeHCLBody parses and categorizes HCL body elements into different field types.
+// Numbers decoded into empty interface fields get the Go types selected by numbers.
+func categorizeHCLBody(node *utils.Tree, file *hcl.File, hclBody *hclsyntax.Body, nullAttrs []string, numbers utils.NumberMode, blockFields, interfaceFields, newFields, newLabels []reflect.StructField) (*hclBodyParseResult, error) {
 	result := &hclBodyParseResult{
 		BlockData:       make(map[string][]*hclsyntax.Block),
 		InterfaceBlocks: make(map[string][]*hclsyntax.Block),
@@ -335,28 +462,16 @@ func categorizeHCLBody(node *utils.Tree, file *hcl.File, hclBody *hclsyntax.Body
 		} else if blockTags[attrName] { // this MUST BE hash or slice with equal sign.
 			// Unmarshal []any produces an equal sign (unmarshal a map[string]any does not)
 			// Equal sign results in suxh N attribute. It is recorded in oriref and there is a struct associated.
-			if literalExpr, ok := attr.Expr.(*hclsyntax.LiteralValueExpr); !ok || !literalExpr.Val.CanIterateElements() {
-				return nil, fmt.Errorf("unknown expression type %T", literalExpr)
+			literalExpr, ok := attr.Expr.(*hclsyntax.LiteralValueExpr)
+			if !ok || !literalExpr.Val.CanIterateElements() {
+				return nil, fmt.Errorf("unknown expression type %T", attr.Expr)
 			}
-			start := attr.EqualsRange.End.Byte + 1
-			attributeContent := string(file.Bytes[start:attr.SrcRange.End.Byte])
-			blockPattern := regexp.MustCompile(`(?s){[^}]+}`)
-			// the starting and ending positions of each matched string block, including the braces
-			indices := blockPattern.FindAllStringIndex(attributeContent, -1)
-			// there is only one block in case of hash; there would be multiple blocks in case of slice
-			for _, item := range indices {
-				block := &hclsyntax.Block{
-					Type: attrName,
-					OpenBraceRange: hcl.Range{
-						End: hcl.Pos{Byte: start + item[0] + 1}, // remove leading brace
-					},
-					CloseBraceRange: hcl.Range{
-						Start: hcl.Pos{Byte: start + item[1] - 1}, // remove trailing brace
-					},
-				}
-				result.BlockData[attrName] = append(result.BlockData[attrName], block)
+			blocks, err := objectBlocks(file, attrName, literalExpr.Val, mapFieldLabels(blockFields, attrName))
+			if err != nil {
+				return nil, err
 			}
-			node.AddNode(attrName)
+			result.BlockData[attrName] = append(result.BlockData[attrName], blocks...)
+			addBlocksToTree(node, blocks)
 		} else {
 			if body.Attributes == nil {
 				body.Attributes = make(map[string]*hclsyntax.Attribute)
@@ -396,21 +511,45 @@ func categorizeHCLBody(node *utils.Tree, file *hcl.File, hclBody *hclsyntax.Body
 
 		ctyVal, ok := ctyValInterface.(cty.Value)
 		if !ok {
-			return nil, hcl.Diagnostics{{
+			return nil, newFieldError(node, tag, hcl.Diagnostics{{
 				Severity: hcl.DiagError,
 				Summary:  "Internal error",
 				Detail:   fmt.Sprintf("Field %s: expected cty.Value, got %T", tag, ctyValInterface),
-			}}
+			}})
+		}
+
+		// a list of objects for a map field, e.g. `hcl:"entries,mapkey=key"`
+		if keyAttr, ok := tagOptionValue(parseHCLTag(field.Tag)[1], tagOptionMapKey); ok {
+			var err error
+			ctyVal, err = listToMap(ctyVal, keyAttr, field.Type)
+			if err != nil {
+				return nil, newFieldError(node, tag, hcl.Diagnostics{{
+					Severity: hcl.DiagError,
+					Summary:  "Type conversion error",
+					Detail:   fmt.Sprintf("Field %s: %v", tag, err),
+				}})
+			}
 		}
 
 		// Convert to the exact field type
-		nativeVal, err := utils.ConvertCtyToFieldType(ctyVal, field.Type)
+		// a time is parsed with its layout, a text type by UnmarshalText, a
+		// registered enum is given by its name
+		nativeVal, done, err := timeValue(ctyVal, field)
+		if !done {
+			nativeVal, done, err = textValue(ctyVal, field)
+		}
+		if !done {
+			nativeVal, done, err = enumValue(ctyVal, field.Type)
+		}
+		if !done {
+			nativeVal, err = utils.ConvertCtyToFieldTypeMode(ctyVal, field.Type, numbers)
+		}
 		if err != nil {
-			return nil, hcl.Diagnostics{{
+			return nil, newFieldError(node, tag, hcl.Diagnostics{{
 				Severity: hcl.DiagError,
 				Summary:  "Type conversion error",
 				Detail:   fmt.Sprintf("Field %s: %v", tag, err),
-			}}
+			}})
 		}
 
 		rawValue.Field(i).Set(reflect.ValueOf(nativeVal))
@@ -420,6 +559,123 @@ func categorizeHCLBody(node *utils.Tree, file *hcl.File, hclBody *hclsyntax.Body
 	return result, nil
 }
 
+// objectBlocks turns the evaluated value of an attribute such as
+//
+//	items = [{ radius = 1 }, { sx = 2, sy = 3 }]
+//
+// into blocks of type name, one per object of a list, or one for a single
+// object, so that block fields with a spec can decode them. The object bodies
+// are written as HCL and appended to file.Bytes, where the block ranges point.
+//
+// For a map field, an object of objects such as
+//
+//	services = { api = { port = 8080 } }
+//
+// gives one block per entry, labeled with its key, as services "api" {...}.
+// keys is the number of labels of the map, two for a [2]string key, whose
+// entries are nested one level deeper, or zero for another field. An empty
+// object gives no block for a map.
+func objectBlocks(file *hcl.File, name string, val cty.Value, keys int) ([]*hclsyntax.Block, error) {
+	elements := []cty.Value{val}
+	var labels [][]string
+	if typ := val.Type(); typ.IsTupleType() || typ.IsListType() || typ.IsSetType() {
+		elements = val.AsValueSlice()
+	} else if keys > 0 && objectOfObjects(val) {
+		labels, elements = objectEntries(val, keys)
+	} else if keys > 0 && (typ.IsObjectType() || typ.IsMapType()) && val.LengthInt() == 0 {
+		return nil, nil
+	}
+
+	// the bodies are appended to a copy, since file.Bytes is the caller's
+	// data, whose spare capacity must be left alone
+	file.Bytes = slices.Clip(file.Bytes)
+	var blocks []*hclsyntax.Block
+	for i, element := range elements {
+		typ := element.Type()
+		if element.IsNull() || !(typ.IsObjectType() || typ.IsMapType()) {
+			return nil, fmt.Errorf("attribute %s: element %d is %s, expected an object", name, i, typ.FriendlyName())
+		}
+		body := hclwrite.NewEmptyFile()
+		for k, v := range element.AsValueMap() {
+			body.Body().SetAttributeValue(k, v)
+		}
+		start := len(file.Bytes)
+		file.Bytes = append(file.Bytes, '\n')
+		file.Bytes = append(file.Bytes, body.Bytes()...)
+		block := &hclsyntax.Block{
+			Type:            name,
+			OpenBraceRange:  hcl.Range{End: hcl.Pos{Byte: start}},
+			CloseBraceRange: hcl.Range{Start: hcl.Pos{Byte: len(file.Bytes)}},
+		}
+		if labels != nil {
+			block.Labels = labels[i]
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// objectOfObjects reports whether val is a non-empty object or map whose
+// values are all objects or maps.
+func objectOfObjects(val cty.Value) bool {
+	typ := val.Type()
+	if val.IsNull() || !(typ.IsObjectType() || typ.IsMapType()) || val.LengthInt() == 0 {
+		return false
+	}
+	for _, element := range val.AsValueMap() {
+		t := element.Type()
+		if element.IsNull() || !(t.IsObjectType() || t.IsMapType()) {
+			return false
+		}
+	}
+	return true
+}
+
+// objectEntries returns the entries of the object of objects val in key
+// order, with the keys leading to them, nested up to depth levels.
+func objectEntries(val cty.Value, depth int) ([][]string, []cty.Value) {
+	var labels [][]string
+	var elements []cty.Value
+	entries := val.AsValueMap()
+	for _, k := range slices.Sorted(maps.Keys(entries)) {
+		if depth > 1 && objectOfObjects(entries[k]) {
+			inner, values := objectEntries(entries[k], depth-1)
+			for _, path := range inner {
+				labels = append(labels, append([]string{k}, path...))
+			}
+			elements = append(elements, values...)
+			continue
+		}
+		labels = append(labels, []string{k})
+		elements = append(elements, entries[k])
+	}
+	return labels, elements
+}
+
+// mapFieldLabels returns the number of labels of the map field tagged name
+// in fields: two for a [2]string key, one for another key, and zero if the
+// field is not a map.
+func mapFieldLabels(fields []reflect.StructField, name string) int {
+	for _, field := range fields {
+		if parseHCLTag(field.Tag)[0] != name {
+			continue
+		}
+		typ := field.Type
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		switch {
+		case typ.Kind() != reflect.Map:
+			return 0
+		case typ.Key().Kind() == reflect.Array && typ.Key().Len() == 2:
+			return 2
+		default:
+			return 1
+		}
+	}
+	return 0
+}
+
 // getBlockBytes extracts the content bytes and labels from an HCL block.
 // Returns the block body (content between braces) and the block's labels.
 //
@@ -492,6 +748,9 @@ func categorizeStructFields(structType reflect.Type, objectMap map[string]*schem
 			continue
 		}
 		tagModifier := parseHCLTag(field.Tag)[1]
+		if isCommentField(field) {
+			continue
+		}
 		if strings.ToLower(tagModifier) == tagModifierLabel {
 			categories.Labels = append(categories.Labels, field)
 			continue
@@ -499,6 +758,17 @@ func categorizeStructFields(structType reflect.Type, objectMap map[string]*schem
 		if tag == tagIgnore || (len(tag) >= 2 && tag[len(tag)-2:] == tagIgnoreSuffix) {
 			continue
 		}
+		if tag == "" && (!field.Anonymous || getDecodeState(ref).jsonName(field) != "") {
+			// untagged fields use the same name as marshal gives them
+			var ok bool
+			if tag, ok = getDecodeState(ref).fieldName(field); !ok {
+				continue
+			}
+			if slices.Contains(nullAttrs, tag) {
+				continue
+			}
+			field.Tag = reflect.StructTag(strings.TrimSpace(fmt.Sprintf(`hcl:"%s,%s" %s`, tag, tagModifierOptional, field.Tag)))
+		}
 		if _, ok := objectMap[name]; ok {
 			categories.BlockFields = append(categories.BlockFields, field)
 			continue
@@ -520,10 +790,21 @@ func categorizeStructFields(structType reflect.Type, objectMap map[string]*schem
 			continue
 		}
 
-		if fieldType.Kind() == reflect.Struct {
+		if isTextType(fieldType) {
+			// written as a string, see textValue
+			categories.SimpleFields = append(categories.SimpleFields, field)
+		} else if fieldType.Kind() == reflect.Struct && !isTimeType(fieldType) {
 			if err := handleStructField(field, fieldType, objectMap, ref, categories); err != nil {
 				return nil, err
 			}
+		} else if className, ok := defaultFor(fieldType, ref); ok {
+			// an interface with a default implementation, see RegisterDefault
+			valueSpec, err := schema.NewValue(className)
+			if err != nil {
+				return nil, err
+			}
+			objectMap[field.Name] = valueSpec
+			categories.BlockFields = append(categories.BlockFields, field)
 		} else if fieldType.Kind() == reflect.Map && fieldType.Key().Kind() == reflect.Array && fieldType.Key().Len() == 2 {
 			if err := handleMap2Field(field, fieldType, objectMap, ref, categories); err != nil {
 				return nil, err
@@ -553,6 +834,11 @@ func handleStructField(field reflect.StructField, fieldType reflect.Type, object
 
 func handleMap2Field(field reflect.StructField, fieldType reflect.Type, objectMap map[string]*schema.Value, ref map[string]any, categories *structFieldCategories) error {
 	elemType := fieldType.Elem()
+	// a map of slices of structs, e.g. map[string][]*Rule, collects the
+	// blocks sharing a label
+	if fieldType.Kind() == reflect.Map && isStructSlice(elemType) {
+		elemType = elemType.Elem()
+	}
 	typeName := elemType.String()
 
 	switch elemType.Kind() {
@@ -561,8 +847,12 @@ func handleMap2Field(field reflect.StructField, fieldType reflect.Type, objectMa
 	case reflect.Pointer:
 		ref[typeName] = reflect.New(elemType.Elem()).Interface()
 	case reflect.Interface:
-		categories.InterfaceFields = append(categories.InterfaceFields, field)
-		return nil
+		className, ok := defaultFor(elemType, ref)
+		if !ok {
+			categories.InterfaceFields = append(categories.InterfaceFields, field)
+			return nil
+		}
+		typeName = className
 	default:
 		categories.SimpleFields = append(categories.SimpleFields, field)
 		return nil
@@ -579,6 +869,11 @@ func handleMap2Field(field reflect.StructField, fieldType reflect.Type, objectMa
 
 func handleSliceOrMapField(field reflect.StructField, fieldType reflect.Type, objectMap map[string]*schema.Value, ref map[string]any, categories *structFieldCategories) error {
 	elemType := fieldType.Elem()
+	// a map of slices of structs, e.g. map[string][]*Rule, collects the
+	// blocks sharing a label
+	if fieldType.Kind() == reflect.Map && isStructSlice(elemType) {
+		elemType = elemType.Elem()
+	}
 	typeName := elemType.String()
 
 	switch elemType.Kind() {
@@ -587,8 +882,12 @@ func handleSliceOrMapField(field reflect.StructField, fieldType reflect.Type, ob
 	case reflect.Pointer:
 		ref[typeName] = reflect.New(elemType.Elem()).Interface()
 	case reflect.Interface:
-		categories.InterfaceFields = append(categories.InterfaceFields, field)
-		return nil
+		className, ok := defaultFor(elemType, ref)
+		if !ok {
+			categories.InterfaceFields = append(categories.InterfaceFields, field)
+			return nil
+		}
+		typeName = className
 	default:
 		categories.SimpleFields = append(categories.SimpleFields, field)
 		return nil
@@ -636,13 +935,32 @@ func getLabels(current any) (string, string) {
 		fieldValue := structValue.Field(i)
 		tagParts := parseHCLTag(field.Tag)
 		if strings.ToLower(tagParts[1]) == tagModifierLabel {
+			// a label of a type setLabel rejects is never set
+			label, _ := labelString(fieldValue)
 			if labelCount == 0 {
-				key0 = fieldValue.String()
+				key0 = label
 			} else {
-				key1 = fieldValue.String()
+				key1 = label
 			}
 			labelCount++
 		}
 	}
 	return key0, key1
 }
+
+// countLabelFields returns the number of label fields of the struct that
+// current points to, or 0 if current is not a pointer to struct.
+func countLabelFields(current any) int {
+	structType := reflect.TypeOf(current)
+	if structType == nil || structType.Kind() != reflect.Pointer || structType.Elem().Kind() != reflect.Struct {
+		return 0
+	}
+	structType = structType.Elem()
+	count := 0
+	for i := 0; i < structType.NumField(); i++ {
+		if strings.ToLower(parseHCLTag(structType.Field(i).Tag)[1]) == tagModifierLabel {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,63 @@
+package dethcl
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type benchRule struct {
+	Action string `hcl:"action,label"`
+	Zone   string `hcl:"zone,label"`
+	Port   int    `hcl:"port"`
+}
+
+type benchFirewall struct {
+	Rules []*benchRule `hcl:"rule,block"`
+}
+
+// newBenchRules returns a document of n rule blocks sharing a few long
+// labels
+func newBenchRules(n int) []byte {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteString(`rule "allow-inbound-from-load-balancer" "zone-` + strconv.Itoa(i%4) + `-private-subnets" {` + "\n")
+		sb.WriteString("  port = " + strconv.Itoa(8000+i) + "\n}\n")
+	}
+	return []byte(sb.String())
+}
+
+// Benchmark the memory kept by decoding 10k blocks with repeated labels,
+// with and without UnmarshalOptions.InternStrings. The retained-B/op metric
+// is the live heap held by the decoded value.
+func BenchmarkUnmarshalInternStrings(b *testing.B) {
+	data := newBenchRules(10000)
+	for _, intern := range []bool{false, true} {
+		name := "plain"
+		if intern {
+			name = "intern"
+		}
+		b.Run(name, func(b *testing.B) {
+			var retained int64
+			var stats runtime.MemStats
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				runtime.ReadMemStats(&stats)
+				before := stats.HeapAlloc
+
+				fw := new(benchFirewall)
+				if err := UnmarshalWithOptions(data, fw, UnmarshalOptions{InternStrings: intern}); err != nil {
+					b.Fatal(err)
+				}
+
+				runtime.GC()
+				runtime.ReadMemStats(&stats)
+				retained += int64(stats.HeapAlloc) - int64(before)
+				runtime.KeepAlive(fw)
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
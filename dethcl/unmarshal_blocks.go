@@ -1,8 +1,10 @@
 package dethcl
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/genelet/horizon/utils"
 	"github.com/OpenUdon/schema"
@@ -13,6 +15,8 @@ import (
 // processBlockFields handles complex block fields based on their spec type.
 // This includes Map2Struct, MapStruct, ListStruct, and SingleStruct.
 func processBlockFields(node *utils.Tree, file *hcl.File, ref map[string]any, oriFields []reflect.StructField, oriblock map[string][]*hclsyntax.Block, objectMap map[string]*schema.Value, oriTobe reflect.Value) error {
+	state := getDecodeState(ref)
+	var errs []error
 	for _, field := range oriFields {
 		tag := (parseHCLTag(field.Tag))[0]
 		blocks := oriblock[tag]
@@ -27,25 +31,23 @@ func processBlockFields(node *utils.Tree, file *hcl.File, ref map[string]any, or
 			continue
 		}
 
+		var err error
 		if x := result.GetMap2Struct(); x != nil {
-			if err := processMap2StructField(node, file, ref, field, blocks, x, oriTobe); err != nil {
-				return err
-			}
+			err = processMap2StructField(node, file, ref, field, blocks, x, oriTobe)
 		} else if x := result.GetMapStruct(); x != nil {
-			if err := processMapStructField(node, file, ref, field, blocks, x, oriTobe); err != nil {
-				return err
-			}
+			err = processMapStructField(node, file, ref, field, blocks, x, oriTobe)
 		} else if x := result.GetListStruct(); x != nil {
-			if err := processListStructField(node, file, ref, field, blocks, x, oriTobe); err != nil {
-				return err
-			}
+			err = processListStructField(node, file, ref, field, blocks, x, oriTobe)
 		} else if x := result.GetSingleStruct(); x != nil {
-			if err := processSingleStructField(node, file, ref, field, blocks[0], x, oriTobe); err != nil {
+			err = processSingleStructField(node, file, ref, field, blocks[0], x, oriTobe)
+		}
+		if err != nil {
+			if err = state.blockError(&errs, err); err != nil {
 				return err
 			}
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // processMap2StructField handles fields with Map2Struct spec (map with 2 labels).
@@ -53,6 +55,10 @@ func processMap2StructField(node *utils.Tree, file *hcl.File, ref map[string]any
 	name := field.Name
 	tag := (parseHCLTag(field.Tag))[0]
 	typ := field.Type
+	// treat ptr the same as the underlying type e.g. *map[[2]string]*Example
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
 
 	if typ.Kind() != reflect.Map {
 		return fmt.Errorf("field %s: expected map type for Map2Struct, got %v", name, typ.Kind())
@@ -71,7 +77,9 @@ func processMap2StructField(node *utils.Tree, file *hcl.File, ref map[string]any
 
 	n := len(blocks)
 	fMap := reflect.MakeMapWithSize(typ, n)
-	f := oriTobe.Elem().FieldByName(name)
+	f := fieldByName(oriTobe.Elem(), name)
+	state := getDecodeState(ref)
+	var errs []error
 
 	for k := 0; k < n; k++ {
 		block := blocks[k]
@@ -94,7 +102,7 @@ func processMap2StructField(node *utils.Tree, file *hcl.File, ref map[string]any
 			nextStruct = firstFirst
 		}
 
-		trial := ref[nextStruct.ClassName]
+		trial := lookupType(ref, nextStruct.ClassName)
 		if trial == nil {
 			return fmt.Errorf("field %s: struct type %q not found in ref map", name, nextStruct.ClassName)
 		}
@@ -105,12 +113,15 @@ func processMap2StructField(node *utils.Tree, file *hcl.File, ref map[string]any
 			return err
 		}
 		if len(lbls) > 2 {
-			return fmt.Errorf("field %s: Map2Struct supports maximum 2 labels, got %d", name, len(lbls))
+			return blockFieldError(subnode, fmt.Errorf("Map2Struct supports maximum 2 labels, got %d", len(lbls)))
 		}
 
 		err = tryUnmarshalWithCustom(subnode, s, trial, nextStruct, ref, lbls...)
 		if err != nil {
-			return fmt.Errorf("field %s[%s][%s]: unmarshal failed: %w", name, keystring0, keystring1, err)
+			if err = state.blockError(&errs, blockFieldError(subnode, err)); err != nil {
+				return err
+			}
+			continue
 		}
 
 		// Get labels from struct if not in HCL
@@ -125,7 +136,7 @@ func processMap2StructField(node *utils.Tree, file *hcl.File, ref map[string]any
 				keystring1 = key0
 			}
 		}
-		strKey := reflect.ValueOf([2]string{keystring0, keystring1})
+		strKey := reflect.ValueOf([2]string{state.intern(keystring0), state.intern(keystring1)})
 
 		knd := typ.Elem().Kind()
 		if knd == reflect.Interface || knd == reflect.Ptr {
@@ -134,8 +145,14 @@ func processMap2StructField(node *utils.Tree, file *hcl.File, ref map[string]any
 			fMap.SetMapIndex(strKey, reflect.ValueOf(trial).Elem())
 		}
 	}
-	f.Set(fMap)
-	return nil
+	if f.Kind() == reflect.Pointer {
+		ptr := reflect.New(typ)
+		ptr.Elem().Set(fMap)
+		f.Set(ptr)
+	} else {
+		f.Set(fMap)
+	}
+	return errors.Join(errs...)
 }
 
 // processMapStructField handles fields with MapStruct spec (map with 1 label).
@@ -157,19 +174,33 @@ func processMapStructField(node *utils.Tree, file *hcl.File, ref map[string]any,
 
 	n := len(blocks)
 	fMap := reflect.MakeMapWithSize(typ, n)
-	f := oriTobe.Elem().FieldByName(name)
+	f := fieldByName(oriTobe.Elem(), name)
+	state := getDecodeState(ref)
+	seen := make(map[string]string)
+	var errs []error
 
 	for k := 0; k < n; k++ {
 		block := blocks[k]
 		subnode := node.GetNode(tag, block.Labels...)
-		keystring := block.Labels[0]
+		// a block without label is the default entry, under the empty key
+		var label string
+		if len(block.Labels) > 0 {
+			label = block.Labels[0]
+		}
+		keystring, err := state.labelKey(label, seen)
+		if err != nil {
+			return blockFieldError(subnode, err)
+		}
 
-		nextStruct, ok := nextMapStructs[keystring]
+		nextStruct, ok := nextMapStructs[label]
+		if !ok {
+			nextStruct, ok = nextMapStructs[keystring]
+		}
 		if !ok {
 			nextStruct = first
 		}
 
-		trial := ref[nextStruct.ClassName]
+		trial := lookupType(ref, nextStruct.ClassName)
 		if trial == nil {
 			return fmt.Errorf("field %s: struct type %q not found in ref map", name, nextStruct.ClassName)
 		}
@@ -179,17 +210,24 @@ func processMapStructField(node *utils.Tree, file *hcl.File, ref map[string]any,
 		if err != nil {
 			return err
 		}
-		if len(lbls) > 1 {
-			return fmt.Errorf("field %s: MapStruct supports maximum 1 label, got %d", name, len(lbls))
+		structLabels := mapEntryLabels(trial, lbls)
+		if len(lbls) > 1 && len(structLabels) == len(lbls) {
+			return blockFieldError(subnode, fmt.Errorf("MapStruct supports maximum 1 label, got %d", len(lbls)))
 		}
 
-		err = tryUnmarshalWithCustom(subnode, s, trial, nextStruct, ref, lbls...)
+		err = tryUnmarshalWithCustom(subnode, s, trial, nextStruct, ref, structLabels...)
 		if err != nil {
-			return fmt.Errorf("field %s[%s]: unmarshal failed: %w", name, keystring, err)
+			if err = state.blockError(&errs, blockFieldError(subnode, err)); err != nil {
+				return err
+			}
+			continue
 		}
 
 		knd := typ.Elem().Kind()
-		strKey := reflect.ValueOf(keystring)
+		strKey, err := mapKeyValue(keystring, typ.Key())
+		if err != nil {
+			return blockFieldError(subnode, err)
+		}
 
 		if knd == reflect.Interface || knd == reflect.Ptr {
 			fMap.SetMapIndex(strKey, reflect.ValueOf(trial))
@@ -198,7 +236,22 @@ func processMapStructField(node *utils.Tree, file *hcl.File, ref map[string]any,
 		}
 	}
 	f.Set(fMap)
-	return nil
+	return errors.Join(errs...)
+}
+
+// mapEntryLabels returns the labels passed to the struct of a map[string]T
+// entry. The first block label is the map key. Marshal writes the struct's own
+// labels after the key, leaving out a first label equal to the key, so
+//
+//	service "web" "frontend" {}
+//
+// fills a single label field with "frontend", while service "web" {} fills it
+// with "web".
+func mapEntryLabels(trial any, labels []string) []string {
+	if len(labels) > 1 && len(labels)-1 == countLabelFields(trial) {
+		return labels[1:]
+	}
+	return labels
 }
 
 // processListStructField handles fields with ListStruct spec (slice or map without labels).
@@ -206,13 +259,18 @@ func processListStructField(node *utils.Tree, file *hcl.File, ref map[string]any
 	name := field.Name
 	tag := (parseHCLTag(field.Tag))[0]
 	typ := field.Type
-	f := oriTobe.Elem().FieldByName(name)
+	f := fieldByName(oriTobe.Elem(), name)
 
 	nextListStructs := listSpec.GetListFields()
 	nSmaller := len(nextListStructs)
 	first := nextListStructs[0]
 
 	n := len(blocks)
+	state := getDecodeState(ref)
+	seen := make(map[string]string)
+	var errs []error
+	// failed blocks are left out when decoding goes on
+	kept := 0
 
 	var fSlice, fMap reflect.Value
 	if typ.Kind() == reflect.Map {
@@ -230,7 +288,7 @@ func processListStructField(node *utils.Tree, file *hcl.File, ref map[string]any
 		block := blocks[k]
 		subnode := node.GetNode(tag, block.Labels...)
 
-		trial := ref[nextStruct.ClassName]
+		trial := lookupType(ref, nextStruct.ClassName)
 		if trial == nil {
 			return fmt.Errorf("field %s: struct type %q not found in ref map (list index %d)", name, nextStruct.ClassName, k)
 		}
@@ -241,44 +299,80 @@ func processListStructField(node *utils.Tree, file *hcl.File, ref map[string]any
 			return err
 		}
 
-		err = tryUnmarshalWithCustom(subnode, s, trial, nextStruct, ref, lbls...)
+		structLabels := lbls
+		if typ.Kind() == reflect.Map {
+			structLabels = mapEntryLabels(trial, lbls)
+		}
+		err = tryUnmarshalWithCustom(subnode, s, trial, nextStruct, ref, structLabels...)
 		if err != nil {
-			return fmt.Errorf("field %s[%d]: unmarshal failed: %w", name, k, err)
+			// unlabeled entries of a list are named by their index
+			var index []string
+			if len(block.Labels) == 0 && typ.Kind() != reflect.Map {
+				index = append(index, strconv.Itoa(k))
+			}
+			if err = state.blockError(&errs, blockFieldError(subnode, err, index...)); err != nil {
+				return err
+			}
+			continue
 		}
 
 		knd := typ.Elem().Kind()
 		if typ.Kind() == reflect.Map {
-			strKey := reflect.ValueOf(lbls[0])
-			if knd == reflect.Interface || knd == reflect.Ptr {
+			// a block without label is the default entry, under the empty key
+			var label string
+			if len(lbls) > 0 {
+				label = lbls[0]
+			}
+			keystring, err := state.labelKey(label, seen)
+			if err != nil {
+				return blockFieldError(subnode, err)
+			}
+			strKey, err := mapKeyValue(keystring, typ.Key())
+			if err != nil {
+				return blockFieldError(subnode, err)
+			}
+			if knd == reflect.Slice {
+				// blocks sharing a label are appended in order
+				item := reflect.ValueOf(trial)
+				if typ.Elem().Elem().Kind() != reflect.Ptr {
+					item = item.Elem()
+				}
+				list := fMap.MapIndex(strKey)
+				if !list.IsValid() {
+					list = reflect.MakeSlice(typ.Elem(), 0, 1)
+				}
+				fMap.SetMapIndex(strKey, reflect.Append(list, item))
+			} else if knd == reflect.Interface || knd == reflect.Ptr {
 				fMap.SetMapIndex(strKey, reflect.ValueOf(trial))
 			} else {
 				fMap.SetMapIndex(strKey, reflect.ValueOf(trial).Elem())
 			}
 		} else {
 			if knd == reflect.Interface || knd == reflect.Ptr {
-				fSlice.Index(k).Set(reflect.ValueOf(trial))
+				fSlice.Index(kept).Set(reflect.ValueOf(trial))
 			} else {
-				fSlice.Index(k).Set(reflect.ValueOf(trial).Elem())
+				fSlice.Index(kept).Set(reflect.ValueOf(trial).Elem())
 			}
 		}
+		kept++
 	}
 
 	if typ.Kind() == reflect.Map {
 		f.Set(fMap)
 	} else {
-		f.Set(fSlice)
+		f.Set(fSlice.Slice(0, kept))
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // processSingleStructField handles fields with SingleStruct spec (single nested struct).
 func processSingleStructField(node *utils.Tree, file *hcl.File, ref map[string]any, field reflect.StructField, block *hclsyntax.Block, singleSpec *schema.Struct, oriTobe reflect.Value) error {
 	name := field.Name
 	tag := (parseHCLTag(field.Tag))[0]
-	f := oriTobe.Elem().FieldByName(name)
+	f := fieldByName(oriTobe.Elem(), name)
 
 	subnode := node.GetNode(tag, block.Labels...)
-	trial := ref[singleSpec.ClassName]
+	trial := lookupType(ref, singleSpec.ClassName)
 	if trial == nil {
 		return fmt.Errorf("field %s: struct type %q not found in ref map", name, singleSpec.ClassName)
 	}
@@ -291,7 +385,7 @@ func processSingleStructField(node *utils.Tree, file *hcl.File, ref map[string]a
 
 	err = tryUnmarshalWithCustom(subnode, s, trial, singleSpec, ref, lbls...)
 	if err != nil {
-		return fmt.Errorf("field %s: unmarshal failed: %w", name, err)
+		return blockFieldError(subnode, err)
 	}
 
 	if f.Kind() == reflect.Interface || f.Kind() == reflect.Ptr {
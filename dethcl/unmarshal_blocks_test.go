@@ -2,6 +2,7 @@ package dethcl
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/OpenUdon/schema"
@@ -76,6 +77,64 @@ func TestUnmarshalMap2Struct(t *testing.T) {
 	}
 }
 
+// Test Map2Struct inferred from the field type, without a spec
+func TestUnmarshalMap2StructInferred(t *testing.T) {
+	type Service struct {
+		Kind string `hcl:"kind,label"`
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port,optional"`
+	}
+
+	type Config struct {
+		Services *map[[2]string]*Service `hcl:"service,block"`
+		Backups  map[[2]string]Service   `hcl:"backup,block"`
+	}
+
+	hclData := []byte(`
+		service "http" "api" {
+			port = 80
+		}
+		service "tcp" "db" {
+			port = 5432
+		}
+		backup "tcp" "db" {
+			port = 5433
+		}
+	`)
+
+	result := &Config{}
+	if err := Unmarshal(hclData, result); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if result.Services == nil || len(*result.Services) != 2 {
+		t.Fatalf("Services = %#v, want 2 entries", result.Services)
+	}
+	api := (*result.Services)[[2]string{"http", "api"}]
+	if api == nil || api.Kind != "http" || api.Name != "api" || api.Port != 80 {
+		t.Errorf("Services[http api] = %#v", api)
+	}
+	backup := result.Backups[[2]string{"tcp", "db"}]
+	if backup.Kind != "tcp" || backup.Name != "db" || backup.Port != 5433 {
+		t.Errorf("Backups[tcp db] = %#v", backup)
+	}
+
+	bs, err := Marshal(result)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if strings.Contains(string(bs), `"api" "api"`) {
+		t.Errorf("labels repeated in output:\n%s", bs)
+	}
+
+	again := &Config{}
+	if err := Unmarshal(bs, again); err != nil {
+		t.Fatalf("unmarshal of marshaled output failed: %v\n%s", err, bs)
+	}
+	if !reflect.DeepEqual(result, again) {
+		t.Errorf("round trip mismatch:\n%#v\n%#v", result, again)
+	}
+}
+
 // Test MapStruct - map with 1 label
 func TestUnmarshalMapStruct(t *testing.T) {
 	type Config struct {
@@ -334,3 +393,361 @@ func TestParseHCLTag(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalMapDefaultEntry(t *testing.T) {
+	type Service struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type Config struct {
+		Services map[string]*Service `hcl:"service,block"`
+	}
+	data := `
+service {
+  port = 80
+}
+service "api" {
+  port = 8080
+}
+`
+	c := new(Config)
+	if err := Unmarshal([]byte(data), c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Services) != 2 || c.Services[""].Port != 80 || c.Services["api"].Port != 8080 {
+		t.Fatalf("unexpected services: %#v", c.Services)
+	}
+
+	bs, err := Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), `""`) {
+		t.Errorf("default entry should have no label: %s", bs)
+	}
+	got := new(Config)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, got) {
+		t.Errorf("round trip: %#v", got.Services)
+	}
+
+	spec, err := schema.NewStruct("Config", map[string]any{
+		"Services": map[string]string{"api": "Service"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2 := new(Config)
+	if err := UnmarshalSpec([]byte(data), c2, spec, map[string]any{"Service": new(Service)}); err != nil {
+		t.Fatal(err)
+	}
+	if c2.Services[""].Port != 80 || c2.Services["api"].Port != 8080 {
+		t.Errorf("spec services: %#v", c2.Services)
+	}
+}
+
+// Test MapStruct whose entries are pointers to structs with an interface field,
+// each entry resolved by its own nested spec
+func TestUnmarshalMapStructWithInterface(t *testing.T) {
+	type Place struct {
+		Title string `hcl:"title"`
+		Shape inter  `hcl:"shape,block"`
+	}
+
+	type Atlas struct {
+		Places map[string]*Place `hcl:"place,block"`
+	}
+
+	hclData := []byte(`
+		place "home" {
+			title = "Home"
+			shape {
+				radius = 2
+			}
+		}
+		place "office" {
+			title = "Office"
+			shape {
+				sx = 3
+				sy = 4
+			}
+		}
+	`)
+
+	spec, err := schema.NewStruct("Atlas", map[string]any{
+		"Places": map[string][2]any{
+			"home":   {"Place", map[string]any{"Shape": "circle"}},
+			"office": {"Place", map[string]any{"Shape": "square"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create spec: %v", err)
+	}
+	ref := map[string]any{
+		"Place":  new(Place),
+		"circle": new(circle),
+		"square": new(square),
+	}
+
+	result := &Atlas{}
+	if err := UnmarshalSpec(hclData, result, spec, ref); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	expected := &Atlas{Places: map[string]*Place{
+		"home":   {Title: "Home", Shape: &circle{Radius: 2}},
+		"office": {Title: "Office", Shape: &square{SX: 3, SY: 4}},
+	}}
+	if !reflect.DeepEqual(result, expected) {
+		for k, v := range result.Places {
+			t.Logf("%s: %#v %#v", k, v, v.Shape)
+		}
+		t.Errorf("unexpected result")
+	}
+}
+
+// Test ListStruct on interface slices given as a tuple of objects, where the
+// spec gives each index its own concrete type
+func TestUnmarshalListStructTuple(t *testing.T) {
+	type Box struct {
+		Items  []any   `hcl:"items"`
+		Shapes []inter `hcl:"shapes"`
+	}
+
+	hclData := []byte(`
+		items  = [{ radius = 1 }, { sx = 2, sy = 3 }]
+		shapes = [
+			{ sx = 4, sy = 5 },
+			{ radius = 6 },
+		]
+	`)
+
+	spec, err := schema.NewStruct("Box", map[string]any{
+		"Items":  []string{"circle", "square"},
+		"Shapes": []string{"square", "circle"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create spec: %v", err)
+	}
+	ref := map[string]any{"circle": new(circle), "square": new(square)}
+
+	result := &Box{}
+	if err := UnmarshalSpec(hclData, result, spec, ref); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	expected := &Box{
+		Items:  []any{&circle{Radius: 1}, &square{SX: 2, SY: 3}},
+		Shapes: []inter{&square{SX: 4, SY: 5}, &circle{Radius: 6}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("got %#v", result)
+	}
+
+	// the marshaled blocks decode to the same values
+	bs, err := Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := &Box{}
+	if err := UnmarshalSpec(bs, back, spec, ref); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(back, expected) {
+		t.Errorf("got %#v from\n%s", back, bs)
+	}
+}
+
+// Test a list of objects holding nested objects and braces in strings,
+// given for a slice of structs with map fields. The list is turned into
+// blocks by objectBlocks, from its parsed values; the brace-matching regex it
+// replaced cut such objects short.
+func TestUnmarshalListStructNestedObjects(t *testing.T) {
+	type Item struct {
+		Name string                       `hcl:"name"`
+		Tags map[string]string            `hcl:"tags"`
+		Deep map[string]map[string]string `hcl:"deep,optional"`
+	}
+	type Config struct {
+		Items []*Item `hcl:"items,block"`
+	}
+	hclData := []byte(`
+		items = [
+			{ name = "a", tags = { k = "}" }, deep = { x = { y = "{" } } },
+			{ name = "b", tags = {} },
+		]
+	`)
+	expected := &Config{Items: []*Item{
+		{Name: "a", Tags: map[string]string{"k": "}"}, Deep: map[string]map[string]string{"x": {"y": "{"}}},
+		{Name: "b", Tags: map[string]string{}},
+	}}
+
+	result := &Config{}
+	if err := Unmarshal(hclData, result); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("got %#v", result)
+	}
+
+	bs, err := Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := &Config{}
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(back, expected) {
+		t.Errorf("got %#v from\n%s", back, bs)
+	}
+}
+
+// Test blocks written on a single line, including compact input without
+// spaces or a final newline, and the equals-sign list of objects form
+func TestUnmarshalSingleLineBlocks(t *testing.T) {
+	type Listener struct {
+		Port int    `hcl:"port"`
+		Host string `hcl:"host,optional"`
+	}
+	type Named struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type Config struct {
+		Service *Listener         `hcl:"service,block"`
+		Named   map[string]*Named `hcl:"named,block"`
+		Items   []*Listener       `hcl:"items,block"`
+		Shape   inter             `hcl:"shape,block"`
+		Empty   *Listener         `hcl:"empty,block"`
+	}
+	expected := &Config{
+		Service: &Listener{Port: 8080},
+		Named:   map[string]*Named{"a": {Name: "a", Port: 1}},
+		Items:   []*Listener{{Port: 2}, {Port: 3, Host: "h"}},
+		Shape:   &circle{Radius: 2},
+		Empty:   &Listener{},
+	}
+
+	spec, err := schema.NewStruct("Config", map[string]any{"Shape": "circle"})
+	if err != nil {
+		t.Fatalf("failed to create spec: %v", err)
+	}
+	ref := map[string]any{"circle": new(circle)}
+
+	tests := map[string]string{
+		"spaced": `service { port = 8080 }
+named "a" { port = 1 }
+items = [{ port = 2 }, { port = 3, host = "h" }]
+shape { radius = 2 }
+empty {}
+`,
+		"compact": `service {port=8080}
+named "a" {port=1}
+items=[{port=2},{port=3,host="h"}]
+shape {radius=2}
+empty {}`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := &Config{}
+			if err := UnmarshalSpec([]byte(data), result, spec, ref); err != nil {
+				t.Fatalf("unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("got %#v", result)
+			}
+		})
+	}
+
+	var m map[string]any
+	if err := Unmarshal([]byte(`service {port=8080}`), &m); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(m, map[string]any{"service": map[string]any{"port": 8080}}) {
+		t.Errorf("got %#v", m)
+	}
+}
+
+// Test that an absent pointer block stays nil while a present but empty block
+// is allocated, on the plain, spec and map paths, and that Marshal keeps the
+// difference
+func TestUnmarshalEmptyPointerBlocks(t *testing.T) {
+	type Limits struct {
+		Max int `hcl:"max,optional"`
+	}
+	type Config struct {
+		Name    string             `hcl:"name,optional"`
+		Limits  *Limits            `hcl:"limits,block"`
+		Backup  *Limits            `hcl:"backup,block"`
+		Shape   inter              `hcl:"shape,block"`
+		Tenants map[string]*Limits `hcl:"tenant,block"`
+	}
+	spec, err := schema.NewStruct("Config", map[string]any{"Shape": "circle"})
+	if err != nil {
+		t.Fatalf("failed to create spec: %v", err)
+	}
+	ref := map[string]any{"circle": new(circle)}
+
+	result := &Config{}
+	hclData := []byte(`
+		name = "app"
+		limits {}
+		shape {
+		}
+		tenant "a" {}
+	`)
+	if err := UnmarshalSpec(hclData, result, spec, ref); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	expected := &Config{
+		Name:    "app",
+		Limits:  &Limits{},
+		Shape:   &circle{},
+		Tenants: map[string]*Limits{"a": {}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("got %#v", result)
+	}
+
+	bs, err := Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "limits {\n  }") || strings.Contains(string(bs), "backup") {
+		t.Errorf("unexpected output:\n%s", bs)
+	}
+	back := &Config{}
+	if err := UnmarshalSpec(bs, back, spec, ref); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(back, expected) {
+		t.Errorf("got %#v from\n%s", back, bs)
+	}
+}
+
+// Test that decoding the equals-sign form leaves the memory past the end of
+// the data alone, e.g. the rest of a larger buffer
+func TestUnmarshalObjectBlocksSubSlice(t *testing.T) {
+	type Item struct {
+		Name string `hcl:"name"`
+	}
+	type Config struct {
+		Items []*Item `hcl:"items,block"`
+	}
+	data := `items = [{ name = "a" }, { name = "b" }]`
+	tail := strings.Repeat("#", 256)
+	all := []byte(data + tail)
+
+	result := &Config{}
+	if err := Unmarshal(all[:len(data)], result); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if len(result.Items) != 2 || result.Items[0].Name != "a" || result.Items[1].Name != "b" {
+		t.Errorf("got %#v", result.Items)
+	}
+	if got := string(all[len(data):]); got != tail {
+		t.Errorf("tail overwritten: %q", got)
+	}
+}
@@ -3,10 +3,14 @@ package dethcl
 import (
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/genelet/horizon/utils"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 )
 
 // unmarshalToMap handles unmarshaling HCL data to a map[string]any.
@@ -15,20 +19,21 @@ import (
 // The function decodes HCL into a nested map structure where:
 //   - Attributes become map entries with their values
 //   - Blocks without labels become nested maps
-//   - Blocks with labels become map[label]value
+//   - Blocks with labels become map[label]value, nested once per label
 //
 // Parameters:
 //   - node: tree node for variable scope
 //   - dat: HCL data bytes
 //   - current: pointer to map[string]any to populate
+//   - ref: registry carrying the decode state, may be nil
 //
 // Returns error if parsing or decoding fails.
-func unmarshalToMap(node *utils.Tree, dat []byte, current any) error {
-	obj, err := decodeMap(nil, node, dat)
+func unmarshalToMap(node *utils.Tree, dat []byte, current any, ref map[string]any) error {
+	obj, err := decodeMap(ref, node, dat)
 	if err != nil {
 		return err
 	}
-	x, ok := current.(*map[string]any)
+	x, ok := pointerAs[map[string]any](current)
 	if !ok {
 		return fmt.Errorf("expected *map[string]any, got %T", current)
 	}
@@ -54,14 +59,15 @@ func unmarshalToMap(node *utils.Tree, dat []byte, current any) error {
 //   - node: tree node for variable scope
 //   - dat: HCL data bytes (should be array syntax)
 //   - current: pointer to []any to append to
+//   - ref: registry carrying the decode state, may be nil
 //
 // Returns error if parsing or decoding fails.
-func unmarshalToSlice(node *utils.Tree, dat []byte, current any) error {
-	obj, err := decodeSlice(nil, node, dat)
+func unmarshalToSlice(node *utils.Tree, dat []byte, current any, ref map[string]any) error {
+	obj, err := decodeSlice(ref, node, dat)
 	if err != nil {
 		return err
 	}
-	x, ok := current.(*[]any)
+	x, ok := pointerAs[[]any](current)
 	if !ok {
 		return fmt.Errorf("expected *[]any, got %T", current)
 	}
@@ -69,6 +75,20 @@ func unmarshalToSlice(node *utils.Tree, dat []byte, current any) error {
 	return nil
 }
 
+// pointerAs returns current as a *T, also if it points to a named type of
+// the same underlying type, e.g. *Doc for type Doc map[string]any.
+func pointerAs[T any](current any) (*T, bool) {
+	if x, ok := current.(*T); ok {
+		return x, true
+	}
+	rv := reflect.ValueOf(current)
+	target := reflect.TypeOf((*T)(nil))
+	if rv.Kind() != reflect.Pointer || !rv.Type().ConvertibleTo(target) {
+		return nil, false
+	}
+	return rv.Convert(target).Interface().(*T), true
+}
+
 // parseHCLFile parses raw HCL bytes into an AST (abstract syntax tree).
 // This is the first step in unmarshaling, converting HCL text into structured data.
 //
@@ -84,12 +104,63 @@ func unmarshalToSlice(node *utils.Tree, dat []byte, current any) error {
 func parseHCLFile(dat []byte) (*hcl.File, *hclsyntax.Body, error) {
 	file, diags := hclsyntax.ParseConfig(dat, generateTempHCLFileName(), hcl.Pos{Line: 1, Column: 1})
 	if diags.HasErrors() {
-		return nil, nil, fmt.Errorf("failed to parse HCL: %w", diags)
+		return nil, nil, newDethclError("failed to parse HCL", diags, dat)
 	}
 	bd := file.Body.(*hclsyntax.Body)
 	return file, bd, nil
 }
 
+// repeatedAttributes replaces the blocks of each field of structType tagged
+// repeated by a list attribute of their values, so that
+//
+//	tag { value = "a" }
+//	tag { value = "b" }
+//
+// is decoded like tag = ["a", "b"]. It fails if the body has both forms.
+func repeatedAttributes(structType reflect.Type, bd *hclsyntax.Body) error {
+	for i := 0; i < structType.NumField(); i++ {
+		tagParts := parseHCLTag(structType.Field(i).Tag)
+		if !hasTagOption(tagParts[1], tagModifierRepeated) {
+			continue
+		}
+		name := tagParts[0]
+		var exprs []hclsyntax.Expression
+		var srcRange hcl.Range
+		blocks := make([]*hclsyntax.Block, 0, len(bd.Blocks))
+		for _, block := range bd.Blocks {
+			if block.Type != name {
+				blocks = append(blocks, block)
+				continue
+			}
+			attr, ok := block.Body.Attributes[repeatedValueAttr]
+			if !ok || len(block.Labels) > 0 || len(block.Body.Attributes) != 1 || len(block.Body.Blocks) > 0 {
+				return fmt.Errorf("block %s at %s: expected only a %s attribute", name, block.DefRange(), repeatedValueAttr)
+			}
+			if exprs == nil {
+				srcRange = block.Range()
+			}
+			exprs = append(exprs, attr.Expr)
+			srcRange = hcl.RangeOver(srcRange, block.Range())
+		}
+		if exprs == nil {
+			continue
+		}
+		if _, ok := bd.Attributes[name]; ok {
+			return fmt.Errorf("%s is given both as attribute and as blocks", name)
+		}
+		bd.Blocks = blocks
+		if bd.Attributes == nil {
+			bd.Attributes = make(hclsyntax.Attributes)
+		}
+		bd.Attributes[name] = &hclsyntax.Attribute{
+			Name:     name,
+			Expr:     &hclsyntax.TupleConsExpr{Exprs: exprs, SrcRange: srcRange},
+			SrcRange: srcRange,
+		}
+	}
+	return nil
+}
+
 // evaluateExpressions evaluates HCL expressions and converts them to concrete values.
 // This handles HCL's expression evaluation including variables, functions, and references.
 //
@@ -119,7 +190,7 @@ func evaluateExpressions(ref map[string]any, node *utils.Tree, file *hcl.File, b
 	for k, v := range bd.Attributes {
 		cv, err := utils.ExpressionToCty(ref, node, v.Expr)
 		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate expression for %q: %w", k, err)
+			return nil, newFieldError(node, k, fmt.Errorf("failed to evaluate expression for %q: %w", k, err))
 		}
 		if cv.IsNull() {
 			kNulls = append(kNulls, k)
@@ -145,7 +216,6 @@ func processLabels(labelFields []reflect.StructField, oriTobe reflect.Value, lab
 	if labelExprs != nil {
 		for _, field := range labelFields {
 			name := field.Name
-			f := oriTobe.Elem().FieldByName(name)
 			tag := (parseHCLTag(field.Tag))[0]
 			expr, ok := labelExprs[tag]
 			if ok {
@@ -153,8 +223,14 @@ func processLabels(labelFields []reflect.StructField, oriTobe reflect.Value, lab
 				if diags.HasErrors() {
 					return fmt.Errorf("failed to evaluate label %q: %w", tag, diags)
 				}
-				label := cv.AsString()
-				f.Set(reflect.ValueOf(label))
+				// a number, e.g. port = 443, is parsed from its text
+				cv, err := convert.Convert(cv, cty.String)
+				if err != nil || cv.IsNull() {
+					return fmt.Errorf("label %q must be a string or a number", tag)
+				}
+				if err := setLabel(fieldByName(oriTobe.Elem(), name), cv.AsString()); err != nil {
+					return fmt.Errorf("field %s: %w", name, err)
+				}
 			}
 		}
 	}
@@ -163,10 +239,11 @@ func processLabels(labelFields []reflect.StructField, oriTobe reflect.Value, lab
 	if labels != nil && labelFields != nil && len(labels) == len(labelFields) {
 		for i, field := range labelFields {
 			name := field.Name
-			f := oriTobe.Elem().FieldByName(name)
-			if f.String() == "" {
-				label := labels[i]
-				f.Set(reflect.ValueOf(label))
+			f := fieldByName(oriTobe.Elem(), name)
+			if f.IsZero() {
+				if err := setLabel(f, labels[i]); err != nil {
+					return fmt.Errorf("field %s: %w", name, err)
+				}
 			}
 		}
 	}
@@ -174,18 +251,72 @@ func processLabels(labelFields []reflect.StructField, oriTobe reflect.Value, lab
 }
 
 // processSimpleFields copies simple field values from the decoded struct to the target.
+// String fields tagged with the "trim" modifier, e.g. `hcl:"body,trim"`, have
+// their surrounding whitespace removed. The hclprefix and hclsuffix tags of a
+// string field are stripped from its value; a value without them is kept as
+// written.
 func processSimpleFields(newFields []reflect.StructField, rawValue reflect.Value, oriTobe reflect.Value, existingAttrs map[string]bool) {
 	for i, field := range newFields {
 		name := field.Name
-		tag := (parseHCLTag(field.Tag))[0]
-		if _, ok := existingAttrs[tag]; ok {
+		tagParts := parseHCLTag(field.Tag)
+		if _, ok := existingAttrs[tagParts[0]]; ok {
 			rawField := rawValue.Field(i)
-			f := oriTobe.Elem().FieldByName(name)
+			if rawField.Kind() == reflect.String && hasTagOption(tagParts[1], tagModifierTrim) {
+				rawField = reflect.ValueOf(strings.TrimSpace(rawField.String())).Convert(field.Type)
+			}
+			if prefix, suffix := stringAffixes(field); prefix != "" || suffix != "" {
+				str := rawField.String()
+				if strings.HasPrefix(str, prefix) && strings.HasSuffix(str[len(prefix):], suffix) {
+					str = str[len(prefix) : len(str)-len(suffix)]
+				}
+				rawField = reflect.ValueOf(str).Convert(field.Type)
+			}
+			f := fieldByName(oriTobe.Elem(), name)
 			f.Set(rawField)
 		}
 	}
 }
 
+// fieldByName returns the field of the struct v with the given name, like
+// v.FieldByName, but allocates the nil embedded pointers on the way to it,
+// e.g. the *Metadata embedded in a struct when its Owner field is set.
+// Embedded pointers stay nil unless one of their fields is decoded.
+func fieldByName(v reflect.Value, name string) reflect.Value {
+	sf, ok := v.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}
+	}
+	for i, x := range sf.Index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// processNullFields sets pointer and interface fields whose attribute is null
+// to nil, so that `x = null` decodes the same way the explicitnull modifier
+// encodes a nil pointer.
+func processNullFields(structType reflect.Type, oriTobe reflect.Value, nullAttrs []string, state *decodeState) {
+	if len(nullAttrs) == 0 {
+		return
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Anonymous || (field.Type.Kind() != reflect.Pointer && field.Type.Kind() != reflect.Interface) || !field.IsExported() {
+			continue
+		}
+		if name, ok := state.fieldName(field); ok && slices.Contains(nullAttrs, name) {
+			f := oriTobe.Elem().Field(i)
+			f.Set(reflect.Zero(field.Type))
+		}
+	}
+}
+
 // processMapOrSliceFields handles dynamic interface fields (map[string]any and []any).
 // These fields can contain any HCL structure and are decoded into generic Go types.
 //
@@ -234,13 +365,23 @@ func processMapOrSliceFields(ref map[string]interface{}, node *utils.Tree, file
 
 		name := field.Name
 		typ := field.Type
-		f := oriTobe.Elem().FieldByName(name)
+		f := fieldByName(oriTobe.Elem(), name)
 		if typ.Kind() == reflect.Slice {
 			obj, err := decodeSlice(ref, node, bs)
 			if err != nil {
 				return fmt.Errorf("field %s: failed to decode slice: %w", name, err)
 			}
 			f.Set(reflect.ValueOf(obj))
+		} else if keyAttr, ok := tagOptionValue(parseHCLTag(field.Tag)[1], tagOptionMapKey); ok && isTupleAttr(decattrs[tag]) {
+			list, err := decodeSlice(ref, node, bs)
+			if err != nil {
+				return fmt.Errorf("field %s: failed to decode slice: %w", name, err)
+			}
+			obj, err := sliceToMap(list, keyAttr)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+			f.Set(reflect.ValueOf(obj))
 		} else {
 			obj, err := decodeMap(ref, node, bs)
 			if err != nil {
@@ -251,3 +392,124 @@ func processMapOrSliceFields(ref map[string]interface{}, node *utils.Tree, file
 	}
 	return nil
 }
+
+// isTupleAttr reports whether the attribute is a list, e.g. entries = [...].
+func isTupleAttr(attr *hclsyntax.Attribute) bool {
+	if attr == nil {
+		return false
+	}
+	switch t := attr.Expr.(type) {
+	case *hclsyntax.TupleConsExpr:
+		return true
+	case *hclsyntax.LiteralValueExpr:
+		return t.Val.Type().IsTupleType() || t.Val.Type().IsListType()
+	default:
+		return false
+	}
+}
+
+// listToMap converts a list of objects into an object keyed by the keyAttr
+// attribute of each element, so that it can be decoded into the map type typ.
+// The key attribute is removed from each element; if a single attribute is
+// left, that attribute becomes the value as set by mapKeyUnwrap.
+//
+// Example: with keyAttr "key" and typ map[string]int,
+//
+//	[{ key = "a", value = 1 }, { key = "b", value = 2 }]
+//
+// becomes { a = 1, b = 2 }. Values which are not lists are returned unchanged.
+func listToMap(val cty.Value, keyAttr string, typ reflect.Type) (cty.Value, error) {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if val.IsNull() || !(val.Type().IsTupleType() || val.Type().IsListType()) {
+		return val, nil
+	}
+	unwrap := mapKeyUnwrap(typ)
+
+	result := make(map[string]cty.Value)
+	for it := val.ElementIterator(); it.Next(); {
+		idx, item := it.Element()
+		if item.IsNull() || !(item.Type().IsObjectType() || item.Type().IsMapType()) {
+			return cty.NilVal, fmt.Errorf("element %s is not an object", idx.AsBigFloat().String())
+		}
+		rest := make(map[string]cty.Value)
+		var key cty.Value
+		for k, v := range item.AsValueMap() {
+			if k == keyAttr {
+				key = v
+			} else {
+				rest[k] = v
+			}
+		}
+		if key == cty.NilVal || key.IsNull() {
+			return cty.NilVal, fmt.Errorf("element %s has no key attribute %q", idx.AsBigFloat().String(), keyAttr)
+		}
+		key, err := convert.Convert(key, cty.String)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("element %s: key attribute %q: %w", idx.AsBigFloat().String(), keyAttr, err)
+		}
+		name := key.AsString()
+		if _, ok := result[name]; ok {
+			return cty.NilVal, fmt.Errorf("duplicate key %q", name)
+		}
+		if unwrap && len(rest) == 1 {
+			for _, v := range rest {
+				result[name] = v
+			}
+		} else {
+			result[name] = cty.ObjectVal(rest)
+		}
+	}
+	return cty.ObjectVal(result), nil
+}
+
+// mapKeyUnwrap reports whether an element of a mapkey list whose only
+// attribute left is its value, e.g. { key = "a", value = 1 }, is decoded as
+// that value into the map type typ. It is, unless the map holds maps or
+// structs, whose fields are the attributes. A map[string]any gets the value,
+// 1, like a map[string]int; an element with more attributes stays an object.
+func mapKeyUnwrap(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Map {
+		switch typ.Elem().Kind() {
+		case reflect.Map, reflect.Struct:
+			return false
+		default:
+		}
+	}
+	return true
+}
+
+// sliceToMap is the native counterpart of listToMap for map[string]any fields.
+func sliceToMap(list []any, keyAttr string) (map[string]any, error) {
+	unwrap := mapKeyUnwrap(reflect.TypeOf(map[string]any(nil)))
+	result := make(map[string]any)
+	for i, item := range list {
+		object, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("element %d is not an object", i)
+		}
+		key, ok := object[keyAttr]
+		if !ok || key == nil {
+			return nil, fmt.Errorf("element %d has no key attribute %q", i, keyAttr)
+		}
+		name := fmt.Sprintf("%v", key)
+		if _, ok := result[name]; ok {
+			return nil, fmt.Errorf("duplicate key %q", name)
+		}
+		rest := make(map[string]any)
+		for k, v := range object {
+			if k != keyAttr {
+				rest[k] = v
+			}
+		}
+		if unwrap && len(rest) == 1 {
+			for _, v := range rest {
+				result[name] = v
+			}
+		} else {
+			result[name] = rest
+		}
+	}
+	return result, nil
+}
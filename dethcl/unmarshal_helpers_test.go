@@ -2,6 +2,7 @@ package dethcl
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/genelet/horizon/utils"
@@ -211,7 +212,7 @@ func TestUnmarshalToMap(t *testing.T) {
 	result := make(map[string]any)
 	node := utils.NewEvalContext(nil)
 
-	err := unmarshalToMap(node, hclData, &result)
+	err := unmarshalToMap(node, hclData, &result, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -250,7 +251,7 @@ func TestUnmarshalToSlice(t *testing.T) {
 	result := make([]any, 0)
 	node := utils.NewEvalContext(nil)
 
-	err := unmarshalToSlice(node, hclData, &result)
+	err := unmarshalToSlice(node, hclData, &result, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -332,3 +333,126 @@ func TestProcessLabels(t *testing.T) {
 		}
 	})
 }
+
+type Metadata struct {
+	Owner  string  `hcl:"owner,optional"`
+	Tier   int     `hcl:"tier,optional"`
+	Bounds *square `hcl:"bounds,block"`
+}
+
+type resource struct {
+	*Metadata
+	Name string `hcl:"name"`
+}
+
+func TestUnmarshalEmbeddedPointer(t *testing.T) {
+	res := &resource{
+		Metadata: &Metadata{Owner: "ops", Tier: 2, Bounds: &square{SX: 3, SY: 4}},
+		Name:     "api",
+	}
+	bs, err := Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(resource)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, res) {
+		t.Errorf("got %#v, %#v\nfrom %s", got, got.Metadata, bs)
+	}
+
+	// without its fields, the embedded pointer stays nil
+	got = new(resource)
+	if err := Unmarshal([]byte(`name = "api"`), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Metadata != nil || got.Name != "api" {
+		t.Errorf("got %#v", got)
+	}
+
+	// a block alone allocates it too
+	got = new(resource)
+	if err := Unmarshal([]byte("name = \"api\"\nbounds {\n  sx = 1\n  sy = 2\n}\n"), got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Metadata == nil || got.Bounds == nil || got.Bounds.SX != 1 || got.Owner != "" {
+		t.Errorf("got %#v", got)
+	}
+}
+
+type regionName string
+
+type labeledListener struct {
+	Port  int    `hcl:"port,label"`
+	Proto string `hcl:"proto"`
+}
+
+type labeledZone struct {
+	Region regionName `hcl:"region,label"`
+	Size   int        `hcl:"size"`
+}
+
+type labeledLogger struct {
+	Level logLevel `hcl:"level,label"`
+	Path  string   `hcl:"path"`
+}
+
+func TestLabelTypes(t *testing.T) {
+	type config struct {
+		Listeners []*labeledListener       `hcl:"listener,block"`
+		ByPort    map[int]*labeledListener `hcl:"port,block"`
+		Zone      *labeledZone             `hcl:"zone,block"`
+		Loggers   []*labeledLogger         `hcl:"logger,block"`
+	}
+	cfg := &config{
+		Listeners: []*labeledListener{{Port: 443, Proto: "https"}},
+		ByPort:    map[int]*labeledListener{80: {Port: 80, Proto: "http"}},
+		Zone:      &labeledZone{Region: "eu-west", Size: 3},
+		Loggers:   []*labeledLogger{{Level: levelWarn, Path: "/var/log/warn"}},
+	}
+	bs, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`listener "443" {`, `port "80" {`, `zone "eu-west" {`, `logger "warn" {`} {
+		if !strings.Contains(string(bs), want) {
+			t.Errorf("missing %s in\n%s", want, bs)
+		}
+	}
+	got := new(config)
+	if err := Unmarshal(bs, got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, cfg) {
+		t.Errorf("got %#v", got)
+	}
+
+	// an enum label is also read from its number
+	loggers := new(config)
+	if err := Unmarshal([]byte("logger \"1\" {\n  path = \"/tmp\"\n}\n"), loggers); err != nil {
+		t.Fatal(err)
+	}
+	if loggers.Loggers[0].Level != levelInfo {
+		t.Errorf("got level %v", loggers.Loggers[0].Level)
+	}
+
+	err = Unmarshal([]byte("listener \"https\" {\n  proto = \"https\"\n}\n"), new(config))
+	if err == nil || !strings.Contains(err.Error(), `label "https" is not a valid int`) {
+		t.Errorf("expected an invalid label error, got %v", err)
+	}
+
+	type gauge struct {
+		Value float64 `hcl:"value,label"`
+	}
+	type gauges struct {
+		Gauges []*gauge `hcl:"gauge,block"`
+	}
+	want := "label field must be a string or an integer, got float64"
+	if _, err := Marshal(&gauges{Gauges: []*gauge{{Value: 1.5}}}); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("marshal: expected %q, got %v", want, err)
+	}
+	if err := Unmarshal([]byte("gauge \"1.5\" {\n}\n"), new(gauges)); err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("unmarshal: expected %q, got %v", want, err)
+	}
+}
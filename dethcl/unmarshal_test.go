@@ -1,11 +1,14 @@
 package dethcl
 
 import (
+	"math"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/OpenUdon/schema"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
 )
 
 func TestHclSimple(t *testing.T) {
@@ -507,10 +510,11 @@ brand = {
 	if err == nil {
 		err = UnmarshalSpec([]byte(data1), c, spec, ref)
 	}
-	str := err.Error()
-	expected := `Missing expression; Expected the start of an expression, but found the end of the file.`
-	if !strings.HasSuffix(str, expected) {
-		t.Errorf("%v", err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Age != 5 || c.Brand.ToyName != "roblox" || c.Brand.Geo.Shape.(*circle).Radius != 1.234 {
+		t.Errorf("%#v", c)
 	}
 }
 
@@ -645,3 +649,675 @@ func TestMapList(t *testing.T) {
 		t.Errorf("%#v", xc.Circles["k6"])
 	}
 }
+
+func TestHclTrim(t *testing.T) {
+	type note struct {
+		Title string `hcl:"title,trim"`
+		Body  string `hcl:"body,optional,trim"`
+		Raw   string `hcl:"raw"`
+		Count int    `hcl:"count,trim"`
+	}
+	data := `
+title = "  hello  "
+body = <<EOT
+    first line
+    second line
+
+EOT
+raw = "  kept  "
+count = 3
+`
+	n := new(note)
+	if err := Unmarshal([]byte(data), n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Title != "hello" {
+		t.Errorf("Title = %q, want %q", n.Title, "hello")
+	}
+	if n.Body != "first line\n    second line" {
+		t.Errorf("Body = %q", n.Body)
+	}
+	if n.Raw != "  kept  " {
+		t.Errorf("Raw = %q, want untrimmed", n.Raw)
+	}
+	if n.Count != 3 {
+		t.Errorf("Count = %d, want 3", n.Count)
+	}
+
+	bs, err := Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), `title = "hello"`) {
+		t.Errorf("unexpected marshal output: %s", bs)
+	}
+}
+
+func TestHclMapKey(t *testing.T) {
+	type ingest struct {
+		Entries map[string]int    `hcl:"entries,mapkey=key"`
+		Labels  map[string]string `hcl:"labels,optional,mapkey=name"`
+		Extra   map[string]any    `hcl:"extra,mapkey=id"`
+	}
+	data := `
+entries = [
+  { key = "a", value = 1 },
+  { key = "b", value = 2 },
+]
+labels = { env = "prod" }
+extra = [
+  { id = "x", size = 3, color = "red" },
+]
+`
+	g := new(ingest)
+	if err := Unmarshal([]byte(data), g); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g.Entries, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("Entries = %#v", g.Entries)
+	}
+	if !reflect.DeepEqual(g.Labels, map[string]string{"env": "prod"}) {
+		t.Errorf("Labels = %#v", g.Labels)
+	}
+	x, ok := g.Extra["x"].(map[string]any)
+	if !ok || x["color"] != "red" || len(x) != 2 {
+		t.Errorf("Extra = %#v", g.Extra)
+	}
+
+	// an element left with one attribute is its value, whatever the map type
+	type single struct {
+		Ints    map[string]int            `hcl:"ints,mapkey=key"`
+		Anys    map[string]any            `hcl:"anys,mapkey=key"`
+		Objects map[string]map[string]int `hcl:"objects,mapkey=key"`
+	}
+	data = `
+ints    = [{ key = "a", value = 1 }]
+anys    = [{ key = "a", value = 1 }, { key = "b", value = 2, size = 3 }]
+objects = [{ key = "a", value = 1 }]
+`
+	s := new(single)
+	if err := Unmarshal([]byte(data), s); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Ints, map[string]int{"a": 1}) {
+		t.Errorf("Ints = %#v", s.Ints)
+	}
+	if b, ok := s.Anys["b"].(map[string]any); s.Anys["a"] != 1 || !ok || len(b) != 2 {
+		t.Errorf("Anys = %#v", s.Anys)
+	}
+	if !reflect.DeepEqual(s.Objects, map[string]map[string]int{"a": {"value": 1}}) {
+		t.Errorf("Objects = %#v", s.Objects)
+	}
+
+	dup := `
+entries = [
+  { key = "a", value = 1 },
+  { key = "a", value = 2 },
+]
+extra = [{ id = "x" }, { id = "x" }]
+`
+	err := Unmarshal([]byte(dup), new(ingest))
+	if err == nil || !strings.Contains(err.Error(), `duplicate key "a"`) {
+		t.Errorf("expected duplicate key error, got %v", err)
+	}
+
+	missing := `entries = [{ value = 1 }]`
+	if err := Unmarshal([]byte(missing), new(ingest)); err == nil {
+		t.Error("expected error for missing key attribute")
+	}
+}
+
+func TestHclSpecPerSubtree(t *testing.T) {
+	type frame struct {
+		Color string `hcl:"color"`
+	}
+	type border struct {
+		Width int `hcl:"width"`
+	}
+	type holder struct {
+		Shape inter `hcl:"shape,block"`
+		Frame frame `hcl:"frame,block"`
+	}
+	type edged struct {
+		Shape inter  `hcl:"shape,block"`
+		Frame border `hcl:"frame,block"`
+	}
+	type scene struct {
+		Left  holder `hcl:"left,block"`
+		Right holder `hcl:"right,block"`
+	}
+	data := `
+left {
+  shape { radius = 2 }
+  frame { color = "red" }
+}
+right {
+  shape {
+    sx = 3
+    sy = 4
+  }
+  frame { color = "blue" }
+}
+`
+	circleSpec, err := schema.NewStruct("holder", map[string]any{"Shape": "circle"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	squareSpec, err := schema.NewStruct("holder", map[string]any{"Shape": "square"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := schema.NewStruct("scene", map[string]any{
+		"Left":  circleSpec,
+		"Right": squareSpec,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]any{"circle": new(circle), "square": new(square), "holder": new(holder)}
+
+	s := new(scene)
+	if err := UnmarshalSpec([]byte(data), s, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := s.Left.Shape.(*circle); !ok || c.Radius != 2 {
+		t.Errorf("Left.Shape = %#v", s.Left.Shape)
+	}
+	if sq, ok := s.Right.Shape.(*square); !ok || sq.SX != 3 || sq.SY != 4 {
+		t.Errorf("Right.Shape = %#v", s.Right.Shape)
+	}
+	if s.Left.Frame.Color != "red" || s.Right.Frame.Color != "blue" {
+		t.Errorf("frames = %#v %#v", s.Left.Frame, s.Right.Frame)
+	}
+	if len(circleSpec.GetFields()) != 1 || len(squareSpec.GetFields()) != 1 {
+		t.Errorf("spec was modified: %v %v", circleSpec.GetFields(), squareSpec.GetFields())
+	}
+
+	// the inferred Frame type of holder must not leak into edged
+	e := new(edged)
+	if err := UnmarshalSpec([]byte("shape { radius = 1 }\nframe { width = 7 }"), e, circleSpec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if e.Frame.Width != 7 {
+		t.Errorf("Frame = %#v", e.Frame)
+	}
+}
+
+func TestHclNativeFunctionScalars(t *testing.T) {
+	type scalars struct {
+		Count int     `hcl:"count"`
+		Ratio float32 `hcl:"ratio"`
+		OK    bool    `hcl:"ok"`
+		Name  string  `hcl:"name"`
+	}
+	ref := map[string]any{
+		"functions": map[string]any{
+			"count": func() int { return 7 },
+			"ratio": func() float64 { return 0.25 },
+			"ok":    func() (bool, error) { return true, nil },
+			"name":  func(s string) string { return s + "-svc" },
+		},
+	}
+	data := `
+count = count()
+ratio = ratio()
+ok    = ok()
+name  = name("api")
+`
+	s := new(scalars)
+	if err := UnmarshalSpec([]byte(data), s, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != 7 || s.Ratio != 0.25 || !s.OK || s.Name != "api-svc" {
+		t.Errorf("%#v", s)
+	}
+}
+
+func TestHclUnmarshalWithPresence(t *testing.T) {
+	type service struct {
+		Name    string `hcl:"name,label"`
+		Port    int    `hcl:"port,optional"`
+		Timeout int    `hcl:"timeout,optional"`
+	}
+	type app struct {
+		Name     string              `hcl:"name"`
+		Replicas int                 `hcl:"replicas,optional"`
+		Debug    bool                `hcl:"debug,optional"`
+		Services map[string]*service `hcl:"service,block"`
+	}
+	data := `
+name = "app"
+debug = false
+service "api" {
+  port = 8080
+}
+`
+	a := new(app)
+	present, err := UnmarshalWithPresence([]byte(data), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "app" || a.Services["api"].Port != 8080 {
+		t.Errorf("%#v", a)
+	}
+	want := map[string]bool{
+		"name":             true,
+		"debug":            true,
+		"service":          true,
+		"service.api":      true,
+		"service.api.port": true,
+	}
+	if !reflect.DeepEqual(present, want) {
+		t.Errorf("present = %v, want %v", present, want)
+	}
+}
+
+func TestHclBlockReferencesLaterAttribute(t *testing.T) {
+	type server struct {
+		Address string `hcl:"address"`
+	}
+	type config struct {
+		Server *server `hcl:"server,block"`
+		Host   string  `hcl:"host"`
+		Port   int     `hcl:"port"`
+	}
+	// the block comes first and uses attributes declared below it
+	data := `
+server {
+  address = "${host}:${port}"
+}
+
+host = "localhost"
+port = 8080
+`
+	var cfg config
+	if err := Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server == nil || cfg.Server.Address != "localhost:8080" {
+		t.Errorf("got %#v", cfg.Server)
+	}
+
+	var m map[string]any
+	if err := Unmarshal([]byte(data), &m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m["server"].(map[string]any)["address"]; got != "localhost:8080" {
+		t.Errorf("got %#v", got)
+	}
+}
+
+func TestHclPrefixSuffix(t *testing.T) {
+	type config struct {
+		Path string `hcl:"path" hclprefix:"/etc/"`
+		File string `hcl:"file,optional" hclprefix:"conf.d/" hclsuffix:".conf"`
+		Log  string `hclsuffix:".log"`
+	}
+	cfg := &config{Path: "app", File: "main", Log: "access"}
+	bs, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{`path = "/etc/app"`, `file = "conf.d/main.conf"`, `log  = "access.log"`} {
+		if !strings.Contains(string(bs), line) {
+			t.Errorf("expected %s in:\n%s", line, bs)
+		}
+	}
+
+	var back config
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != *cfg {
+		t.Errorf("got %#v", back)
+	}
+
+	// a value without the prefix is kept as written
+	back = config{}
+	if err := Unmarshal([]byte(`path = "/opt/app"`), &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Path != "/opt/app" {
+		t.Errorf("got %q", back.Path)
+	}
+}
+
+func TestHclLargeIntegers(t *testing.T) {
+	type config struct {
+		Max   uint64 `hcl:"max"`
+		Min   int64  `hcl:"min"`
+		Extra any    `hcl:"extra,optional"`
+	}
+	cfg := &config{Max: math.MaxUint64, Min: math.MinInt64, Extra: uint64(math.MaxUint64)}
+	bs, err := Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), "18446744073709551615") {
+		t.Errorf("expected math.MaxUint64 in:\n%s", bs)
+	}
+
+	var back config
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, *cfg) {
+		t.Errorf("got %#v", back)
+	}
+
+	m := make(map[string]any)
+	if err := Unmarshal([]byte(`big = 18446744073709551615`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["big"] != uint64(math.MaxUint64) {
+		t.Errorf("got %#v", m["big"])
+	}
+
+	// one above math.MaxUint64
+	if err := Unmarshal([]byte("max = 18446744073709551616\nmin = 0"), &back); err == nil {
+		t.Errorf("expected overflow error")
+	}
+}
+
+func TestHclTemplateInterpolation(t *testing.T) {
+	type service struct {
+		Name  string `hcl:"name"`
+		Image string `hcl:"image,optional"`
+	}
+	type config struct {
+		Env     string   `hcl:"env"`
+		Service *service `hcl:"service,block"`
+	}
+	data := `
+env = "prod"
+service {
+  name  = "svc-${var.env}"
+  image = "registry/${upper(env)}:latest"
+}
+`
+	ref := map[string]any{
+		"functions": map[string]function.Function{"upper": stdlib.UpperFunc},
+	}
+	var cfg config
+	if err := UnmarshalSpec([]byte(data), &cfg, nil, ref); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Service == nil || cfg.Service.Name != "svc-prod" || cfg.Service.Image != "registry/PROD:latest" {
+		t.Errorf("got %#v", cfg.Service)
+	}
+}
+
+func TestHclTrailingCommas(t *testing.T) {
+	type config struct {
+		Ports  []int          `hcl:"ports"`
+		Limits map[string]int `hcl:"limits"`
+		Max    int            `hcl:"max"`
+	}
+	data := `
+ports  = [80, 443,]
+limits = {
+  cpu = 2,
+  mem = 4,
+}
+max    = max(1, 2,)
+`
+	var cfg config
+	if err := Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{Ports: []int{80, 443}, Limits: map[string]int{"cpu": 2, "mem": 4}, Max: 2}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("got %#v", cfg)
+	}
+
+	var m map[string]any
+	if err := Unmarshal([]byte(`items = [{ x = 1, }, { y = 2 },]`), &m); err != nil {
+		t.Fatal(err)
+	}
+	if items := m["items"].([]any); len(items) != 2 {
+		t.Errorf("got %#v", m)
+	}
+
+	if err := Unmarshal([]byte(`ports = [80, , 443]`), &cfg); err == nil {
+		t.Error("expected error for an empty element")
+	}
+}
+
+func TestHclMapOfStructsAsObject(t *testing.T) {
+	type service struct {
+		Port int    `hcl:"port"`
+		Host string `hcl:"host,optional"`
+	}
+	type config struct {
+		Services map[string]*service `hcl:"services,block"`
+		Values   map[string]service  `hcl:"values,block,optional"`
+	}
+	data := `
+services = {
+  api = { port = 8080 }
+  web = { port = 80, host = "example.com" }
+}
+values = { a = { port = 1 } }
+`
+	var cfg config
+	if err := Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{
+		Services: map[string]*service{
+			"api": {Port: 8080},
+			"web": {Port: 80, Host: "example.com"},
+		},
+		Values: map[string]service{"a": {Port: 1}},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("got %#v", cfg)
+	}
+
+	// the object form decodes like the labeled blocks Marshal writes
+	bs, err := Marshal(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back config
+	if err := Unmarshal(bs, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, expected) {
+		t.Errorf("round trip: %#v", back)
+	}
+}
+
+func TestHclCoreFunctions(t *testing.T) {
+	type limits struct {
+		Ports []int          `hcl:"ports"`
+		Sizes map[string]int `hcl:"sizes"`
+	}
+	type config struct {
+		Mask   int     `hcl:"mask"`
+		Count  int64   `hcl:"count"`
+		Name   string  `hcl:"name"`
+		Port   *uint16 `hcl:"port"`
+		Ratio  float32 `hcl:"ratio"`
+		Limits *limits `hcl:"limits,block"`
+	}
+	data := `
+mask  = parseint("ff", 16)
+count = length(["a", "b", "c"])
+name  = coalesce("", "fallback")
+port  = parseint("8080", 10)
+ratio = parseint("3", 10) / 2
+limits {
+  ports = [parseint("50", 10), length([1])]
+  sizes = { small = parseint("10", 2) }
+}
+`
+	var cfg config
+	if err := Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	port := uint16(8080)
+	expected := config{
+		Mask:   255,
+		Count:  3,
+		Name:   "fallback",
+		Port:   &port,
+		Ratio:  1.5,
+		Limits: &limits{Ports: []int{50, 1}, Sizes: map[string]int{"small": 2}},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("got %#v", cfg)
+	}
+
+	err := Unmarshal([]byte(`mask = parseint("zz", 10)`), &cfg)
+	if err == nil || !strings.Contains(err.Error(), "parse") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+func TestHclListFunctions(t *testing.T) {
+	type config struct {
+		Flat   []int     `hcl:"flat"`
+		Joined []string  `hcl:"joined"`
+		Part   []int64   `hcl:"part"`
+		Ratios []float64 `hcl:"ratios"`
+		Pair   [2]int    `hcl:"pair"`
+		Mixed  []int     `hcl:"mixed"`
+		Nested [][]int   `hcl:"nested"`
+		Empty  []int     `hcl:"empty"`
+	}
+	data := `
+flat   = flatten([[1], [2, 3]])
+joined = concat(["a"], ["b", "c"])
+part   = slice([1, 2, 3, 4], 1, 3)
+ratios = flatten([[1.5], [2]])
+pair   = concat([1], [2])
+mixed  = concat([1], ["2"])
+nested = concat([[1]], [[2, 3]])
+empty  = flatten([])
+`
+	var cfg config
+	if err := Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	expected := config{
+		Flat:   []int{1, 2, 3},
+		Joined: []string{"a", "b", "c"},
+		Part:   []int64{2, 3},
+		Ratios: []float64{1.5, 2},
+		Pair:   [2]int{1, 2},
+		Mixed:  []int{1, 2},
+		Nested: [][]int{{1}, {2, 3}},
+		Empty:  []int{},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("got %#v", cfg)
+	}
+
+	err := Unmarshal([]byte(`flat = flatten([[1.5]])`), &cfg)
+	if err == nil || !strings.Contains(err.Error(), "whole number") {
+		t.Errorf("expected a conversion error, got %v", err)
+	}
+}
+
+type genericBox[T any] struct {
+	Value T   `hcl:"value"`
+	Items []T `hcl:"items,optional"`
+}
+
+func (b *genericBox[T]) Area() float32 { return float32(len(b.Items)) }
+
+type genericPair[K comparable, V any] struct {
+	Key   K `hcl:"key"`
+	Value V `hcl:"value"`
+}
+
+func (p *genericPair[K, V]) Area() float32 { return 1 }
+
+type genericHolder struct {
+	Name   string                         `hcl:"name"`
+	Box    genericBox[int]                `hcl:"box,block"`
+	Boxes  map[string]*genericBox[string] `hcl:"boxes,block"`
+	Shape  inter                          `hcl:"shape,block,optional"`
+	Shapes []inter                        `hcl:"shapes,block,optional"`
+}
+
+func TestHclGenericStructs(t *testing.T) {
+	h := &genericHolder{
+		Name:  "store",
+		Box:   genericBox[int]{Value: 3, Items: []int{1, 2}},
+		Boxes: map[string]*genericBox[string]{"k": {Value: "v"}},
+	}
+	bs, err := Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back := new(genericHolder)
+	if err := Unmarshal(bs, back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, h) {
+		t.Errorf("round trip: %#v", back)
+	}
+	if reflect.TypeOf(genericBox[int]{}).Name() != "genericBox[int]" {
+		t.Errorf("unexpected reflect name %s", reflect.TypeOf(genericBox[int]{}).Name())
+	}
+
+	// instantiations are distinct classes, registered by name
+	data := `name = "shapes"
+box {
+  value = 1
+}
+shape {
+  value = "a"
+  items = ["b", "c"]
+}
+shapes {
+  key   = "x"
+  value = 2
+}
+shapes {
+  value = 4
+}
+`
+	spec, err := schema.NewStruct("genericHolder", map[string]any{
+		"Shape":  "genericBox[string]",
+		"Shapes": []string{"genericPair[string,int]", "genericBox[int]"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := map[string]any{
+		"genericBox[string]":      new(genericBox[string]),
+		"genericBox[int]":         new(genericBox[int]),
+		"genericPair[string,int]": new(genericPair[string, int]),
+	}
+	g := new(genericHolder)
+	if err := UnmarshalSpec([]byte(data), g, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := g.Shape.(*genericBox[string]); !ok || b.Value != "a" || len(b.Items) != 2 {
+		t.Errorf("shape: %#v", g.Shape)
+	}
+	if len(g.Shapes) != 2 {
+		t.Fatalf("shapes: %#v", g.Shapes)
+	}
+	if p, ok := g.Shapes[0].(*genericPair[string, int]); !ok || p.Key != "x" || p.Value != 2 {
+		t.Errorf("shapes[0]: %#v", g.Shapes[0])
+	}
+	if b, ok := g.Shapes[1].(*genericBox[int]); !ok || b.Value != 4 {
+		t.Errorf("shapes[1]: %#v", g.Shapes[1])
+	}
+
+	bs, err = Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again := new(genericHolder)
+	if err := UnmarshalSpec(bs, again, spec, ref); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, g) {
+		t.Errorf("round trip: %#v", again)
+	}
+}
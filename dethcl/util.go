@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 
@@ -27,20 +28,118 @@ func clone(old any) any {
 // parseHCLTag extracts the HCL tag name and modifier from a struct field tag.
 // Returns [0] = tag name, [1] = modifier (e.g., "label", "block", "optional")
 // Example: `hcl:"name,label"` returns ["name", "label"]
+//
+// It runs for every field of every value marshaled, so it walks the tag
+// without allocating.
 func parseHCLTag(tag reflect.StructTag) [2]string {
-	for _, tagStr := range strings.Fields(string(tag)) {
-		if len(tagStr) >= tagPrefixHCLLength && strings.ToLower(tagStr[:tagPrefixHCLLength]) == tagPrefixHCL {
+	rest := string(tag)
+	for rest != "" {
+		var tagStr string
+		tagStr, rest = nextTagField(rest)
+		if len(tagStr) >= tagPrefixHCLLength && strings.EqualFold(tagStr[:tagPrefixHCLLength], tagPrefixHCL) {
 			tagStr = tagStr[tagPrefixHCLLength : len(tagStr)-1]
-			parts := strings.SplitN(tagStr, ",", 2)
-			if len(parts) == 2 {
-				return [2]string{parts[0], parts[1]}
-			}
-			return [2]string{parts[0], ""}
+			name, modifier, _ := strings.Cut(tagStr, ",")
+			return [2]string{name, modifier}
 		}
 	}
 	return [2]string{}
 }
 
+// nextTagField returns the first space-separated field of s and the text
+// after it, like one step of strings.Fields.
+func nextTagField(s string) (field, rest string) {
+	start := 0
+	for start < len(s) && isTagSpace(s[start]) {
+		start++
+	}
+	end := start
+	for end < len(s) && !isTagSpace(s[end]) {
+		end++
+	}
+	return s[start:end], s[end:]
+}
+
+func isTagSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// HCLName returns the HCL name and tag modifier that marshal and unmarshal use
+// for a struct field. The name comes from the hcl tag, or is the lowercased
+// field name when the tag has no name.
+//
+// Example:
+//
+//	Port    int `hcl:"port,optional"` // returns "port", "optional"
+//	Timeout int                        // returns "timeout", ""
+//	Secret  string `hcl:"-"`          // returns "-", ""
+func HCLName(field reflect.StructField) (name, modifier string) {
+	tagParts := parseHCLTag(field.Tag)
+	name, modifier = tagParts[0], tagParts[1]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name, modifier
+}
+
+// hasTagOption reports whether the comma-separated modifier list of an hcl tag
+// contains opt. Example: hasTagOption("optional,trim", "trim") returns true.
+func hasTagOption(modifier, opt string) bool {
+	for _, part := range strings.Split(modifier, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), opt) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagOptionValue returns the value of a key=value option in the comma-separated
+// modifier list of an hcl tag.
+// Example: tagOptionValue("optional,mapkey=id", "mapkey") returns ("id", true).
+func tagOptionValue(modifier, opt string) (string, bool) {
+	for _, part := range strings.Split(modifier, ",") {
+		k, v, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found && strings.EqualFold(k, opt) {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+// gohclKinds are the tag modifiers understood by gohcl.
+var gohclKinds = []string{"attr", tagModifierBlock, tagModifierLabel, "remain", tagModifierOptional}
+
+// encoderTag rewrites the hcl tag of a simple field so that gohcl, which panics
+// on modifiers it does not know, only sees its own kind.
+// Example: `hcl:"body,optional,trim"` becomes `hcl:"body,optional"`.
+// An omitempty field without another kind is decoded as optional.
+func encoderTag(field reflect.StructField) reflect.StructField {
+	tagParts := parseHCLTag(field.Tag)
+	if tagParts[1] == "" || slices.Contains(gohclKinds, tagParts[1]) {
+		return field
+	}
+	kind := ""
+	for _, part := range strings.Split(tagParts[1], ",") {
+		if slices.Contains(gohclKinds, strings.TrimSpace(part)) {
+			kind = "," + strings.TrimSpace(part)
+			break
+		}
+	}
+	if kind == "" && hasTagOption(tagParts[1], tagModifierOmitEmpty) {
+		kind = "," + tagModifierOptional
+	}
+	field.Tag = reflect.StructTag(fmt.Sprintf(`hcl:"%s%s"`, tagParts[0], kind))
+	return field
+}
+
+// stringAffixes returns the hclprefix and hclsuffix tag values of a string
+// field, which marshal adds around the value and unmarshal strips again.
+func stringAffixes(field reflect.StructField) (prefix, suffix string) {
+	if field.Type.Kind() != reflect.String {
+		return "", ""
+	}
+	return field.Tag.Get(tagKeyPrefix), field.Tag.Get(tagKeySuffix)
+}
+
 // extractHCLTagName returns just the HCL tag name (without modifier) as bytes.
 func extractHCLTagName(tag reflect.StructTag) []byte {
 	parsed := parseHCLTag(tag)
@@ -80,3 +179,28 @@ func getFirstMapStructFromMap(m map[string]*schema.MapStruct) *schema.MapStruct
 	sort.Strings(keys)
 	return m[keys[0]]
 }
+
+// optionalTag reports whether the tag modifiers make a field optional,
+// either directly or through omitempty.
+func optionalTag(modifier string) bool {
+	return hasTagOption(modifier, tagModifierOptional) || hasTagOption(modifier, tagModifierOmitEmpty)
+}
+
+// isEmptyValue reports whether v is empty in the omitempty sense: the zero
+// value, an empty slice, map, array or string, or an interface or pointer
+// that is nil or holds an empty value.
+func isEmptyValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface:
+		return v.IsNil() || isEmptyValue(v.Elem())
+	case reflect.Pointer:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
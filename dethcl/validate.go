@@ -0,0 +1,36 @@
+package dethcl
+
+import (
+	"strings"
+
+	"github.com/genelet/horizon/utils"
+)
+
+// Validator is the interface implemented by types that check their own
+// invariants after they are decoded, e.g. that a port is between 1 and 65535.
+//
+// Unmarshal calls Validate on the target and on each nested block once its
+// fields are set, and returns the first error as a *FieldError with the path
+// of the block, e.g. "service.api: port 0 out of range".
+type Validator interface {
+	Validate() error
+}
+
+// validate calls Validate on current if it is a Validator, returning the
+// error as a FieldError with the dot-joined path of node.
+func validate(node *utils.Tree, current any) error {
+	validator, ok := current.(Validator)
+	if !ok {
+		return nil
+	}
+	err := validator.Validate()
+	if err == nil {
+		return nil
+	}
+	if node != nil {
+		if path := strings.Join(node.Path(), "."); path != "" {
+			return &FieldError{Path: path, Err: err}
+		}
+	}
+	return err
+}
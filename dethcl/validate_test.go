@@ -0,0 +1,118 @@
+package dethcl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var errNoName = errors.New("name is required")
+
+type validService struct {
+	Name string `hcl:"name,label"`
+	Port int    `hcl:"port"`
+}
+
+func (s *validService) Validate() error {
+	if s.Port < 1 || s.Port > 65535 {
+		return fmt.Errorf("port %d out of range", s.Port)
+	}
+	return nil
+}
+
+type validConfig struct {
+	Name     string                   `hcl:"name,optional"`
+	Services map[string]*validService `hcl:"service,block"`
+	Main     *validService            `hcl:"main,block"`
+}
+
+func (c *validConfig) Validate() error {
+	if c.Name == "" {
+		return errNoName
+	}
+	return nil
+}
+
+func TestUnmarshalValidate(t *testing.T) {
+	var cfg validConfig
+	err := Unmarshal([]byte(`name = "app"
+service "api" {
+  port = 8080
+}
+main "web" {
+  port = 80
+}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Services["api"].Port != 8080 || cfg.Main.Port != 80 {
+		t.Errorf("got %#v", cfg)
+	}
+
+	err = Unmarshal([]byte(`name = "app"
+service "api" {
+  port = 0
+}`), &validConfig{})
+	if err == nil || !strings.Contains(err.Error(), "service.api: port 0 out of range") {
+		t.Errorf("expected the nested error with its path, got %v", err)
+	}
+
+	err = Unmarshal([]byte(`main "web" {
+  port = 70000
+}`), &validConfig{})
+	if err == nil || !strings.Contains(err.Error(), "main.web: port 70000 out of range") {
+		t.Errorf("expected the nested error first, got %v", err)
+	}
+
+	err = Unmarshal([]byte(`service "api" {
+  port = 8080
+}`), &validConfig{})
+	if !errors.Is(err, errNoName) || err.Error() != errNoName.Error() {
+		t.Errorf("expected the root error without a path, got %v", err)
+	}
+
+	// types without Validate are unaffected
+	var plain struct {
+		Port int `hcl:"port"`
+	}
+	if err := Unmarshal([]byte(`port = 0`), &plain); err != nil {
+		t.Error(err)
+	}
+}
+
+// customValid decodes itself and validates its port.
+type customValid struct {
+	Port int
+}
+
+func (c *customValid) UnmarshalHCL(data []byte, labels ...string) error {
+	_, err := fmt.Sscanf(string(data), "port = %d", &c.Port)
+	return err
+}
+
+func (c *customValid) Validate() error {
+	if c.Port < 1 {
+		return fmt.Errorf("port %d out of range", c.Port)
+	}
+	return nil
+}
+
+func TestUnmarshalValidateCustom(t *testing.T) {
+	// a type decoding itself is validated by every entry point
+	decoders := map[string]func([]byte, any) error{
+		"Unmarshal":    func(data []byte, v any) error { return Unmarshal(data, v) },
+		"WithOptions":  func(data []byte, v any) error { return UnmarshalWithOptions(data, v, UnmarshalOptions{}) },
+		"Strict":       func(data []byte, v any) error { return UnmarshalStrict(data, v) },
+		"WithPresence": func(data []byte, v any) error { _, err := UnmarshalWithPresence(data, v); return err },
+	}
+	for name, decode := range decoders {
+		c := new(customValid)
+		if err := decode([]byte("port = 0"), c); err == nil || err.Error() != "port 0 out of range" {
+			t.Errorf("%s: expected the validation error, got %v", name, err)
+		}
+		if err := decode([]byte("port = 80"), c); err != nil || c.Port != 80 {
+			t.Errorf("%s: got %d, %v", name, c.Port, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package dethcl
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/genelet/horizon/utils"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// UnmarshalWithVars decodes HCL data like Unmarshal, with vars set as
+// variables that expressions read as var.<name>, e.g.
+//
+//	hcl := []byte(`name = var.service_name
+//	url  = "postgres://${var.db.host}:${var.db.port}"`)
+//	vars := map[string]cty.Value{
+//	    "service_name": cty.StringVal("api"),
+//	    "db": cty.ObjectVal(map[string]cty.Value{
+//	        "host": cty.StringVal("localhost"),
+//	        "port": cty.NumberIntVal(5432),
+//	    }),
+//	}
+//	err := UnmarshalWithVars(hcl, &cfg, vars)
+//
+// Each entry of vars is an item of the root node of the variable tree, where
+// the attributes of the document are kept as they are decoded, so nested
+// variables such as var.db.host are given as object values, not as nodes. An
+// attribute of the document named like a variable replaces it from then on.
+// Types implementing Unmarshaler decode themselves without the variables.
+func UnmarshalWithVars(hclData []byte, current any, vars map[string]cty.Value, labels ...string) error {
+	if current == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(current)
+	if rv.Kind() != reflect.Pointer {
+		return fmt.Errorf("non-pointer or nil data")
+	}
+	if rv.IsNil() {
+		return nil
+	}
+	if _, ok := current.(Unmarshaler); ok {
+		return Unmarshal(hclData, current, labels...)
+	}
+	root := utils.NewTree(utils.TreeNodeVar)
+	for k, v := range vars {
+		root.AddItem(k, v)
+	}
+	ref := map[string]any{utils.ContextKeyAttributes: root}
+	return UnmarshalSpec(hclData, current, nil, ref, labels...)
+}
+
+// UnmarshalWithNativeVars decodes HCL data like UnmarshalWithVars, with
+// variables given as Go values, e.g. {"db": map[string]any{"host": "localhost"}}
+// for var.db.host, converted by utils.NativeToCty.
+func UnmarshalWithNativeVars(hclData []byte, current any, vars map[string]any, labels ...string) error {
+	values := make(map[string]cty.Value, len(vars))
+	for k, v := range vars {
+		value, err := utils.NativeToCty(v)
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", k, err)
+		}
+		values[k] = value
+	}
+	return UnmarshalWithVars(hclData, current, values, labels...)
+}
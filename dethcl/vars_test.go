@@ -0,0 +1,68 @@
+package dethcl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestUnmarshalWithVars(t *testing.T) {
+	type database struct {
+		Host string `hcl:"host"`
+		Port int    `hcl:"port"`
+	}
+	type config struct {
+		Name string    `hcl:"name"`
+		URL  string    `hcl:"url"`
+		DB   *database `hcl:"db,block"`
+	}
+	data := []byte(`
+name = var.service_name
+url  = "postgres://${var.db.host}:${var.db.port}"
+db {
+  host = var.db.host
+  port = var.db.port + 1
+}`)
+	want := &config{Name: "api", URL: "postgres://localhost:5432", DB: &database{Host: "localhost", Port: 5433}}
+
+	vars := map[string]cty.Value{
+		"service_name": cty.StringVal("api"),
+		"db": cty.ObjectVal(map[string]cty.Value{
+			"host": cty.StringVal("localhost"),
+			"port": cty.NumberIntVal(5432),
+		}),
+	}
+	got := new(config)
+	if err := UnmarshalWithVars(data, got, vars); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, %#v", got, got.DB)
+	}
+
+	native := map[string]any{
+		"service_name": "api",
+		"db":           map[string]any{"host": "localhost", "port": 5432},
+	}
+	got = new(config)
+	if err := UnmarshalWithNativeVars(data, got, native); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, %#v", got, got.DB)
+	}
+
+	// variables are not decoded as attributes of the document
+	m := make(map[string]any)
+	if err := UnmarshalWithNativeVars(data, &m, native); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["service_name"]; ok || m["name"] != "api" {
+		t.Errorf("got %v", m)
+	}
+
+	if err := UnmarshalWithVars([]byte("name = var.missing"), new(config), nil); err == nil {
+		t.Error("expected an error for an unknown variable")
+	}
+}
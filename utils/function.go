@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// RegisterFunction adds the cty function fn to ref[FUNCTIONS] under name, so
+// that HCL expressions can call it, e.g. name = upper("abc"). The map is
+// created if ref has no functions yet.
+//
+// NewEvalContext merges the built-in functions into the same map and
+// overwrites functions of the same name, so register after calling it to
+// replace a built-in:
+//
+//	node := utils.NewEvalContext(nil)
+//	ref := node.GetRef()
+//	err := utils.RegisterFunction(ref, "upper", myUpper)
+//
+// ref[FUNCTIONS] holds either cty functions or native Go functions, so it is
+// an error if RegisterGoFunction has already been used on ref.
+func RegisterFunction(ref map[string]any, name string, fn function.Function) error {
+	if ref == nil {
+		return fmt.Errorf("register function %s: ref is nil", name)
+	}
+	switch t := ref[FUNCTIONS].(type) {
+	case nil:
+		ref[FUNCTIONS] = map[string]function.Function{name: fn}
+	case map[string]function.Function:
+		t[name] = fn
+	default:
+		return fmt.Errorf("register function %s: ref holds %T, not cty functions", name, ref[FUNCTIONS])
+	}
+	return nil
+}
+
+// RegisterGoFunction adds the native Go function fn to ref[FUNCTIONS] under
+// name, so that HCL expressions can call it, e.g. name = upper("abc") with
+//
+//	err := utils.RegisterGoFunction(ref, "upper", strings.ToUpper)
+//
+// The arguments of fn are converted by CtyToNative, and fn must return at
+// most one value, optionally followed by an error, e.g. func(string) int or
+// func(float64) (bool, error).
+//
+// Native functions are kept apart from the cty functions of RegisterFunction
+// and NewEvalContext, so it is an error if ref already holds cty functions;
+// register them on a ref without functions and pass it to NewEvalContext.
+func RegisterGoFunction(ref map[string]any, name string, fn any) error {
+	if ref == nil {
+		return fmt.Errorf("register function %s: ref is nil", name)
+	}
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func || f.IsNil() {
+		return fmt.Errorf("function %s is not a func, got %T", name, fn)
+	}
+	if _, _, err := goFunctionResults(name, f.Type()); err != nil {
+		return err
+	}
+	switch t := ref[FUNCTIONS].(type) {
+	case nil:
+		ref[FUNCTIONS] = map[string]any{name: fn}
+	case map[string]any:
+		t[name] = fn
+	default:
+		return fmt.Errorf("register function %s: ref holds %T, not Go functions", name, ref[FUNCTIONS])
+	}
+	return nil
+}
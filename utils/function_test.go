@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+func evalRef(t *testing.T, node *Tree, src string) (cty.Value, error) {
+	t.Helper()
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	return ExpressionToCty(node.GetRef(), node, expr)
+}
+
+func TestRegisterFunction(t *testing.T) {
+	shout := function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "s", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.StringVal(strings.ToUpper(args[0].AsString()) + "!"), nil
+		},
+	})
+
+	node := NewEvalContext(nil)
+	if err := RegisterFunction(node.GetRef(), "shout", shout); err != nil {
+		t.Fatal(err)
+	}
+	got, err := evalRef(t, node, `shout(lower("Abc"))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.RawEquals(cty.StringVal("ABC!")) {
+		t.Errorf("got %#v", got)
+	}
+
+	// registered before NewEvalContext, the built-ins are merged in
+	ref := make(map[string]any)
+	if err := RegisterFunction(ref, "shout", shout); err != nil {
+		t.Fatal(err)
+	}
+	node = NewEvalContext(ref)
+	if got, err = evalRef(t, node, `"${shout("a")}${upper("b")}"`); err != nil {
+		t.Fatal(err)
+	}
+	if !got.RawEquals(cty.StringVal("A!B")) {
+		t.Errorf("got %#v", got)
+	}
+
+	if err := RegisterFunction(nil, "shout", shout); err == nil {
+		t.Errorf("expected an error for a nil ref")
+	}
+	goRef := make(map[string]any)
+	if err := RegisterGoFunction(goRef, "upper", strings.ToUpper); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterFunction(goRef, "shout", shout); err == nil {
+		t.Errorf("expected an error for a ref of Go functions")
+	}
+}
+
+func TestRegisterGoFunction(t *testing.T) {
+	ref := make(map[string]any)
+	if err := RegisterGoFunction(ref, "upper", strings.ToUpper); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterGoFunction(ref, "twice", func(n int) (int, error) { return 2 * n, nil }); err != nil {
+		t.Fatal(err)
+	}
+	node := NewEvalContext(ref)
+
+	got, err := evalRef(t, node, `upper("abc")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.RawEquals(cty.StringVal("ABC")) {
+		t.Errorf("got %#v", got)
+	}
+	if got, err = evalRef(t, node, `twice(21)`); err != nil {
+		t.Fatal(err)
+	}
+	if !got.RawEquals(cty.NumberIntVal(42)) {
+		t.Errorf("got %#v", got)
+	}
+
+	for name, fn := range map[string]any{
+		"pair":   func() (int, int) { return 1, 2 },
+		"triple": func() (int, string, error) { return 1, "", nil },
+		"text":   "not a func",
+		"nil":    (func() int)(nil),
+	} {
+		if err := RegisterGoFunction(ref, name, fn); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if _, ok := ref[FUNCTIONS].(map[string]any)[name]; ok {
+			t.Errorf("%s: registered despite the error", name)
+		}
+	}
+
+	if err := RegisterGoFunction(NewEvalContext(nil).GetRef(), "upper", strings.ToUpper); err == nil {
+		t.Errorf("expected an error for a ref of cty functions")
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"slices"
 	"sync"
 )
 
@@ -183,6 +184,41 @@ func (t *Tree) FindNode(names []string) *Tree {
 	return nil
 }
 
+// Path returns the names of the nodes from the root down to t, excluding the
+// root itself.
+// Example: the node at service/http/web returns []string{"service", "http", "web"}
+// Thread-safe: Uses read locks while walking up the tree.
+func (t *Tree) Path() []string {
+	var names []string
+	for node := t; node != nil; {
+		node.mu.RLock()
+		up := node.Up
+		name := node.Name
+		node.mu.RUnlock()
+		if up == nil {
+			break
+		}
+		names = append(names, name)
+		node = up
+	}
+	slices.Reverse(names)
+	return names
+}
+
+// Depth returns the number of nodes above t, 0 for the root.
+func (t *Tree) Depth() int {
+	depth := 0
+	for node := t; ; depth++ {
+		node.mu.RLock()
+		up := node.Up
+		node.mu.RUnlock()
+		if up == nil {
+			return depth
+		}
+		node = up
+	}
+}
+
 // Variables returns all variables in the tree as a generic map.
 // For HCL expression evaluation, use CtyVariables instead.
 // Thread-safe: Uses read locks and copies children before recursive calls.
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -32,3 +33,24 @@ func TestTree(t *testing.T) {
 		t.Errorf("%#v", first)
 	}
 }
+
+func TestTreePath(t *testing.T) {
+	root := NewTree(VAR)
+	node := root.AddNodes("service", "http", "web")
+	if got := node.Path(); !reflect.DeepEqual(got, []string{"service", "http", "web"}) {
+		t.Errorf("Path() = %v", got)
+	}
+	if got := root.Path(); len(got) != 0 {
+		t.Errorf("root Path() = %v", got)
+	}
+}
+
+func TestTreeDepth(t *testing.T) {
+	root := NewTree(VAR)
+	if got := root.Depth(); got != 0 {
+		t.Errorf("root Depth() = %d", got)
+	}
+	if got := root.AddNodes("service", "http", "web").Depth(); got != 3 {
+		t.Errorf("Depth() = %d", got)
+	}
+}
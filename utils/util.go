@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
 	"reflect"
 
@@ -49,6 +51,13 @@ func CtyToExpression(cv cty.Value, rng hcl.Range) hclsyntax.Expression {
 	return &hclsyntax.LiteralValueExpr{Val: cv, SrcRange: rng}
 }
 
+// callToCty calls the native Go function named by u from funcs.
+//
+// A native function takes arguments converted by CtyToNative and returns a
+// single value, optionally followed by an error, e.g. func(string) int or
+// func(float64) (bool, error). A function returning only an error, or nothing,
+// evaluates to an empty object. Functions with more than one non-error return
+// value are rejected.
 func callToCty(ref map[string]any, node *Tree, funcs map[string]any, u *hclsyntax.FunctionCallExpr) (cty.Value, error) {
 	if u.Name == "" {
 		return cty.EmptyObjectVal, fmt.Errorf("function call is empty")
@@ -60,12 +69,25 @@ func callToCty(ref map[string]any, node *Tree, funcs map[string]any, u *hclsynta
 	if !ok {
 		return cty.EmptyObjectVal, fmt.Errorf("function call is not found for %s", u.Name)
 	}
-	k := -1
 	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		return cty.EmptyObjectVal, fmt.Errorf("function %s is not a func, got %T", u.Name, fn)
+	}
 
-	n := 0
+	// check the return contract before calling the function
+	fType := f.Type()
+	numOut := fType.NumOut()
+	numValues, hasError, err := goFunctionResults(u.Name, fType)
+	if err != nil {
+		return cty.EmptyObjectVal, err
+	}
+
+	n := len(u.Args)
+	if fType.NumIn() != n {
+		return cty.EmptyObjectVal, fmt.Errorf("function %s needs %d args, got %d", u.Name, fType.NumIn(), n)
+	}
 	var in []reflect.Value
-	for _, arg := range u.Args {
+	for i, arg := range u.Args {
 		cv, err := ExpressionToCty(ref, node, arg)
 		if err != nil {
 			return cty.EmptyObjectVal, err
@@ -74,43 +96,48 @@ func callToCty(ref map[string]any, node *Tree, funcs map[string]any, u *hclsynta
 		if err != nil {
 			return cty.EmptyObjectVal, err
 		}
-		in = append(in, reflect.ValueOf(v))
-		n++
-	}
-	if f.Type().NumIn() != n {
-		return cty.EmptyObjectVal, fmt.Errorf("function %s needs %d args, got %d", u.Name, f.Type().NumIn(), n)
+		// numbers may come back as a different numeric type, e.g. float32
+		argType := fType.In(i)
+		rv := reflect.ValueOf(v)
+		switch {
+		case !rv.IsValid():
+			rv = reflect.Zero(argType)
+		case rv.Type().AssignableTo(argType):
+		case rv.Type().ConvertibleTo(argType) && (argType.Kind() == reflect.String) == (rv.Kind() == reflect.String):
+			rv = rv.Convert(argType)
+		default:
+			return cty.EmptyObjectVal, fmt.Errorf("function %s: arg %d: cannot use %T as %v", u.Name, i, v, argType)
+		}
+		in = append(in, rv)
 	}
 
 	outputs := f.Call(in)
-	m := len(outputs)
-	for i, output := range outputs {
-		if output.Type().Implements(reflect.TypeOf((*error)(nil)).Elem()) {
-			k = i
-			break
+	if hasError {
+		errValue := outputs[numOut-1]
+		if (errValue.Kind() != reflect.Interface && errValue.Kind() != reflect.Pointer) || !errValue.IsNil() {
+			return cty.EmptyObjectVal, errValue.Interface().(error)
 		}
 	}
-
-	if k >= 0 {
-		if k >= m {
-			return cty.EmptyObjectVal, fmt.Errorf("error output is missing or mismatched")
-		}
-		if outputs[k].IsValid() && !outputs[k].IsNil() {
-			return cty.EmptyObjectVal, outputs[k].Interface().(error)
-		}
-		if m == 1 {
-			return cty.EmptyObjectVal, nil
-		}
+	if numValues == 0 {
+		return cty.EmptyObjectVal, nil
 	}
+	return NativeToCty(outputs[0].Interface())
+}
 
-	for i, output := range outputs {
-		if i == k {
-			continue
-		}
-		// in this version, we assume only one item returned
-		return NativeToCty(output.Interface())
+// goFunctionResults returns the number of non-error values returned by the
+// native function fType, and whether they are followed by an error. More than
+// one non-error value is an error.
+func goFunctionResults(name string, fType reflect.Type) (int, bool, error) {
+	numOut := fType.NumOut()
+	hasError := numOut > 0 && fType.Out(numOut-1).Implements(reflect.TypeOf((*error)(nil)).Elem())
+	numValues := numOut
+	if hasError {
+		numValues--
 	}
-
-	return cty.EmptyObjectVal, nil
+	if numValues > 1 {
+		return numValues, hasError, fmt.Errorf("function %s must return one value and an optional error, got %d values", name, numValues)
+	}
+	return numValues, hasError, nil
 }
 
 // ExpressionToCty evaluates an HCL expression to a cty.Value.
@@ -167,6 +194,10 @@ func ExpressionToCty(ref map[string]any, node *Tree, v hclsyntax.Expression) (ct
 	}
 
 	if ref != nil && ref[FUNCTIONS] != nil {
+		// functions may also be called inside templates, e.g. "svc-${upper(env)}"
+		if t, ok := ref[FUNCTIONS].(map[string]function.Function); ok {
+			ctx.Functions = t
+		}
 		if u, ok := v.(*hclsyntax.FunctionCallExpr); ok {
 			if u.Name == "null" {
 				return cty.NilVal, nil
@@ -226,6 +257,10 @@ func NativeToCty(item any) (cty.Value, error) {
 			arr = append(arr, ct)
 		}
 		return cty.TupleVal(arr), nil
+	case *big.Int:
+		return cty.NumberVal(new(big.Float).SetInt(t)), nil
+	case *big.Float:
+		return cty.NumberVal(t), nil
 	default:
 	}
 	typ, err := gocty.ImpliedType(item)
@@ -235,24 +270,81 @@ func NativeToCty(item any) (cty.Value, error) {
 	return gocty.ToCtyValue(item, typ)
 }
 
+// NumberMode selects the Go types that CtyToNativeMode gives for numbers.
+type NumberMode int
+
+const (
+	// NumberAuto gives int for values in the int32 range, then int64,
+	// uint64, float32 or float64, whichever holds the value exactly.
+	// Integers beyond 64 bits give *big.Int, and numbers beyond the float64
+	// range *big.Float.
+	NumberAuto NumberMode = iota
+	// NumberInt64 gives int64 for integers and float64 for other numbers.
+	// Like NumberAuto, integers beyond the int64 range, which include all
+	// numbers beyond the float64 range, give *big.Int rather than a float64
+	// that loses their value, so the type is stable only within int64:
+	// callers should use a type switch, not a bare .(int64) assertion.
+	NumberInt64
+	// NumberFloat64 gives float64 for every number.
+	NumberFloat64
+	// NumberJSON gives json.Number holding the decimal text of the value.
+	NumberJSON
+)
+
 func CtyNumberToNative(val cty.Value) (any, error) {
+	return CtyNumberToNativeMode(val, NumberAuto)
+}
+
+// CtyNumberToNativeMode converts a cty number to the Go type selected by mode.
+func CtyNumberToNativeMode(val cty.Value, mode NumberMode) (any, error) {
 	v := val.AsBigFloat()
-	if _, accuracy := v.Int64(); accuracy == big.Exact || accuracy == big.Above {
-		var x int64
-		err := gocty.FromCtyValue(val, &x)
-		if x > 0x7FFFFFFF || x < -0x80000000 {
+	switch mode {
+	case NumberInt64:
+		if _, accuracy := v.Int64(); accuracy == big.Exact {
+			var x int64
+			err := gocty.FromCtyValue(val, &x)
 			return x, err
 		}
-		return int(x), err
-	} else if _, accuracy := v.Int(nil); accuracy == big.Exact || accuracy == big.Above {
-		var x int
-		err := gocty.FromCtyValue(val, &x)
-		return x, err
+		if v.IsInt() {
+			x, _ := v.Int(nil)
+			return x, nil
+		}
+		x, _ := v.Float64()
+		return x, nil
+	case NumberFloat64:
+		x, _ := v.Float64()
+		return x, nil
+	case NumberJSON:
+		if v.IsInt() {
+			return json.Number(v.Text('f', 0)), nil
+		}
+		return json.Number(v.Text('g', -1)), nil
+	default:
+	}
+
+	if v.IsInt() {
+		if x, accuracy := v.Int64(); accuracy == big.Exact {
+			if x > 0x7FFFFFFF || x < -0x80000000 {
+				return x, nil
+			}
+			return int(x), nil
+		}
+		// above the int64 range, e.g. math.MaxUint64
+		if x, accuracy := v.Uint64(); accuracy == big.Exact {
+			return x, nil
+		}
+		// integers beyond 64 bits
+		x, _ := v.Int(nil)
+		return x, nil
 	} else if _, accuracy := v.Float32(); accuracy == big.Exact || accuracy == big.Above {
 		var x float32
 		err := gocty.FromCtyValue(val, &x)
 		return x, err
 	}
+	if x, _ := v.Float64(); math.IsInf(x, 0) {
+		// beyond the float64 range
+		return new(big.Float).Copy(v), nil
+	}
 	var x float64
 	err := gocty.FromCtyValue(val, &x)
 	return x, err
@@ -262,7 +354,7 @@ func CtyNumberToNative(val cty.Value) (any, error) {
 //
 // Conversion rules:
 //   - cty.String → string
-//   - cty.Number → int, int64, float32, or float64 (auto-detected)
+//   - cty.Number → int, int64, uint64, float32, float64, *big.Int or *big.Float (auto-detected)
 //   - cty.Bool → bool
 //   - cty.Object/Map → map[string]any
 //   - cty.List/Tuple/Set → []any
@@ -271,6 +363,12 @@ func CtyNumberToNative(val cty.Value) (any, error) {
 // Numbers are intelligently converted to the smallest type that fits.
 // This is the inverse of NativeToCty.
 func CtyToNative(val cty.Value) (any, error) {
+	return CtyToNativeMode(val, NumberAuto)
+}
+
+// CtyToNativeMode converts a cty.Value like CtyToNative, giving numbers, also
+// those nested in objects and lists, the Go types selected by mode.
+func CtyToNativeMode(val cty.Value, mode NumberMode) (any, error) {
 	if val.IsNull() {
 		return nil, nil
 	}
@@ -282,7 +380,7 @@ func CtyToNative(val cty.Value) (any, error) {
 		err := gocty.FromCtyValue(val, &v)
 		return v, err
 	case cty.Number:
-		return CtyNumberToNative(val)
+		return CtyNumberToNativeMode(val, mode)
 	case cty.Bool:
 		var v bool
 		err := gocty.FromCtyValue(val, &v)
@@ -294,7 +392,7 @@ func CtyToNative(val cty.Value) (any, error) {
 	case ty.IsObjectType(), ty.IsMapType():
 		var u map[string]any
 		for k, v := range val.AsValueMap() {
-			x, err := CtyToNative(v)
+			x, err := CtyToNativeMode(v, mode)
 			if err != nil {
 				return nil, err
 			}
@@ -310,7 +408,7 @@ func CtyToNative(val cty.Value) (any, error) {
 	case ty.IsListType(), ty.IsTupleType(), ty.IsSetType():
 		var u []any
 		for _, v := range val.AsValueSlice() {
-			x, err := CtyToNative(v)
+			x, err := CtyToNativeMode(v, mode)
 			if err != nil {
 				return nil, err
 			}
@@ -335,7 +433,7 @@ func CtyToNative(val cty.Value) (any, error) {
 //
 // The function handles type coercion for common mismatches:
 //   - cty.Object → map[string]T (e.g., from HCL comprehensions)
-//   - cty.Tuple → []T (when tuple and list are semantically equivalent)
+//   - cty.Tuple → []T or [N]T (when tuple and list are semantically equivalent)
 //
 // Parameters:
 //   - ctyVal: the cty.Value to convert
@@ -349,10 +447,43 @@ func CtyToNative(val cty.Value) (any, error) {
 //   - ctyVal="hello", targetType=string → "hello"
 //   - ctyVal=cty.Object(...), targetType=map[string]string → map[string]string{...}
 func ConvertCtyToFieldType(ctyVal cty.Value, targetType reflect.Type) (any, error) {
+	return ConvertCtyToFieldTypeMode(ctyVal, targetType, NumberAuto)
+}
+
+// ConvertCtyToFieldTypeMode converts a cty.Value like ConvertCtyToFieldType.
+// Numbers held by empty interfaces, also at any depth of maps and slices such
+// as map[string]any or []map[string]any, get the Go type selected by mode.
+func ConvertCtyToFieldTypeMode(ctyVal cty.Value, targetType reflect.Type, mode NumberMode) (any, error) {
 	if ctyVal.IsNull() {
 		return reflect.Zero(targetType).Interface(), nil
 	}
 
+	// A pointer to a map or a slice, e.g. *map[string]string, points to the
+	// converted collection
+	if targetType.Kind() == reflect.Pointer && (targetType.Elem().Kind() == reflect.Map || targetType.Elem().Kind() == reflect.Slice) {
+		elem, err := ConvertCtyToFieldTypeMode(ctyVal, targetType.Elem(), mode)
+		if err != nil {
+			return nil, err
+		}
+		ptr := reflect.New(targetType.Elem())
+		ptr.Elem().Set(reflect.ValueOf(elem))
+		return ptr.Interface(), nil
+	}
+
+	// An empty interface takes the natural Go value, e.g. int, string or []any,
+	// which also fills maps and slices of interfaces
+	if holdsInterface(targetType) {
+		native, err := CtyToNativeMode(ctyVal, mode)
+		if err != nil {
+			return nil, err
+		}
+		v, err := nativeToType(native, targetType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert cty.Value to %v: %w", targetType, err)
+		}
+		return v.Interface(), nil
+	}
+
 	// Handle type coercion for common HCL patterns
 	// Convert number to string if needed (e.g., from function return values)
 	if targetType.Kind() == reflect.String && ctyVal.Type() == cty.Number {
@@ -364,37 +495,29 @@ func ConvertCtyToFieldType(ctyVal cty.Value, targetType reflect.Type) (any, erro
 
 	// 1. If target is a map and value is an object, convert object to map
 	if targetType.Kind() == reflect.Map && ctyVal.Type().IsObjectType() {
-		// Get the element type of the map
-		elemType := targetType.Elem()
-
-		// Determine the target cty type for map elements
-		var targetCtyElemType cty.Type
-		if elemType.Kind() == reflect.String {
-			targetCtyElemType = cty.String
-		} else if elemType.Kind() == reflect.Slice && elemType.Elem().Kind() == reflect.String {
-			// Handle map[string][]string case
-			targetCtyElemType = cty.List(cty.String)
-		} else {
-			targetCtyElemType = cty.DynamicPseudoType
+		// Build a map type from the element type, so that nested objects and
+		// tuples e.g. for map[string]map[string]string or map[string][]int
+		// are converted too
+		mapType, err := gocty.ImpliedType(reflect.Zero(targetType).Interface())
+		if err != nil {
+			mapType = cty.Map(cty.DynamicPseudoType)
 		}
 
 		// Convert object to map
-		convertedVal, err := convert.Convert(ctyVal, cty.Map(targetCtyElemType))
+		convertedVal, err := convert.Convert(ctyVal, mapType)
 		if err == nil {
 			ctyVal = convertedVal
 		}
 	}
 
-	// 2. If target is a slice and value is a tuple, convert tuple to list
-	if targetType.Kind() == reflect.Slice && ctyVal.Type().IsTupleType() {
-		// Get the element type of the slice
-		elemType := targetType.Elem()
-
-		// Build a list type
-		var listType cty.Type
-		if elemType.Kind() == reflect.String {
-			listType = cty.List(cty.String)
-		} else {
+	// 2. If target is a slice or an array and value is a tuple, e.g. the
+	// result of concat or flatten, convert tuple to list
+	if (targetType.Kind() == reflect.Slice || targetType.Kind() == reflect.Array) && ctyVal.Type().IsTupleType() {
+		// Build a list type from the element type, so that nested tuples
+		// e.g. for [][]int and mixed elements e.g. [1, "2"] for []int are
+		// converted too
+		listType, err := gocty.ImpliedType(reflect.Zero(reflect.SliceOf(targetType.Elem())).Interface())
+		if err != nil {
 			listType = cty.List(cty.DynamicPseudoType)
 		}
 
@@ -418,6 +541,69 @@ func ConvertCtyToFieldType(ctyVal cty.Value, targetType reflect.Type) (any, erro
 	return reflect.ValueOf(targetPtr).Elem().Interface(), nil
 }
 
+// holdsInterface reports whether typ is an empty interface, or a map with
+// string keys, slice or array whose innermost element is one, e.g.
+// map[string][]any.
+func holdsInterface(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Interface:
+		return typ.NumMethod() == 0
+	case reflect.Map:
+		return typ.Key().Kind() == reflect.String && holdsInterface(typ.Elem())
+	case reflect.Slice, reflect.Array:
+		return holdsInterface(typ.Elem())
+	default:
+		return false
+	}
+}
+
+// nativeToType builds a value of typ, for which holdsInterface is true, from
+// native, a value given by CtyToNativeMode.
+func nativeToType(native any, typ reflect.Type) (reflect.Value, error) {
+	v := reflect.New(typ).Elem()
+	if native == nil {
+		return v, nil
+	}
+	switch typ.Kind() {
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(native))
+	case reflect.Map:
+		m, ok := native.(map[string]any)
+		if !ok {
+			return v, fmt.Errorf("expected an object for %v, got %T", typ, native)
+		}
+		v.Set(reflect.MakeMapWithSize(typ, len(m)))
+		for key, item := range m {
+			elem, err := nativeToType(item, typ.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(typ.Key()), elem)
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := native.([]any)
+		if !ok {
+			return v, fmt.Errorf("expected a list for %v, got %T", typ, native)
+		}
+		if typ.Kind() == reflect.Array && len(list) != typ.Len() {
+			return v, fmt.Errorf("expected %d elements for %v, got %d", typ.Len(), typ, len(list))
+		}
+		if typ.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(typ, len(list), len(list)))
+		}
+		for i, item := range list {
+			elem, err := nativeToType(item, typ.Elem())
+			if err != nil {
+				return v, err
+			}
+			v.Index(i).Set(elem)
+		}
+	default:
+		return v, fmt.Errorf("type %v is not supported", typ)
+	}
+	return v, nil
+}
+
 // CtyVariables converts a Tree's variables to cty.Value format for HCL expression evaluation.
 //
 // This function recursively traverses the tree and converts all stored values to cty.Value.
@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"encoding/json"
+	"math"
+	"math/big"
 	"reflect"
 	"testing"
 
@@ -28,6 +31,8 @@ func TestConvertCtyToFieldType_NumericTypes(t *testing.T) {
 		{"uint16", cty.NumberIntVal(65535), reflect.TypeOf(uint16(0)), uint16(65535)},
 		{"uint32", cty.NumberIntVal(4294967295), reflect.TypeOf(uint32(0)), uint32(4294967295)},
 		{"uint64", cty.NumberIntVal(9223372036854775807), reflect.TypeOf(uint64(0)), uint64(9223372036854775807)},
+		{"uint64 max", cty.MustParseNumberVal("18446744073709551615"), reflect.TypeOf(uint64(0)), uint64(math.MaxUint64)},
+		{"int64 min", cty.NumberIntVal(math.MinInt64), reflect.TypeOf(int64(0)), int64(math.MinInt64)},
 
 		// Float types
 		{"float32", cty.NumberFloatVal(3.14), reflect.TypeOf(float32(0)), float32(3.14)},
@@ -158,6 +163,24 @@ func TestConvertCtyToFieldType_SliceTypes(t *testing.T) {
 			targetType: reflect.TypeOf([]int{}),
 			want:       []int{1, 2, 3},
 		},
+		{
+			name: "[]int_from_mixed_tuple",
+			ctyVal: cty.TupleVal([]cty.Value{
+				cty.NumberIntVal(1),
+				cty.StringVal("2"),
+			}),
+			targetType: reflect.TypeOf([]int{}),
+			want:       []int{1, 2},
+		},
+		{
+			name: "[2]uint8_from_tuple",
+			ctyVal: cty.TupleVal([]cty.Value{
+				cty.NumberIntVal(1),
+				cty.NumberIntVal(2),
+			}),
+			targetType: reflect.TypeOf([2]uint8{}),
+			want:       [2]uint8{1, 2},
+		},
 	}
 
 	for _, tt := range tests {
@@ -199,6 +222,28 @@ func TestConvertCtyToFieldType_NestedTypes(t *testing.T) {
 				"methods": {"GET", "POST"},
 			},
 		},
+		{
+			name: "map[string]map[string]string_from_nested_objects",
+			ctyVal: cty.ObjectVal(map[string]cty.Value{
+				"dev": cty.ObjectVal(map[string]cty.Value{"host": cty.StringVal("localhost")}),
+			}),
+			targetType: reflect.TypeOf(map[string]map[string]string{}),
+			want:       map[string]map[string]string{"dev": {"host": "localhost"}},
+		},
+		{
+			name: "map[string][]int_from_object_with_tuples",
+			ctyVal: cty.ObjectVal(map[string]cty.Value{
+				"ports": cty.TupleVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+			}),
+			targetType: reflect.TypeOf(map[string][]int{}),
+			want:       map[string][]int{"ports": {80, 443}},
+		},
+		{
+			name:       "*map[string]string_from_object",
+			ctyVal:     cty.ObjectVal(map[string]cty.Value{"a": cty.StringVal("1")}),
+			targetType: reflect.TypeOf((*map[string]string)(nil)),
+			want:       &map[string]string{"a": "1"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -214,6 +259,62 @@ func TestConvertCtyToFieldType_NestedTypes(t *testing.T) {
 	}
 }
 
+// TestConvertCtyToFieldTypeMode_Interfaces tests containers of interfaces
+// filled with the chosen number mode
+func TestConvertCtyToFieldTypeMode_Interfaces(t *testing.T) {
+	tests := []struct {
+		name       string
+		ctyVal     cty.Value
+		targetType reflect.Type
+		mode       NumberMode
+		want       any
+	}{
+		{
+			name: "[]map[string]any_int64",
+			ctyVal: cty.TupleVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{"k": cty.NumberIntVal(3)}),
+			}),
+			targetType: reflect.TypeOf([]map[string]any{}),
+			mode:       NumberInt64,
+			want:       []map[string]any{{"k": int64(3)}},
+		},
+		{
+			name: "map[string][]any_float64",
+			ctyVal: cty.ObjectVal(map[string]cty.Value{
+				"k": cty.TupleVal([]cty.Value{cty.NumberIntVal(1), cty.StringVal("a")}),
+			}),
+			targetType: reflect.TypeOf(map[string][]any{}),
+			mode:       NumberFloat64,
+			want:       map[string][]any{"k": {float64(1), "a"}},
+		},
+		{
+			name: "map[string]map[string]any_auto",
+			ctyVal: cty.ObjectVal(map[string]cty.Value{
+				"a": cty.ObjectVal(map[string]cty.Value{"b": cty.NumberIntVal(7)}),
+			}),
+			targetType: reflect.TypeOf(map[string]map[string]any{}),
+			mode:       NumberAuto,
+			want:       map[string]map[string]any{"a": {"b": 7}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertCtyToFieldTypeMode(tt.ctyVal, tt.targetType, tt.mode)
+			if err != nil {
+				t.Fatalf("ConvertCtyToFieldTypeMode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertCtyToFieldTypeMode() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ConvertCtyToFieldTypeMode(cty.StringVal("x"), reflect.TypeOf(map[string]any{}), NumberAuto); err == nil {
+		t.Error("expected an error for a string into map[string]any")
+	}
+}
+
 // TestConvertCtyToFieldType_NullValues tests null value handling
 func TestConvertCtyToFieldType_NullValues(t *testing.T) {
 	tests := []struct {
@@ -254,4 +355,134 @@ func TestConvertCtyToFieldType_OverflowDetection(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for overflow, got nil")
 	}
+
+	// one above math.MaxUint64
+	_, err = ConvertCtyToFieldType(cty.MustParseNumberVal("18446744073709551616"), reflect.TypeOf(uint64(0)))
+	if err == nil {
+		t.Error("Expected error for uint64 overflow, got nil")
+	}
+}
+
+// TestCtyNumberToNative_Boundaries tests numbers at and beyond the 64-bit limits
+func TestCtyNumberToNative_Boundaries(t *testing.T) {
+	tests := []struct {
+		name string
+		val  cty.Value
+		want any
+	}{
+		{"int32 max", cty.NumberIntVal(math.MaxInt32), int(math.MaxInt32)},
+		{"int32 max+1", cty.NumberIntVal(math.MaxInt32 + 1), int64(math.MaxInt32 + 1)},
+		{"int64 max", cty.NumberIntVal(math.MaxInt64), int64(math.MaxInt64)},
+		{"int64 min", cty.NumberIntVal(math.MinInt64), int64(math.MinInt64)},
+		{"int64 max+1", cty.MustParseNumberVal("9223372036854775808"), uint64(math.MaxInt64 + 1)},
+		{"uint64 max", cty.MustParseNumberVal("18446744073709551615"), uint64(math.MaxUint64)},
+		{"negative fraction", cty.NumberFloatVal(-1.5), float32(-1.5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CtyNumberToNative(tt.val)
+			if err != nil {
+				t.Fatalf("CtyNumberToNative() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CtyNumberToNative() = %v (type %T), want %v (type %T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+// TestCtyNumberToNative_Big tests numbers fitting no native type
+func TestCtyNumberToNative_Big(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"uint64 max+1", "18446744073709551616"},
+		{"int64 min-1", "-9223372036854775809"},
+		{"40 digits", "1234567890123456789012345678901234567890"},
+		{"beyond float64", "1e400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val := cty.MustParseNumberVal(tt.text)
+			got, err := CtyNumberToNative(val)
+			if err != nil {
+				t.Fatalf("CtyNumberToNative() error = %v", err)
+			}
+			var f *big.Float
+			switch x := got.(type) {
+			case *big.Int:
+				f = new(big.Float).SetInt(x)
+			case *big.Float:
+				f = x
+			default:
+				t.Fatalf("CtyNumberToNative() = %v (type %T), want a big number", got, got)
+			}
+			if f.Cmp(val.AsBigFloat()) != 0 {
+				t.Errorf("CtyNumberToNative() = %v, want %s", got, tt.text)
+			}
+
+			back, err := NativeToCty(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !back.Equals(val).True() {
+				t.Errorf("NativeToCty() = %#v, want %#v", back, val)
+			}
+		})
+	}
+
+	// a field of a big type holds the number exactly
+	val := cty.MustParseNumberVal("1234567890123456789012345678901234567890")
+	got, err := ConvertCtyToFieldType(val, reflect.TypeOf(&big.Int{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, ok := got.(*big.Int); !ok || x.String() != "1234567890123456789012345678901234567890" {
+		t.Errorf("ConvertCtyToFieldType() = %v (type %T)", got, got)
+	}
+}
+
+// TestCtyToNativeMode tests that each number mode gives one stable type
+func TestCtyToNativeMode(t *testing.T) {
+	val := cty.TupleVal([]cty.Value{
+		cty.NumberIntVal(1),
+		cty.NumberIntVal(5000000000),
+		cty.NumberFloatVal(1.5),
+	})
+	tests := []struct {
+		mode NumberMode
+		want []any
+	}{
+		{NumberAuto, []any{1, int64(5000000000), float32(1.5)}},
+		{NumberInt64, []any{int64(1), int64(5000000000), 1.5}},
+		{NumberFloat64, []any{1.0, 5000000000.0, 1.5}},
+		{NumberJSON, []any{json.Number("1"), json.Number("5000000000"), json.Number("1.5")}},
+	}
+	for _, tt := range tests {
+		got, err := CtyToNativeMode(val, tt.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("mode %d: got %#v, want %#v", tt.mode, got, tt.want)
+		}
+	}
+	// beyond int64 and float64, NumberInt64 keeps the value
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	beyond := new(big.Int).Exp(big.NewInt(10), big.NewInt(400), nil)
+	got, err := CtyToNativeMode(cty.TupleVal([]cty.Value{
+		cty.NumberVal(new(big.Float).SetInt(huge)),
+		cty.NumberVal(new(big.Float).SetInt(beyond)),
+	}), NumberInt64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := got.([]any)
+	if x, ok := list[0].(*big.Int); !ok || x.Cmp(huge) != 0 {
+		t.Errorf("got %#v, want %s", list[0], huge)
+	}
+	if x, ok := list[1].(*big.Int); !ok || x.Cmp(beyond) != 0 {
+		t.Errorf("got %#v, want %s", list[1], beyond)
+	}
 }
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func evalNative(t *testing.T, funcs map[string]any, src string) (cty.Value, error) {
+	t.Helper()
+	expr, diags := hclsyntax.ParseExpression([]byte(src), "test.hcl", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+	node := NewEvalContext(map[string]any{FUNCTIONS: funcs})
+	return ExpressionToCty(node.GetRef(), node, expr)
+}
+
+func TestCallToCtyScalars(t *testing.T) {
+	funcs := map[string]any{
+		"count": func() int { return 3 },
+		"ratio": func() float64 { return 0.5 },
+		"ok":    func() bool { return true },
+		"upper": func(s string) string { return strings.ToUpper(s) },
+		"half":  func(f float64) (float64, error) { return f / 2, nil },
+	}
+	tests := []struct {
+		src  string
+		want cty.Value
+	}{
+		{`count()`, cty.NumberIntVal(3)},
+		{`ratio()`, cty.NumberFloatVal(0.5)},
+		{`ok()`, cty.True},
+		{`upper("abc")`, cty.StringVal("ABC")},
+		{`half(1.5)`, cty.NumberFloatVal(0.75)},
+	}
+	for _, tt := range tests {
+		got, err := evalNative(t, funcs, tt.src)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.src, err)
+		}
+		if !got.RawEquals(tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCallToCtyContract(t *testing.T) {
+	called := false
+	funcs := map[string]any{
+		"fail": func() (int, error) { return 0, errors.New("boom") },
+		"pair": func() (int, int) { called = true; return 1, 2 },
+		"only": func() error { return nil },
+	}
+
+	if _, err := evalNative(t, funcs, `fail()`); err == nil || err.Error() != "boom" {
+		t.Errorf("expected boom, got %v", err)
+	}
+	if _, err := evalNative(t, funcs, `pair()`); err == nil || !strings.Contains(err.Error(), "one value") {
+		t.Errorf("expected contract error, got %v", err)
+	}
+	if called {
+		t.Error("function with two return values should not be called")
+	}
+	got, err := evalNative(t, funcs, `only()`)
+	if err != nil || !got.RawEquals(cty.EmptyObjectVal) {
+		t.Errorf("only() = %#v, %v", got, err)
+	}
+}